@@ -0,0 +1,14 @@
+// Package db embeds the database migrations in the binary.
+package db
+
+import "embed"
+
+// Migrations contains the Postgres migrations, in the directory "migrations".
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// SQLiteMigrations contains the SQLite migrations, in the directory "sqlite_migrations".
+//
+//go:embed sqlite_migrations/*.sql
+var SQLiteMigrations embed.FS
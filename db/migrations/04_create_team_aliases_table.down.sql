@@ -0,0 +1,2 @@
+DROP INDEX idx_team_aliases_team_id;
+DROP TABLE team_aliases;
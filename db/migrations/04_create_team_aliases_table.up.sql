@@ -0,0 +1,7 @@
+CREATE TABLE team_aliases (
+    id SERIAL PRIMARY KEY,
+    alias VARCHAR(255) NOT NULL UNIQUE,
+    team_id INTEGER NOT NULL REFERENCES teams(id)
+);
+
+CREATE INDEX idx_team_aliases_team_id ON team_aliases(team_id);
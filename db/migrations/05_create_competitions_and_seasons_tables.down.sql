@@ -0,0 +1,5 @@
+DROP INDEX idx_matches_competition_id;
+ALTER TABLE matches DROP COLUMN competition_id;
+DROP INDEX idx_seasons_competition_id;
+DROP TABLE seasons;
+DROP TABLE competitions;
@@ -0,0 +1,24 @@
+CREATE TABLE competitions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE TABLE seasons (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL REFERENCES competitions(id),
+    name VARCHAR(255) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    UNIQUE (competition_id, name)
+);
+
+CREATE INDEX idx_seasons_competition_id ON seasons(competition_id);
+
+-- Until now, everything was scraped from the Eredivisie page
+INSERT INTO competitions (name) VALUES ('Eredivisie');
+
+ALTER TABLE matches ADD COLUMN competition_id INTEGER REFERENCES competitions(id);
+UPDATE matches SET competition_id = (SELECT id FROM competitions WHERE name = 'Eredivisie');
+ALTER TABLE matches ALTER COLUMN competition_id SET NOT NULL;
+
+CREATE INDEX idx_matches_competition_id ON matches(competition_id);
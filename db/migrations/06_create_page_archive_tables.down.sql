@@ -0,0 +1,3 @@
+DROP INDEX idx_archived_pages_fetched_at;
+DROP TABLE archived_pages;
+DROP TABLE page_contents;
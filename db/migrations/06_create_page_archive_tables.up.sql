@@ -0,0 +1,14 @@
+CREATE TABLE page_contents (
+    hash CHAR(64) PRIMARY KEY,
+    body BYTEA NOT NULL
+);
+
+CREATE TABLE archived_pages (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    competition VARCHAR(255) NOT NULL,
+    hash CHAR(64) NOT NULL REFERENCES page_contents(hash),
+    fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_archived_pages_fetched_at ON archived_pages(fetched_at);
@@ -0,0 +1,6 @@
+DROP INDEX idx_matches_external_id;
+ALTER TABLE matches DROP COLUMN half_time_away_goals;
+ALTER TABLE matches DROP COLUMN half_time_home_goals;
+ALTER TABLE matches DROP COLUMN kickoff;
+ALTER TABLE matches DROP COLUMN status;
+ALTER TABLE matches DROP COLUMN external_id;
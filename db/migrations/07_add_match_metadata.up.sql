@@ -0,0 +1,7 @@
+ALTER TABLE matches ADD COLUMN external_id TEXT;
+ALTER TABLE matches ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'played';
+ALTER TABLE matches ADD COLUMN kickoff TIMESTAMP WITH TIME ZONE;
+ALTER TABLE matches ADD COLUMN half_time_home_goals INTEGER;
+ALTER TABLE matches ADD COLUMN half_time_away_goals INTEGER;
+
+CREATE UNIQUE INDEX idx_matches_external_id ON matches(external_id);
@@ -0,0 +1,5 @@
+CREATE TABLE source_stats (
+    url TEXT PRIMARY KEY,
+    rows_parsed INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
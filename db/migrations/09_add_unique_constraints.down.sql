@@ -0,0 +1,3 @@
+ALTER TABLE matches DROP CONSTRAINT matches_home_team_away_team_date_key;
+ALTER TABLE teams DROP CONSTRAINT teams_name_key;
+CREATE INDEX idx_teams_name ON teams(name);
@@ -0,0 +1,26 @@
+-- Merge teams that were stored more than once into the one that was stored first
+CREATE TEMPORARY TABLE duplicate_teams AS
+    SELECT t.id AS duplicate_id, k.id AS keep_id
+    FROM teams t
+    JOIN (SELECT name, MIN(id) AS id FROM teams GROUP BY name HAVING COUNT(*) > 1) k ON k.name = t.name
+    WHERE t.id <> k.id;
+
+UPDATE matches m SET home_team = d.keep_id FROM duplicate_teams d WHERE m.home_team = d.duplicate_id;
+UPDATE matches m SET away_team = d.keep_id FROM duplicate_teams d WHERE m.away_team = d.duplicate_id;
+UPDATE team_aliases a SET team_id = d.keep_id FROM duplicate_teams d WHERE a.team_id = d.duplicate_id;
+DELETE FROM teams t USING duplicate_teams d WHERE t.id = d.duplicate_id;
+
+DROP TABLE duplicate_teams;
+
+-- Of matches that were stored more than once, keep the one with an external ID, or else
+-- the one that was stored first
+DELETE FROM matches a
+USING matches b
+WHERE a.home_team = b.home_team
+    AND a.away_team = b.away_team
+    AND a.date = b.date
+    AND (a.external_id IS NULL, a.id) > (b.external_id IS NULL, b.id);
+
+DROP INDEX idx_teams_name;
+ALTER TABLE teams ADD CONSTRAINT teams_name_key UNIQUE (name);
+ALTER TABLE matches ADD CONSTRAINT matches_home_team_away_team_date_key UNIQUE (home_team, away_team, date);
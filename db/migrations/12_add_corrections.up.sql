@@ -0,0 +1,19 @@
+-- Corrected matches are left alone by the scraper; deleted matches and teams are kept,
+-- but left out of predictions and lists
+ALTER TABLE matches ADD COLUMN corrected BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE matches ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE teams ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    old_value TEXT,
+    new_value TEXT
+);
+
+CREATE INDEX audit_log_changed_at_idx ON audit_log (changed_at);
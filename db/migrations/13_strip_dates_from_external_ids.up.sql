@@ -0,0 +1,20 @@
+-- External IDs are links to the match page, which end in the day and month of the match,
+-- like ".../mvv-nac-15-08". Without them, the ID survives when a match is rescheduled.
+CREATE TEMPORARY TABLE stripped_external_ids AS
+    SELECT id, regexp_replace(external_id, '-[0-9]{2}-[0-9]{2}$', '') AS external_id
+    FROM matches
+    WHERE external_id IS NOT NULL;
+
+-- A rescheduled match was stored again under the ID with its new date. Of the rows that
+-- end up with the same ID, keep the one that was stored last.
+DELETE FROM matches m
+USING stripped_external_ids a, stripped_external_ids b
+WHERE m.id = a.id
+    AND a.external_id = b.external_id
+    AND a.id < b.id;
+
+DROP TABLE stripped_external_ids;
+
+UPDATE matches
+SET external_id = substr(external_id, 1, length(external_id) - 6)
+WHERE external_id ~ '-[0-9]{2}-[0-9]{2}$';
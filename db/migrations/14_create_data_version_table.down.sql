@@ -0,0 +1,4 @@
+DROP TRIGGER teams_data_version ON teams;
+DROP TRIGGER matches_data_version ON matches;
+DROP FUNCTION bump_data_version();
+DROP TABLE data_version;
@@ -0,0 +1,24 @@
+-- The version of the matches and teams goes up with every statement that changes them,
+-- in the same transaction, so that every instance of the app can tell when its cache is
+-- out of date
+CREATE TABLE data_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version BIGINT NOT NULL
+);
+
+INSERT INTO data_version (id, version) VALUES (1, 0);
+
+CREATE FUNCTION bump_data_version() RETURNS trigger AS $$
+BEGIN
+    UPDATE data_version SET version = version + 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER matches_data_version
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON matches
+    FOR EACH STATEMENT EXECUTE PROCEDURE bump_data_version();
+
+CREATE TRIGGER teams_data_version
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON teams
+    FOR EACH STATEMENT EXECUTE PROCEDURE bump_data_version();
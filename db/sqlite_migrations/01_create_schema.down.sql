@@ -0,0 +1,9 @@
+DROP TABLE source_stats;
+DROP TABLE archived_pages;
+DROP TABLE page_contents;
+DROP TABLE matches;
+DROP TABLE team_aliases;
+DROP TABLE teams;
+DROP TABLE seasons;
+DROP TABLE competitions;
+DROP TABLE stats;
@@ -0,0 +1,76 @@
+-- The same schema as the Postgres migrations up to 09_add_unique_constraints
+CREATE TABLE stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    last_scrape TIMESTAMP NOT NULL
+);
+
+CREATE TABLE competitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE seasons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    competition_id INTEGER NOT NULL REFERENCES competitions(id),
+    name TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    UNIQUE (competition_id, name)
+);
+
+CREATE TABLE teams (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE team_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alias TEXT NOT NULL UNIQUE,
+    team_id INTEGER NOT NULL REFERENCES teams(id)
+);
+
+CREATE INDEX idx_team_aliases_team_id ON team_aliases(team_id);
+
+CREATE TABLE matches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    competition_id INTEGER NOT NULL REFERENCES competitions(id),
+    home_team INTEGER NOT NULL REFERENCES teams(id),
+    away_team INTEGER NOT NULL REFERENCES teams(id),
+    home_goals INTEGER,
+    away_goals INTEGER,
+    date DATE NOT NULL,
+    external_id TEXT UNIQUE,
+    status TEXT NOT NULL DEFAULT 'played',
+    kickoff TIMESTAMP,
+    half_time_home_goals INTEGER,
+    half_time_away_goals INTEGER,
+    UNIQUE (home_team, away_team, date)
+);
+
+CREATE INDEX idx_matches_competition_id ON matches(competition_id);
+CREATE INDEX idx_matches_home_team ON matches(home_team);
+CREATE INDEX idx_matches_away_team ON matches(away_team);
+CREATE INDEX idx_matches_date ON matches(date);
+
+CREATE TABLE page_contents (
+    hash TEXT PRIMARY KEY,
+    body BLOB NOT NULL
+);
+
+CREATE TABLE archived_pages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    competition TEXT NOT NULL,
+    hash TEXT NOT NULL REFERENCES page_contents(hash),
+    fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_archived_pages_fetched_at ON archived_pages(fetched_at);
+
+CREATE TABLE source_stats (
+    url TEXT PRIMARY KEY,
+    rows_parsed INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO competitions (name) VALUES ('Eredivisie');
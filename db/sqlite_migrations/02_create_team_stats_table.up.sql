@@ -0,0 +1,26 @@
+-- Statistics per team and season, recomputed from the matches after every scrape
+CREATE TABLE team_stats (
+    competition_id INTEGER NOT NULL REFERENCES competitions(id),
+    team_id INTEGER NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    season TEXT NOT NULL,
+    played INTEGER NOT NULL,
+    won INTEGER NOT NULL,
+    drawn INTEGER NOT NULL,
+    lost INTEGER NOT NULL,
+    goals_for INTEGER NOT NULL,
+    goals_against INTEGER NOT NULL,
+    home_played INTEGER NOT NULL,
+    home_won INTEGER NOT NULL,
+    home_drawn INTEGER NOT NULL,
+    home_lost INTEGER NOT NULL,
+    home_goals_for INTEGER NOT NULL,
+    home_goals_against INTEGER NOT NULL,
+    away_played INTEGER NOT NULL,
+    away_won INTEGER NOT NULL,
+    away_drawn INTEGER NOT NULL,
+    away_lost INTEGER NOT NULL,
+    away_goals_for INTEGER NOT NULL,
+    away_goals_against INTEGER NOT NULL,
+    form TEXT NOT NULL,
+    PRIMARY KEY (competition_id, season, team_id)
+);
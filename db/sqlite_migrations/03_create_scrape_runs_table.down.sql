@@ -0,0 +1,9 @@
+CREATE TABLE stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    last_scrape TIMESTAMP NOT NULL
+);
+
+INSERT INTO stats (last_scrape)
+    SELECT finished_at FROM scrape_runs WHERE error IS NULL ORDER BY id;
+
+DROP TABLE scrape_runs;
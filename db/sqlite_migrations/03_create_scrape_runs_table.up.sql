@@ -0,0 +1,21 @@
+-- One row per scraped source, which replaces the timestamps in stats
+CREATE TABLE scrape_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    triggered_by TEXT NOT NULL,
+    competition TEXT,
+    source_url TEXT,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    http_status INTEGER,
+    rows_parsed INTEGER NOT NULL DEFAULT 0,
+    rows_inserted INTEGER NOT NULL DEFAULT 0,
+    rows_skipped INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX scrape_runs_finished_at_idx ON scrape_runs (finished_at);
+
+INSERT INTO scrape_runs (triggered_by, started_at, finished_at)
+    SELECT 'unknown', last_scrape, last_scrape FROM stats ORDER BY id;
+
+DROP TABLE stats;
@@ -0,0 +1,5 @@
+DROP TABLE audit_log;
+
+ALTER TABLE teams DROP COLUMN deleted_at;
+ALTER TABLE matches DROP COLUMN deleted_at;
+ALTER TABLE matches DROP COLUMN corrected;
@@ -0,0 +1,19 @@
+-- Corrected matches are left alone by the scraper; deleted matches and teams are kept,
+-- but left out of predictions and lists
+ALTER TABLE matches ADD COLUMN corrected INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE matches ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE teams ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    changed_at TIMESTAMP NOT NULL,
+    username TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT
+);
+
+CREATE INDEX audit_log_changed_at_idx ON audit_log (changed_at);
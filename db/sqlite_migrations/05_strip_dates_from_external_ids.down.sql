@@ -0,0 +1,3 @@
+-- This migration can't be reversed: the dates that were stripped from the external IDs,
+-- and the duplicate matches that were deleted, are gone. Rolling it back only lowers the
+-- schema version.
@@ -0,0 +1,7 @@
+DROP TRIGGER teams_delete_data_version;
+DROP TRIGGER teams_update_data_version;
+DROP TRIGGER teams_insert_data_version;
+DROP TRIGGER matches_delete_data_version;
+DROP TRIGGER matches_update_data_version;
+DROP TRIGGER matches_insert_data_version;
+DROP TABLE data_version;
@@ -0,0 +1,39 @@
+-- The version of the matches and teams goes up with every change to them, in the same
+-- transaction, so that every instance of the app can tell when its cache is out of date.
+-- SQLite only has row triggers.
+CREATE TABLE data_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+
+INSERT INTO data_version (id, version) VALUES (1, 0);
+
+CREATE TRIGGER matches_insert_data_version AFTER INSERT ON matches
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
+
+CREATE TRIGGER matches_update_data_version AFTER UPDATE ON matches
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
+
+CREATE TRIGGER matches_delete_data_version AFTER DELETE ON matches
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
+
+CREATE TRIGGER teams_insert_data_version AFTER INSERT ON teams
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
+
+CREATE TRIGGER teams_update_data_version AFTER UPDATE ON teams
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
+
+CREATE TRIGGER teams_delete_data_version AFTER DELETE ON teams
+BEGIN
+    UPDATE data_version SET version = version + 1;
+END;
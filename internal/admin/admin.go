@@ -0,0 +1,337 @@
+// Package admin corrects the matches and teams that were scraped, such as a wrong score
+// or a friendly that was listed by mistake, and records every correction in an audit
+// log.
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/team"
+)
+
+type DB interface {
+	// InTransaction runs fn in a transaction, which is only committed if fn returns nil.
+	InTransaction(fn func(tx Tx) error) error
+}
+
+// Tx contains what a correction reads and writes in a transaction.
+type Tx interface {
+	GetCompetitionID(name string) (int, error)
+	GetTeamID(teamName string) (int, error)
+	FetchTeamsFromDB() ([]team.Team, error)
+	GetMatchRecord(id int) (*match.Record, error)
+	CreateMatch(m match.Match) (int, error)
+	UpdateMatch(id int, m match.Match) error
+	DeleteMatch(id int, deletedAt time.Time) error
+	CreateTeam(name string) (int, error)
+	RenameTeam(id int, name string) error
+	DeleteTeam(id int, deletedAt time.Time) error
+	MergeTeams(sourceID, targetID int) error
+	RecordChange(c audit.Change) error
+	// RefreshTeamStats recomputes the team statistics from the stored matches.
+	RefreshTeamStats() error
+}
+
+// Editor makes corrections. Each correction happens in a transaction of its own,
+// together with its entry in the audit log.
+type Editor struct {
+	DB DB
+}
+
+func NewEditor(db DB) *Editor {
+	return &Editor{DB: db}
+}
+
+// ValidationError means that a correction doesn't make sense, as opposed to a failure to
+// store it.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{fmt.Sprintf(format, args...)}
+}
+
+// CreateMatch adds a match, and returns its ID.
+func (e *Editor) CreateMatch(user, reason string, m match.Match) (int, error) {
+	if err := checkReason(reason); err != nil {
+		return 0, err
+	}
+
+	var matchID int
+	err := e.DB.InTransaction(func(tx Tx) error {
+		if err := validateMatch(tx, m); err != nil {
+			return err
+		}
+
+		var err error
+		matchID, err = tx.CreateMatch(m)
+		if err != nil {
+			return err
+		}
+		created, err := tx.GetMatchRecord(matchID)
+		if err != nil {
+			return err
+		}
+
+		if err := recordChange(tx, user, reason, audit.MatchEntity, matchID, audit.Create, nil, created); err != nil {
+			return err
+		}
+		return tx.RefreshTeamStats()
+	})
+	return matchID, err
+}
+
+// UpdateMatch corrects a match: edit changes the stored version, which is then stored
+// instead.
+func (e *Editor) UpdateMatch(user, reason string, id int, edit func(m *match.Match)) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+
+	return e.DB.InTransaction(func(tx Tx) error {
+		before, err := tx.GetMatchRecord(id)
+		if err != nil {
+			return err
+		}
+
+		m := before.Match
+		edit(&m)
+		if err := validateMatch(tx, m); err != nil {
+			return err
+		}
+
+		if err := tx.UpdateMatch(id, m); err != nil {
+			return err
+		}
+		after, err := tx.GetMatchRecord(id)
+		if err != nil {
+			return err
+		}
+
+		if err := recordChange(tx, user, reason, audit.MatchEntity, id, audit.Update, before, after); err != nil {
+			return err
+		}
+		return tx.RefreshTeamStats()
+	})
+}
+
+// DeleteMatch deletes a match. It's kept in the database, but it doesn't count anymore.
+func (e *Editor) DeleteMatch(user, reason string, id int) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+
+	return e.DB.InTransaction(func(tx Tx) error {
+		before, err := tx.GetMatchRecord(id)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteMatch(id, time.Now()); err != nil {
+			return err
+		}
+
+		if err := recordChange(tx, user, reason, audit.MatchEntity, id, audit.Delete, before, nil); err != nil {
+			return err
+		}
+		return tx.RefreshTeamStats()
+	})
+}
+
+// CreateTeam adds a team, and returns its ID.
+func (e *Editor) CreateTeam(user, reason, name string) (int, error) {
+	if err := checkReason(reason); err != nil {
+		return 0, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, invalid("A team name is required.")
+	}
+
+	var teamID int
+	err := e.DB.InTransaction(func(tx Tx) error {
+		var err error
+		teamID, err = tx.CreateTeam(name)
+		if err != nil {
+			return err
+		}
+
+		return recordChange(tx, user, reason, audit.TeamEntity, teamID, audit.Create, nil, team.Team{ID: teamID, Name: name})
+	})
+	return teamID, err
+}
+
+// RenameTeam renames a team. The scraper still recognizes it by its old name.
+func (e *Editor) RenameTeam(user, reason string, id int, name string) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return invalid("A team name is required.")
+	}
+
+	return e.DB.InTransaction(func(tx Tx) error {
+		teams, err := tx.FetchTeamsFromDB()
+		if err != nil {
+			return err
+		}
+
+		if err := tx.RenameTeam(id, name); err != nil {
+			return err
+		}
+
+		before := findTeam(teams, id)
+		return recordChange(tx, user, reason, audit.TeamEntity, id, audit.Update, before, team.Team{ID: id, Name: name})
+	})
+}
+
+// DeleteTeam deletes a team, which must not have any matches left.
+func (e *Editor) DeleteTeam(user, reason string, id int) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+
+	return e.DB.InTransaction(func(tx Tx) error {
+		teams, err := tx.FetchTeamsFromDB()
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteTeam(id, time.Now()); err != nil {
+			return err
+		}
+
+		return recordChange(tx, user, reason, audit.TeamEntity, id, audit.Delete, findTeam(teams, id), nil)
+	})
+}
+
+// MergeTeams merges the source team into the target team, which gets its matches and
+// its name as an alias.
+func (e *Editor) MergeTeams(user, reason string, sourceID, targetID int) error {
+	if err := checkReason(reason); err != nil {
+		return err
+	}
+	if sourceID == targetID {
+		return invalid("source_id and target_id must be different.")
+	}
+
+	return e.DB.InTransaction(func(tx Tx) error {
+		teams, err := tx.FetchTeamsFromDB()
+		if err != nil {
+			return err
+		}
+
+		if err := tx.MergeTeams(sourceID, targetID); err != nil {
+			return err
+		}
+
+		before, after := findTeam(teams, sourceID), findTeam(teams, targetID)
+		if err := recordChange(tx, user, reason, audit.TeamEntity, sourceID, audit.Merge, before, after); err != nil {
+			return err
+		}
+		return tx.RefreshTeamStats()
+	})
+}
+
+func checkReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return invalid("A reason is required.")
+	}
+	return nil
+}
+
+// validateMatch checks that a match is complete, and that its competition and teams
+// exist, so that a typo doesn't add a new team.
+func validateMatch(tx Tx, m match.Match) error {
+	switch {
+	case m.Competition == "":
+		return invalid("A competition is required.")
+	case m.HomeTeam == "" || m.AwayTeam == "":
+		return invalid("Both home_team and away_team are required.")
+	case m.HomeTeam == m.AwayTeam:
+		return invalid("home_team and away_team must be different.")
+	case m.Date.IsZero():
+		return invalid("A date is required.")
+	}
+
+	switch m.Status {
+	case match.Played, match.Awarded:
+		if m.HomeGoals < 0 || m.AwayGoals < 0 {
+			return invalid("Goals can't be negative.")
+		}
+	case match.Postponed, match.Abandoned:
+	default:
+		return invalid("Invalid status %q, expected played, postponed, abandoned or awarded.", m.Status)
+	}
+
+	if _, err := tx.GetCompetitionID(m.Competition); errors.Is(err, sql.ErrNoRows) {
+		return invalid("Unknown competition %q.", m.Competition)
+	} else if err != nil {
+		return err
+	}
+	for _, name := range []string{m.HomeTeam, m.AwayTeam} {
+		if _, err := tx.GetTeamID(name); errors.Is(err, sql.ErrNoRows) {
+			return invalid("Unknown team %q.", name)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordChange adds a change to the audit log. before and after are stored as JSON,
+// unless they're nil.
+func recordChange(tx Tx, user, reason string, entity audit.Entity, id int, action audit.Action, before, after interface{}) error {
+	c := audit.Change{
+		ChangedAt: time.Now(),
+		User:      user,
+		Reason:    strings.TrimSpace(reason),
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+	}
+
+	var err error
+	if c.Before, err = toJSON(before); err != nil {
+		return err
+	}
+	if c.After, err = toJSON(after); err != nil {
+		return err
+	}
+
+	return tx.RecordChange(c)
+}
+
+func toJSON(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("Error encoding change: %v", err)
+	}
+	return string(b), nil
+}
+
+func findTeam(teams []team.Team, id int) interface{} {
+	for _, t := range teams {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
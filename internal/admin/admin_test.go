@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryDB struct {
+	*memory.DB
+}
+
+func (db memoryDB) InTransaction(fn func(tx Tx) error) error {
+	return db.DB.InTransaction(func(tx database.Store) error {
+		return fn(tx)
+	})
+}
+
+func newTestEditor(t *testing.T) (*Editor, *memory.DB) {
+	db := memory.New()
+	_, err := db.BulkLoadMatches([]match.Match{played("Ajax", "PSV", 2, 1)})
+	require.NoError(t, err)
+	return NewEditor(memoryDB{db}), db
+}
+
+func played(home, away string, homeGoals, awayGoals int) match.Match {
+	return match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    home,
+		AwayTeam:    away,
+		HomeGoals:   homeGoals,
+		AwayGoals:   awayGoals,
+		Date:        time.Date(2023, time.May, 1, 0, 0, 0, 0, dates.Amsterdam),
+		Status:      match.Played,
+	}
+}
+
+func matchID(t *testing.T, db *memory.DB) int {
+	records, err := db.GetMatchRecords(match.Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	return records[0].ID
+}
+
+func TestUpdateMatchRecordsChange(t *testing.T) {
+	editor, db := newTestEditor(t)
+	id := matchID(t, db)
+
+	err := editor.UpdateMatch("admin", "Wrong score", id, func(m *match.Match) { m.HomeGoals = 3 })
+	require.NoError(t, err)
+
+	record, err := db.GetMatchRecord(id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, record.HomeGoals)
+	assert.True(t, record.Corrected)
+
+	changes, err := db.GetChanges(10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "admin", changes[0].User)
+	assert.Equal(t, "Wrong score", changes[0].Reason)
+	assert.Equal(t, audit.MatchEntity, changes[0].Entity)
+	assert.Equal(t, audit.Update, changes[0].Action)
+	assert.Equal(t, id, changes[0].EntityID)
+
+	var before, after match.Record
+	require.NoError(t, json.Unmarshal([]byte(changes[0].Before), &before))
+	require.NoError(t, json.Unmarshal([]byte(changes[0].After), &after))
+	assert.Equal(t, 2, before.HomeGoals)
+	assert.Equal(t, 3, after.HomeGoals)
+}
+
+func TestDeleteMatchRecordsChange(t *testing.T) {
+	editor, db := newTestEditor(t)
+	id := matchID(t, db)
+
+	require.NoError(t, editor.DeleteMatch("admin", "Friendly", id))
+
+	changes, err := db.GetChanges(10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, audit.Delete, changes[0].Action)
+	assert.NotEmpty(t, changes[0].Before)
+	assert.Empty(t, changes[0].After)
+
+	assert.Equal(t, database.ErrMatchNotFound, editor.DeleteMatch("admin", "Friendly", id))
+}
+
+func TestInvalidCorrectionsChangeNothing(t *testing.T) {
+	editor, db := newTestEditor(t)
+	id := matchID(t, db)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"no reason", editor.UpdateMatch("admin", " ", id, func(m *match.Match) { m.HomeGoals = 3 })},
+		{"negative goals", editor.UpdateMatch("admin", "Typo", id, func(m *match.Match) { m.HomeGoals = -1 })},
+		{"same teams", editor.UpdateMatch("admin", "Typo", id, func(m *match.Match) { m.AwayTeam = "Ajax" })},
+		{"unknown team", editor.UpdateMatch("admin", "Typo", id, func(m *match.Match) { m.AwayTeam = "Feyenord" })},
+		{"invalid status", editor.UpdateMatch("admin", "Typo", id, func(m *match.Match) { m.Status = "cancelled" })},
+	}
+	for _, tt := range tests {
+		var validationErr *ValidationError
+		assert.ErrorAs(t, tt.err, &validationErr, tt.name)
+	}
+
+	record, err := db.GetMatchRecord(id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, record.HomeGoals)
+	assert.False(t, record.Corrected)
+
+	teams, err := db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	assert.Len(t, teams, 2)
+
+	changes, err := db.GetChanges(10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestTeamCorrections(t *testing.T) {
+	editor, db := newTestEditor(t)
+
+	teamID, err := editor.CreateTeam("admin", "Promoted", "Feyenoord")
+	require.NoError(t, err)
+	require.NoError(t, editor.RenameTeam("admin", "Full name", teamID, "Feyenoord Rotterdam"))
+	require.NoError(t, editor.DeleteTeam("admin", "Not promoted after all", teamID))
+
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	assert.Equal(t, database.ErrTeamInUse, editor.DeleteTeam("admin", "Oops", ajax))
+
+	changes, err := db.GetChanges(10)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, audit.Delete, changes[0].Action)
+	assert.Equal(t, `{"ID":`+strconv.Itoa(teamID)+`,"Name":"Feyenoord Rotterdam"}`, changes[0].Before)
+	assert.Equal(t, audit.Create, changes[2].Action)
+}
+
+func TestMergeTeamsRecordsChange(t *testing.T) {
+	editor, db := newTestEditor(t)
+	psv, err := db.GetTeamID("PSV")
+	require.NoError(t, err)
+	eindhoven, err := editor.CreateTeam("admin", "Scraped under another name", "PSV Eindhoven")
+	require.NoError(t, err)
+
+	assert.Error(t, editor.MergeTeams("admin", " ", psv, eindhoven))
+	require.NoError(t, editor.MergeTeams("admin", "Same team", psv, eindhoven))
+
+	id, err := db.GetTeamID("PSV")
+	require.NoError(t, err)
+	assert.Equal(t, eindhoven, id)
+
+	changes, err := db.GetChanges(1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, audit.Merge, changes[0].Action)
+	assert.Equal(t, psv, changes[0].EntityID)
+	assert.Equal(t, "Same team", changes[0].Reason)
+	assert.Equal(t, `{"ID":`+strconv.Itoa(eindhoven)+`,"Name":"PSV Eindhoven"}`, changes[0].After)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The codes of an Error, which clients can rely on, unlike the messages.
+const (
+	CodeInvalidParameter = "invalid_parameter"
+	CodeUnauthorized     = "unauthorized"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInternal         = "internal_error"
+)
+
+// Error is what every failed request returns, wrapped in an ErrorResponse.
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorResponse is the body of a failed request.
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// InvalidParameter means that a parameter is missing or can't be parsed.
+func InvalidParameter(parameter, message string) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeInvalidParameter,
+		Message: message,
+		Details: map[string]string{"parameter": parameter},
+	}
+}
+
+func Unauthorized() *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: "Unauthorized."}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+// MethodNotAllowed lists the methods that are allowed in its details.
+func MethodNotAllowed(allowed ...string) *Error {
+	return &Error{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    CodeMethodNotAllowed,
+		Message: "Method not allowed.",
+		Details: map[string]string{"allow": strings.Join(allowed, ", ")},
+	}
+}
+
+// Internal hides what went wrong from the client, so the cause should be logged.
+func Internal(message string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message}
+}
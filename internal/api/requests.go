@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/season"
+)
+
+// PredictRequest asks for the score of a match. A zero CompetitionID means the default
+// competition.
+type PredictRequest struct {
+	CompetitionID int
+	HomeTeamID    int
+	AwayTeamID    int
+}
+
+func ParsePredictRequest(query url.Values) (PredictRequest, error) {
+	var req PredictRequest
+	if query.Get("home_team_id") == "" || query.Get("away_team_id") == "" {
+		return req, InvalidParameter("home_team_id", "Both home_team_id and away_team_id are required.")
+	}
+
+	var err error
+	if req.HomeTeamID, err = strconv.Atoi(query.Get("home_team_id")); err != nil {
+		return req, InvalidParameter("home_team_id", "Invalid home_team_id.")
+	}
+	if req.AwayTeamID, err = strconv.Atoi(query.Get("away_team_id")); err != nil {
+		return req, InvalidParameter("away_team_id", "Invalid away_team_id.")
+	}
+	req.CompetitionID, err = parseCompetitionID(query)
+	return req, err
+}
+
+type TeamIDRequest struct {
+	TeamName string
+}
+
+func ParseTeamIDRequest(query url.Values) (TeamIDRequest, error) {
+	req := TeamIDRequest{TeamName: query.Get("team_name")}
+	if req.TeamName == "" {
+		return req, InvalidParameter("team_name", "team_name is required.")
+	}
+	return req, nil
+}
+
+type ScrapeRequest struct {
+	// DryRun reports what a scrape would change, without storing anything.
+	DryRun bool
+}
+
+func ParseScrapeRequest(query url.Values) (ScrapeRequest, error) {
+	var req ScrapeRequest
+	if dryRun := query.Get("dry_run"); dryRun != "" {
+		var err error
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return req, InvalidParameter("dry_run", "Invalid dry_run, expected true or false.")
+		}
+	}
+	return req, nil
+}
+
+// StandingsRequest selects a season, or a range of dates; if neither is given, the
+// current season.
+type StandingsRequest struct {
+	CompetitionID int
+	Season        string
+	From          time.Time
+	To            time.Time
+}
+
+func ParseStandingsRequest(query url.Values) (StandingsRequest, error) {
+	var req StandingsRequest
+	var err error
+	if req.CompetitionID, err = parseCompetitionID(query); err != nil {
+		return req, err
+	}
+
+	switch {
+	case query.Get("from") != "" || query.Get("to") != "":
+		req.From, err = time.ParseInLocation("2006-01-02", query.Get("from"), dates.Amsterdam)
+		if err != nil {
+			return req, InvalidParameter("from", "Invalid from, expected YYYY-MM-DD.")
+		}
+		req.To, err = time.ParseInLocation("2006-01-02", query.Get("to"), dates.Amsterdam)
+		if err != nil || req.To.Before(req.From) {
+			return req, InvalidParameter("to", "Invalid to, expected YYYY-MM-DD on or after from.")
+		}
+	case query.Get("season") != "":
+		req.Season, err = parseSeason(query)
+	}
+	return req, err
+}
+
+// TeamStatsRequest selects a season; if it's empty, the current one.
+type TeamStatsRequest struct {
+	CompetitionID int
+	Season        string
+}
+
+func ParseTeamStatsRequest(query url.Values) (TeamStatsRequest, error) {
+	var req TeamStatsRequest
+	var err error
+	if req.CompetitionID, err = parseCompetitionID(query); err != nil {
+		return req, err
+	}
+	req.Season, err = parseSeason(query)
+	return req, err
+}
+
+func parseCompetitionID(query url.Values) (int, error) {
+	competitionIDStr := query.Get("competition_id")
+	if competitionIDStr == "" {
+		return 0, nil
+	}
+	competitionID, err := strconv.Atoi(competitionIDStr)
+	if err != nil {
+		return 0, InvalidParameter("competition_id", "Invalid competition_id.")
+	}
+	return competitionID, nil
+}
+
+func parseSeason(query url.Values) (string, error) {
+	name := query.Get("season")
+	if name == "" {
+		return "", nil
+	}
+	if _, err := season.StartYear(name); err != nil {
+		return "", InvalidParameter("season", "Invalid season, expected a name like 2022-2023.")
+	}
+	return name, nil
+}
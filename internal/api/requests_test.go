@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePredictRequest(t *testing.T) {
+	req, err := ParsePredictRequest(url.Values{"home_team_id": {"1"}, "away_team_id": {"2"}, "competition_id": {"3"}})
+	require.NoError(t, err)
+	assert.Equal(t, PredictRequest{CompetitionID: 3, HomeTeamID: 1, AwayTeamID: 2}, req)
+
+	req, err = ParsePredictRequest(url.Values{"home_team_id": {"1"}, "away_team_id": {"2"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, req.CompetitionID)
+}
+
+func TestParseRequestErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		parse     func(url.Values) error
+		query     url.Values
+		parameter string
+	}{
+		{"missing team", func(q url.Values) error { _, err := ParsePredictRequest(q); return err }, url.Values{"home_team_id": {"1"}}, "home_team_id"},
+		{"invalid team", func(q url.Values) error { _, err := ParsePredictRequest(q); return err }, url.Values{"home_team_id": {"1"}, "away_team_id": {"x"}}, "away_team_id"},
+		{"invalid competition", func(q url.Values) error { _, err := ParsePredictRequest(q); return err }, url.Values{"home_team_id": {"1"}, "away_team_id": {"2"}, "competition_id": {"x"}}, "competition_id"},
+		{"missing team name", func(q url.Values) error { _, err := ParseTeamIDRequest(q); return err }, url.Values{}, "team_name"},
+		{"invalid dry run", func(q url.Values) error { _, err := ParseScrapeRequest(q); return err }, url.Values{"dry_run": {"maybe"}}, "dry_run"},
+		{"invalid season", func(q url.Values) error { _, err := ParseStandingsRequest(q); return err }, url.Values{"season": {"2023"}}, "season"},
+		{"to before from", func(q url.Values) error { _, err := ParseStandingsRequest(q); return err }, url.Values{"from": {"2023-05-01"}, "to": {"2023-04-01"}}, "to"},
+		{"invalid stats season", func(q url.Values) error { _, err := ParseTeamStatsRequest(q); return err }, url.Values{"season": {"last"}}, "season"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr *Error
+			require.ErrorAs(t, tt.parse(tt.query), &apiErr)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+			assert.Equal(t, CodeInvalidParameter, apiErr.Code)
+			assert.Equal(t, tt.parameter, apiErr.Details["parameter"])
+		})
+	}
+}
+
+func TestParseStandingsRequest(t *testing.T) {
+	req, err := ParseStandingsRequest(url.Values{"from": {"2023-01-01"}, "to": {"2023-03-31"}})
+	require.NoError(t, err)
+	assert.Equal(t, "2023-01-01", req.From.Format("2006-01-02"))
+	assert.Equal(t, "2023-03-31", req.To.Format("2006-01-02"))
+	assert.Empty(t, req.Season)
+
+	req, err = ParseStandingsRequest(url.Values{"season": {"2022-2023"}})
+	require.NoError(t, err)
+	assert.Equal(t, "2022-2023", req.Season)
+	assert.True(t, req.From.IsZero())
+}
+
+func TestErrorResponse(t *testing.T) {
+	b, err := json.Marshal(ErrorResponse{Error: MethodNotAllowed(http.MethodGet)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"error": {"code": "method_not_allowed", "message": "Method not allowed.", "details": {"allow": "GET"}}}`, string(b))
+
+	b, err = json.Marshal(ErrorResponse{Error: NotFound("Team not found.")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"error": {"code": "not_found", "message": "Team not found."}}`, string(b))
+}
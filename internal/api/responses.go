@@ -0,0 +1,260 @@
+// Package api defines the requests and responses of version 1 of the REST API, under
+// /api/v1. Unlike the internal types, which they're converted from, they're a contract
+// with the clients: fields may be added, but not renamed or removed.
+package api
+
+import (
+	"time"
+
+	"github.com/jqno/balGPT/internal/competition"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/predictor"
+	"github.com/jqno/balGPT/internal/scraper"
+	"github.com/jqno/balGPT/internal/standings"
+	"github.com/jqno/balGPT/internal/team"
+	"github.com/jqno/balGPT/internal/teamstats"
+)
+
+type Prediction struct {
+	CompetitionID int `json:"competition_id"`
+	HomeTeamID    int `json:"home_team_id"`
+	AwayTeamID    int `json:"away_team_id"`
+	HomeGoals     int `json:"home_goals"`
+	AwayGoals     int `json:"away_goals"`
+}
+
+func NewPrediction(req PredictRequest, p *predictor.Prediction) Prediction {
+	return Prediction{
+		CompetitionID: req.CompetitionID,
+		HomeTeamID:    req.HomeTeamID,
+		AwayTeamID:    req.AwayTeamID,
+		HomeGoals:     p.HomeGoals,
+		AwayGoals:     p.AwayGoals,
+	}
+}
+
+type TeamID struct {
+	TeamID int `json:"team_id"`
+}
+
+type Team struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewTeams(teams []team.Team) []Team {
+	result := make([]Team, 0, len(teams))
+	for _, t := range teams {
+		result = append(result, Team{ID: t.ID, Name: t.Name})
+	}
+	return result
+}
+
+// DuplicateCandidate is a pair of teams whose names are so similar that they may be the
+// same team.
+type DuplicateCandidate struct {
+	First      Team    `json:"first"`
+	Second     Team    `json:"second"`
+	Similarity float64 `json:"similarity"`
+}
+
+func NewDuplicateCandidates(candidates []team.DuplicateCandidate) []DuplicateCandidate {
+	result := make([]DuplicateCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		result = append(result, DuplicateCandidate{
+			First:      Team{ID: c.First.ID, Name: c.First.Name},
+			Second:     Team{ID: c.Second.ID, Name: c.Second.Name},
+			Similarity: c.Similarity,
+		})
+	}
+	return result
+}
+
+type Competition struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewCompetitions(competitions []competition.Competition) []Competition {
+	result := make([]Competition, 0, len(competitions))
+	for _, c := range competitions {
+		result = append(result, Competition{ID: c.ID, Name: c.Name})
+	}
+	return result
+}
+
+// Standings is the league table of a competition over a season or a range of dates,
+// which are formatted as YYYY-MM-DD.
+type Standings struct {
+	CompetitionID int        `json:"competition_id"`
+	Season        string     `json:"season,omitempty"`
+	From          string     `json:"from"`
+	To            string     `json:"to"`
+	Table         []Standing `json:"table"`
+}
+
+type Standing struct {
+	Position int    `json:"position"`
+	TeamID   int    `json:"team_id"`
+	Team     string `json:"team"`
+	Points   int    `json:"points"`
+}
+
+func NewTable(table []standings.Standing) []Standing {
+	result := make([]Standing, 0, len(table))
+	for _, s := range table {
+		result = append(result, Standing{Position: s.Position, TeamID: s.TeamID, Team: s.Team, Points: s.Points})
+	}
+	return result
+}
+
+type TeamStats struct {
+	CompetitionID int    `json:"competition_id"`
+	TeamID        int    `json:"team_id"`
+	Season        string `json:"season"`
+	Total         Record `json:"total"`
+	Home          Record `json:"home"`
+	Away          Record `json:"away"`
+	// Form contains the results of the last matches, oldest first, as W, D or L.
+	Form string `json:"form"`
+}
+
+type Record struct {
+	Played       int `json:"played"`
+	Won          int `json:"won"`
+	Drawn        int `json:"drawn"`
+	Lost         int `json:"lost"`
+	GoalsFor     int `json:"goals_for"`
+	GoalsAgainst int `json:"goals_against"`
+	Points       int `json:"points"`
+}
+
+func NewTeamStats(stats []teamstats.Stats) []TeamStats {
+	result := make([]TeamStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, TeamStats{
+			CompetitionID: s.CompetitionID,
+			TeamID:        s.TeamID,
+			Season:        s.Season,
+			Total:         newRecord(s.Total),
+			Home:          newRecord(s.Home),
+			Away:          newRecord(s.Away),
+			Form:          s.Form,
+		})
+	}
+	return result
+}
+
+func newRecord(r teamstats.Record) Record {
+	return Record{
+		Played:       r.Played,
+		Won:          r.Won,
+		Drawn:        r.Drawn,
+		Lost:         r.Lost,
+		GoalsFor:     r.GoalsFor,
+		GoalsAgainst: r.GoalsAgainst,
+		Points:       r.Points(),
+	}
+}
+
+// DryRunReport tells what a scrape would change, per source.
+type DryRunReport struct {
+	Sources []SourceReport `json:"sources"`
+}
+
+type SourceReport struct {
+	Competition  string        `json:"competition"`
+	URL          string        `json:"url"`
+	NewMatches   []Match       `json:"new_matches"`
+	NewTeams     []string      `json:"new_teams"`
+	ScoreChanges []ScoreChange `json:"score_changes"`
+	Unchanged    int           `json:"unchanged"`
+	// Skipped describes the rows that couldn't be parsed.
+	Skipped []string `json:"skipped"`
+}
+
+type ScoreChange struct {
+	Scraped Match `json:"scraped"`
+	Stored  Match `json:"stored"`
+}
+
+// Match has a score only if it was played or awarded. Its date is formatted as
+// YYYY-MM-DD, and its kickoff, if known, as RFC 3339.
+type Match struct {
+	ExternalID        string `json:"external_id,omitempty"`
+	Competition       string `json:"competition"`
+	HomeTeam          string `json:"home_team"`
+	AwayTeam          string `json:"away_team"`
+	HomeGoals         *int   `json:"home_goals"`
+	AwayGoals         *int   `json:"away_goals"`
+	Date              string `json:"date"`
+	Status            string `json:"status"`
+	Kickoff           string `json:"kickoff,omitempty"`
+	HalfTimeHomeGoals *int   `json:"half_time_home_goals,omitempty"`
+	HalfTimeAwayGoals *int   `json:"half_time_away_goals,omitempty"`
+}
+
+// MatchRecord is a stored match, with what an admin needs to correct it. Its deletion
+// time, if it was deleted, is formatted as RFC 3339.
+type MatchRecord struct {
+	ID int `json:"id"`
+	Match
+	Corrected bool   `json:"corrected"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+func NewMatchRecords(records []match.Record) []MatchRecord {
+	result := make([]MatchRecord, 0, len(records))
+	for _, r := range records {
+		record := MatchRecord{ID: r.ID, Match: newMatch(r.Match), Corrected: r.Corrected}
+		if !r.DeletedAt.IsZero() {
+			record.DeletedAt = r.DeletedAt.Format(time.RFC3339)
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+func NewDryRunReport(report *scraper.DryRunReport) DryRunReport {
+	result := DryRunReport{Sources: make([]SourceReport, 0, len(report.Sources))}
+	for _, s := range report.Sources {
+		source := SourceReport{
+			Competition:  s.Competition,
+			URL:          s.URL,
+			NewMatches:   make([]Match, 0, len(s.NewMatches)),
+			NewTeams:     append([]string{}, s.NewTeams...),
+			ScoreChanges: make([]ScoreChange, 0, len(s.ScoreChanges)),
+			Unchanged:    s.Unchanged,
+			Skipped:      append([]string{}, s.Skipped...),
+		}
+		for _, m := range s.NewMatches {
+			source.NewMatches = append(source.NewMatches, newMatch(m))
+		}
+		for _, c := range s.ScoreChanges {
+			source.ScoreChanges = append(source.ScoreChanges, ScoreChange{Scraped: newMatch(c.Scraped), Stored: newMatch(c.Stored)})
+		}
+		result.Sources = append(result.Sources, source)
+	}
+	return result
+}
+
+func newMatch(m match.Match) Match {
+	result := Match{
+		ExternalID:        m.ExternalID,
+		Competition:       m.Competition,
+		HomeTeam:          m.HomeTeam,
+		AwayTeam:          m.AwayTeam,
+		Date:              m.Date.Format("2006-01-02"),
+		Status:            string(m.Status),
+		HalfTimeHomeGoals: m.HalfTimeHomeGoals,
+		HalfTimeAwayGoals: m.HalfTimeAwayGoals,
+	}
+	if m.HasScore() {
+		homeGoals, awayGoals := m.HomeGoals, m.AwayGoals
+		result.HomeGoals, result.AwayGoals = &homeGoals, &awayGoals
+	}
+	if !m.Kickoff.IsZero() {
+		result.Kickoff = m.Kickoff.Format(time.RFC3339)
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SpecJSON is the OpenAPI document that describes the API. It's written by hand, and
+// a test checks that it agrees with the handlers.
+//
+//go:embed openapi.json
+var SpecJSON []byte
+
+// Spec is the part of an OpenAPI document that the docs page shows. References to
+// parameters and responses are resolved; references to schemas are kept, so that
+// they can be shown by name.
+type Spec struct {
+	Info       Info
+	Paths      map[string]map[string]*Operation
+	Components Components
+}
+
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+type Components struct {
+	Parameters map[string]*Parameter
+	Responses  map[string]*Response
+	Schemas    map[string]*Schema
+}
+
+type Operation struct {
+	Summary     string
+	Description string
+	Deprecated  bool
+	Parameters  []*Parameter
+	RequestBody *RequestBody
+	Responses   map[string]*Response
+	// Security is empty for the operations that don't need credentials, and nil for the
+	// ones that need the default ones.
+	Security []map[string][]string
+}
+
+type Parameter struct {
+	Ref         string `json:"$ref"`
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      *Schema
+	Example     interface{}
+}
+
+// RequestBody is what is posted; here, always the fields of a form.
+type RequestBody struct {
+	Required bool
+	Content  map[string]MediaType
+}
+
+// Form is the schema of the posted form, or nil if the body isn't a form.
+func (b *RequestBody) Form() *Schema {
+	if b == nil {
+		return nil
+	}
+	return b.Content["application/x-www-form-urlencoded"].Schema
+}
+
+type Response struct {
+	Ref         string `json:"$ref"`
+	Description string
+	Content     map[string]MediaType
+}
+
+type MediaType struct {
+	Schema *Schema
+}
+
+type Schema struct {
+	Ref         string `json:"$ref"`
+	Type        string
+	Format      string
+	Description string
+	Nullable    bool
+	Enum        []string
+	Required    []string
+	Properties  map[string]*Schema
+	Items       *Schema
+	Example     interface{}
+}
+
+// String describes the type of the schema, such as "array of Team".
+func (s *Schema) String() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Ref != "":
+		return s.Name()
+	case s.Type == "array":
+		return "array of " + s.Items.String()
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	default:
+		return s.Type
+	}
+}
+
+// Name is the name of the schema that it refers to.
+func (s *Schema) Name() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// IsRequired tells whether the object must have the property.
+func (s *Schema) IsRequired(property string) bool {
+	for _, name := range s.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint is an operation on a path.
+type Endpoint struct {
+	Path   string
+	Method string
+	*Operation
+}
+
+func LoadSpec() (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(SpecJSON, &spec); err != nil {
+		return nil, fmt.Errorf("Error parsing OpenAPI spec: %v", err)
+	}
+
+	for path, operations := range spec.Paths {
+		for method, op := range operations {
+			for i, p := range op.Parameters {
+				if p.Ref == "" {
+					continue
+				}
+				resolved, ok := spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				if !ok {
+					return nil, fmt.Errorf("Error in OpenAPI spec: %s %s refers to unknown parameter %s", method, path, p.Ref)
+				}
+				op.Parameters[i] = resolved
+			}
+			for status, r := range op.Responses {
+				if r.Ref == "" {
+					continue
+				}
+				resolved, ok := spec.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+				if !ok {
+					return nil, fmt.Errorf("Error in OpenAPI spec: %s %s refers to unknown response %s", method, path, r.Ref)
+				}
+				op.Responses[status] = resolved
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// Schema returns the schema that s refers to, or s itself if it doesn't refer to one.
+func (spec *Spec) Schema(s *Schema) *Schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return spec.Components.Schemas[s.Name()]
+}
+
+// Endpoints lists all operations, by path and method.
+func (spec *Spec) Endpoints() []Endpoint {
+	endpoints := []Endpoint{}
+	for path, operations := range spec.Paths {
+		for method, op := range operations {
+			endpoints = append(endpoints, Endpoint{Path: path, Method: strings.ToUpper(method), Operation: op})
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
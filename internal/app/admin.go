@@ -0,0 +1,460 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jqno/balGPT/internal/admin"
+	"github.com/jqno/balGPT/internal/api"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/competition"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/export"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/team"
+)
+
+// adminDB hands the editor a transaction that satisfies its own interface.
+type adminDB struct {
+	database.Store
+}
+
+func (db adminDB) InTransaction(fn func(tx admin.Tx) error) error {
+	return db.Store.InTransaction(func(tx database.Store) error {
+		return fn(tx)
+	})
+}
+
+// CorrectionsData is what the corrections page shows.
+type CorrectionsData struct {
+	Competitions       []competition.Competition
+	DefaultCompetition string
+	Teams              []team.Team
+	Matches            []match.Record
+	Changes            []audit.Change
+}
+
+// handleMatchRecords lists the matches of a season, including deleted ones, so that
+// they can be corrected. It takes the same filters as the exports, and defaults to the
+// current season.
+func handleMatchRecords(db database.Store, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, ok := fetchMatchRecords(w, r, db, defaultCompetition)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.NewMatchRecords(records))
+	}
+}
+
+// fetchMatchRecords returns the matches that the request asks for. If that fails, it
+// writes the error and returns false.
+func fetchMatchRecords(w http.ResponseWriter, r *http.Request, db database.Store, defaultCompetition string) ([]match.Record, bool) {
+	filter, err := export.ParseFilter(db, r.URL.Query(), defaultCompetition)
+	var filterErr *export.FilterError
+	if errors.As(err, &filterErr) {
+		http.Error(w, filterErr.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error: %s", err)
+		http.Error(w, "Error while fetching matches.", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if filter.From.IsZero() && filter.To.IsZero() {
+		s, err := db.SeasonAt(filter.CompetitionID, time.Now())
+		if err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Error while fetching season.", http.StatusInternalServerError)
+			return nil, false
+		}
+		filter.From, filter.To = s.Start, s.End
+	}
+
+	records, err := db.GetMatchRecords(match.Filter{CompetitionID: filter.CompetitionID, TeamID: filter.TeamID, From: filter.From, To: filter.To})
+	if err != nil {
+		log.Printf("Error: %s", err)
+		http.Error(w, "Error while fetching matches.", http.StatusInternalServerError)
+		return nil, false
+	}
+	return records, true
+}
+
+func handleCreateMatch(editor *admin.Editor, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		edit, err := parseMatchForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m := match.Match{Competition: defaultCompetition, Status: match.Played}
+		edit(&m)
+
+		matchID, err := editor.CreateMatch(username(r), r.FormValue("reason"), m)
+		if err != nil {
+			writeCorrectionError(w, err, "creating match")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int{"match_id": matchID})
+	}
+}
+
+// handleUpdateMatch changes the fields of a match that were posted, and leaves the
+// others alone.
+func handleUpdateMatch(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		matchID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid id.", http.StatusBadRequest)
+			return
+		}
+
+		edit, err := parseMatchForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := editor.UpdateMatch(username(r), r.FormValue("reason"), matchID, edit); err != nil {
+			writeCorrectionError(w, err, "updating match")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"match_id": matchID})
+	}
+}
+
+func handleDeleteMatch(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		matchID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid id.", http.StatusBadRequest)
+			return
+		}
+
+		if err := editor.DeleteMatch(username(r), r.FormValue("reason"), matchID); err != nil {
+			writeCorrectionError(w, err, "deleting match")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"match_id": matchID})
+	}
+}
+
+func handleCreateTeam(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		teamID, err := editor.CreateTeam(username(r), r.FormValue("reason"), r.FormValue("name"))
+		if err != nil {
+			writeCorrectionError(w, err, "creating team")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int{"team_id": teamID})
+	}
+}
+
+func handleRenameTeam(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		teamID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid id.", http.StatusBadRequest)
+			return
+		}
+
+		if err := editor.RenameTeam(username(r), r.FormValue("reason"), teamID, r.FormValue("name")); err != nil {
+			writeCorrectionError(w, err, "renaming team")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"team_id": teamID})
+	}
+}
+
+func handleDeleteTeam(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		teamID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid id.", http.StatusBadRequest)
+			return
+		}
+
+		if err := editor.DeleteTeam(username(r), r.FormValue("reason"), teamID); err != nil {
+			writeCorrectionError(w, err, "deleting team")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"team_id": teamID})
+	}
+}
+
+// handleChanges lists the most recent corrections, newest first.
+func handleChanges(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := parseLimit(r)
+		if err != nil {
+			http.Error(w, "Invalid limit.", http.StatusBadRequest)
+			return
+		}
+
+		changes, err := db.GetChanges(limit)
+		if err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Error while fetching changes.", http.StatusInternalServerError)
+			return
+		}
+		if changes == nil {
+			changes = []audit.Change{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(changes)
+	}
+}
+
+func correctionsPageHandler(db database.Store, appBaseDir string, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, ok := fetchMatchRecords(w, r, db, defaultCompetition)
+		if !ok {
+			return
+		}
+
+		competitions, err := db.FetchCompetitionsFromDB()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching competitions: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		teams, err := db.FetchTeamsFromDB()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching teams: %v", err), http.StatusInternalServerError)
+			return
+		}
+		sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+
+		changes, err := db.GetChanges(defaultLimit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching changes: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		data := CorrectionsData{
+			Competitions:       competitions,
+			DefaultCompetition: defaultCompetition,
+			Teams:              teams,
+			Matches:            records,
+			Changes:            changes,
+		}
+
+		templateFile := filepath.Join(appBaseDir, "templates/corrections.html")
+		tmpl, err := template.ParseFiles(templateFile)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing template: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		err = tmpl.Execute(w, data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error executing template: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// parseMatchForm parses the fields of a match that were posted, and returns a function
+// that sets them on a match. Fields that weren't posted are left alone; optional fields
+// that were posted empty are cleared.
+func parseMatchForm(r *http.Request) (func(m *match.Match), error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.New("Invalid form.")
+	}
+	form := r.PostForm
+
+	edits := []func(m *match.Match){}
+	for _, field := range []string{"competition", "home_team", "away_team"} {
+		field := field
+		if !form.Has(field) {
+			continue
+		}
+		value := strings.TrimSpace(form.Get(field))
+		edits = append(edits, func(m *match.Match) {
+			switch field {
+			case "competition":
+				m.Competition = value
+			case "home_team":
+				m.HomeTeam = value
+			case "away_team":
+				m.AwayTeam = value
+			}
+		})
+	}
+
+	if form.Has("date") {
+		date, err := time.ParseInLocation("2006-01-02", form.Get("date"), dates.Amsterdam)
+		if err != nil {
+			return nil, errors.New("Invalid date, expected YYYY-MM-DD.")
+		}
+		edits = append(edits, func(m *match.Match) { m.Date = date })
+	}
+
+	if form.Has("status") {
+		status := match.Status(form.Get("status"))
+		edits = append(edits, func(m *match.Match) { m.Status = status })
+	}
+
+	if form.Has("kickoff") {
+		var kickoff time.Time
+		if form.Get("kickoff") != "" {
+			var err error
+			kickoff, err = time.Parse(time.RFC3339, form.Get("kickoff"))
+			if err != nil {
+				return nil, errors.New("Invalid kickoff, expected a time like 2023-08-12T20:00:00+02:00.")
+			}
+		}
+		edits = append(edits, func(m *match.Match) { m.Kickoff = kickoff })
+	}
+
+	goalFields := []struct {
+		name     string
+		set      func(m *match.Match, goals int)
+		optional bool
+	}{
+		{"home_goals", func(m *match.Match, goals int) { m.HomeGoals = goals }, false},
+		{"away_goals", func(m *match.Match, goals int) { m.AwayGoals = goals }, false},
+		{"half_time_home_goals", func(m *match.Match, goals int) { m.HalfTimeHomeGoals = &goals }, true},
+		{"half_time_away_goals", func(m *match.Match, goals int) { m.HalfTimeAwayGoals = &goals }, true},
+	}
+	for _, field := range goalFields {
+		field := field
+		if !form.Has(field.name) {
+			continue
+		}
+		value := form.Get(field.name)
+		if value == "" && field.optional {
+			edits = append(edits, func(m *match.Match) { clearHalfTime(m, field.name) })
+			continue
+		}
+		goals, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s.", field.name)
+		}
+		edits = append(edits, func(m *match.Match) { field.set(m, goals) })
+	}
+
+	return func(m *match.Match) {
+		for _, edit := range edits {
+			edit(m)
+		}
+	}, nil
+}
+
+func clearHalfTime(m *match.Match, field string) {
+	if field == "half_time_home_goals" {
+		m.HalfTimeHomeGoals = nil
+	} else {
+		m.HalfTimeAwayGoals = nil
+	}
+}
+
+// writeCorrectionError tells the client why a correction failed.
+func writeCorrectionError(w http.ResponseWriter, err error, action string) {
+	var validationErr *admin.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+	case errors.Is(err, database.ErrMatchNotFound):
+		http.Error(w, "Match not found.", http.StatusNotFound)
+	case errors.Is(err, database.ErrTeamNotFound):
+		http.Error(w, "Team not found.", http.StatusNotFound)
+	case errors.Is(err, database.ErrDuplicate):
+		http.Error(w, "Another match or team is already stored like that.", http.StatusConflict)
+	case errors.Is(err, database.ErrTeamInUse):
+		http.Error(w, "Team still has matches; delete them or merge the team first.", http.StatusConflict)
+	default:
+		log.Printf("Error: %s", err)
+		http.Error(w, "Error while "+action+".", http.StatusInternalServerError)
+	}
+}
+
+// allowPost only lets POST requests through, and only from pages of the app itself:
+// browsers send the admin's credentials along with a form on any other site, too.
+func allowPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return false
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-origin request refused.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sameOrigin tells whether the request comes from a page of the app, according to its
+// Origin header, or its Referer header if it has no Origin. Requests without either
+// don't come from a browser, such as those made with curl, and are let through.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// username returns the admin who made the request, for the audit log.
+func username(r *http.Request) string {
+	user, _, _ := r.BasicAuth()
+	return user
+}
@@ -0,0 +1,422 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/jqno/balGPT/internal/api"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/predictor"
+	"github.com/jqno/balGPT/internal/scraper"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/standings"
+	"github.com/jqno/balGPT/internal/teamstats"
+)
+
+// apiRoute is an endpoint of the API under /api/v1, which only answers to one method.
+type apiRoute struct {
+	Path    string
+	Method  string
+	Handler http.HandlerFunc
+}
+
+// apiRoutes lists the endpoints under /api/v1. Each of them must be in the OpenAPI spec.
+func (a *App) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{"/api/v1/predict", http.MethodGet, apiPredict(a.DB, a.Scraper, a.Predictor, a.Config.DefaultCompetition)},
+		{"/api/v1/scrape", http.MethodPost, apiScrape(a.Scraper)},
+		{"/api/v1/team_id", http.MethodGet, apiTeamID(a.DB)},
+		{"/api/v1/teams", http.MethodGet, apiTeams(a.DB)},
+		{"/api/v1/competitions", http.MethodGet, apiCompetitions(a.DB)},
+		{"/api/v1/standings", http.MethodGet, apiStandings(a.DB, a.Config.DefaultCompetition)},
+		{"/api/v1/team_stats", http.MethodGet, apiTeamStats(a.DB, a.Config.DefaultCompetition)},
+	}
+}
+
+// The endpoints below are served both under /api/v1, with typed JSON responses and
+// errors, and at their original paths, which answer like they always did. Their errors
+// are *api.Error values, so that either can write them.
+
+// requestScrapeTimeout bounds a scrape that a request waits for, retries included, so
+// that a slow or rate-limiting source doesn't hold up the request for minutes. Scrapes
+// by the scheduler and the CLI wait as long as the source asks.
+const requestScrapeTimeout = 10 * time.Second
+
+// predictScrapePause is how long predictions don't scrape after a scrape failed, so that
+// a source that is down or has changed its layout isn't fetched for every prediction.
+const predictScrapePause = 15 * time.Minute
+
+// predict scrapes, so that the prediction is based on the latest matches. If the scrape
+// fails, which the scrape runs and the health check report, it predicts from the matches
+// that are already stored. It returns the request with the competition filled in.
+func predict(ctx context.Context, db database.Store, s *scraper.ScrapeData, p predictor.Predictor, defaultCompetition string, req api.PredictRequest) (api.PredictRequest, *predictor.Prediction, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestScrapeTimeout)
+	defer cancel()
+
+	if health := s.Health(); health.Failing() && time.Since(health.LastFailure) < predictScrapePause {
+		log.Printf("Not scraping before predicting, since the last scrape failed at %s", health.LastFailure.Format(time.RFC3339))
+	} else if err := s.Scrape(ctx, scraperun.Predict); err != nil {
+		log.Printf("Error scraping before predicting; predicting from the stored matches: %s", err)
+	}
+
+	competitionID, err := competitionOrDefault(db, req.CompetitionID, defaultCompetition)
+	if err != nil {
+		return req, nil, err
+	}
+	req.CompetitionID = competitionID
+
+	prediction, err := p.Predict(req.CompetitionID, req.HomeTeamID, req.AwayTeamID)
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return req, nil, api.Internal("Error while generating prediction.")
+	}
+
+	log.Printf("Prediction for competition_id=%d, home_team_id=%d, away_team_id=%d: %d - %d", req.CompetitionID, req.HomeTeamID, req.AwayTeamID, prediction.HomeGoals, prediction.AwayGoals)
+	return req, prediction, nil
+}
+
+func lookupTeamID(db database.Store, req api.TeamIDRequest) (int, error) {
+	teamID, err := db.GetTeamID(req.TeamName)
+	if err == sql.ErrNoRows || (err == nil && teamID == 0) {
+		return 0, api.NotFound("Team not found.")
+	} else if err != nil {
+		log.Printf("Error: %s", err)
+		return 0, api.Internal("Error while fetching team ID.")
+	}
+	return teamID, nil
+}
+
+// scrape returns a report if the request is a dry run, and nil otherwise.
+func scrape(ctx context.Context, s *scraper.ScrapeData, req api.ScrapeRequest) (*scraper.DryRunReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestScrapeTimeout)
+	defer cancel()
+
+	if req.DryRun {
+		report, err := s.DryRun(ctx)
+		if err != nil {
+			log.Printf("Error: %s", err)
+			return nil, api.Internal("Error while scraping data.")
+		}
+		return report, nil
+	}
+
+	if err := s.Scrape(ctx, scraperun.Scrape); err != nil {
+		log.Printf("Error: %s", err)
+		return nil, api.Internal("Error while scraping data.")
+	}
+	log.Printf("Scraping complete")
+	return nil, nil
+}
+
+func getStandings(db database.Store, defaultCompetition string, req api.StandingsRequest) (Standings, error) {
+	competitionID, err := competitionOrDefault(db, req.CompetitionID, defaultCompetition)
+	if err != nil {
+		return Standings{}, err
+	}
+
+	s := season.Season{Start: req.From, End: req.To}
+	switch {
+	case !req.From.IsZero():
+	case req.Season != "":
+		s, err = db.GetSeason(competitionID, req.Season)
+	default:
+		s, err = db.SeasonAt(competitionID, time.Now())
+	}
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return Standings{}, api.Internal("Error while fetching season.")
+	}
+
+	leaderboard, err := db.GetLeaderboard(competitionID, s.Start, s.End)
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return Standings{}, api.Internal("Error while fetching standings.")
+	}
+
+	teams, err := db.FetchTeamsFromDB()
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return Standings{}, api.Internal("Error while fetching teams.")
+	}
+
+	return Standings{
+		CompetitionID: competitionID,
+		Season:        s.Name,
+		From:          s.Start.Format("2006-01-02"),
+		To:            s.End.Format("2006-01-02"),
+		Table:         standings.Table(leaderboard, teams),
+	}, nil
+}
+
+func getTeamStats(db database.Store, defaultCompetition string, req api.TeamStatsRequest) ([]teamstats.Stats, error) {
+	competitionID, err := competitionOrDefault(db, req.CompetitionID, defaultCompetition)
+	if err != nil {
+		return nil, err
+	}
+
+	seasonName := req.Season
+	if seasonName == "" {
+		s, err := db.SeasonAt(competitionID, time.Now())
+		if err != nil {
+			log.Printf("Error: %s", err)
+			return nil, api.Internal("Error while fetching season.")
+		}
+		seasonName = s.Name
+	}
+
+	stats, err := db.GetTeamStats(competitionID, seasonName)
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return nil, api.Internal("Error while fetching team stats.")
+	}
+	return stats, nil
+}
+
+func competitionOrDefault(db database.Store, competitionID int, defaultCompetition string) (int, error) {
+	if competitionID != 0 {
+		return competitionID, nil
+	}
+	competitionID, err := db.GetCompetitionID(defaultCompetition)
+	if err != nil {
+		log.Printf("Error: %s", err)
+		return 0, api.Internal("Error while fetching default competition.")
+	}
+	return competitionID, nil
+}
+
+func apiPredict(db database.Store, s *scraper.ScrapeData, p predictor.Predictor, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParsePredictRequest(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		req, prediction, err := predict(r.Context(), db, s, p, defaultCompetition, req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.NewPrediction(req, prediction))
+	}
+}
+
+func apiTeamID(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParseTeamIDRequest(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		teamID, err := lookupTeamID(db, req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.TeamID{TeamID: teamID})
+	}
+}
+
+func apiTeams(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		teams, err := db.FetchTeamsFromDB()
+		if err != nil {
+			log.Printf("Error: %s", err)
+			writeAPIError(w, api.Internal("Error while fetching teams."))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.NewTeams(teams))
+	}
+}
+
+func apiCompetitions(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		competitions, err := db.FetchCompetitionsFromDB()
+		if err != nil {
+			log.Printf("Error: %s", err)
+			writeAPIError(w, api.Internal("Error while fetching competitions."))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.NewCompetitions(competitions))
+	}
+}
+
+// apiScrape answers a dry run with a report, and a real scrape with no content.
+func apiScrape(s *scraper.ScrapeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeAPIError(w, api.InvalidParameter("body", "Invalid form."))
+			return
+		}
+		req, err := api.ParseScrapeRequest(r.Form)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		report, err := scrape(r.Context(), s, req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		if report == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, api.NewDryRunReport(report))
+	}
+}
+
+func apiStandings(db database.Store, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParseStandingsRequest(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		s, err := getStandings(db, defaultCompetition, req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newStandings(s))
+	}
+}
+
+func newStandings(s Standings) api.Standings {
+	return api.Standings{
+		CompetitionID: s.CompetitionID,
+		Season:        s.Season,
+		From:          s.From,
+		To:            s.To,
+		Table:         api.NewTable(s.Table),
+	}
+}
+
+func apiTeamStats(db database.Store, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParseTeamStatsRequest(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		stats, err := getTeamStats(db, defaultCompetition, req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.NewTeamStats(stats))
+	}
+}
+
+// handleOpenAPISpec serves the specification of the API, without authentication, so
+// that anyone who builds a client can read it.
+func handleOpenAPISpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(api.SpecJSON)
+	}
+}
+
+// apiDocsHandler shows the specification of the API as a page.
+func apiDocsHandler(appBaseDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := api.LoadSpec()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error loading API spec: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		templateFile := filepath.Join(appBaseDir, "templates/api_docs.html")
+		tmpl, err := template.ParseFiles(templateFile)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing template: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		err = tmpl.Execute(w, spec)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error executing template: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func apiNotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, api.NotFound("Unknown endpoint."))
+	}
+}
+
+// checkAPIAuth is checkAuth, but answers with a JSON error.
+func checkAPIAuth(h http.HandlerFunc, validUsername, validPassword string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, validUsername, validPassword) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			writeAPIError(w, api.Unauthorized())
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// allowMethod rejects requests with any other method than the given one; GET allows
+// HEAD too.
+func allowMethod(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method && !(method == http.MethodGet && r.Method == http.MethodHead) {
+			w.Header().Set("Allow", method)
+			writeAPIError(w, api.MethodNotAllowed(method))
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes err in the JSON envelope. Errors that aren't an *api.Error are
+// unexpected, so they're logged and hidden from the client.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("Error: %s", err)
+		apiErr = api.Internal("Internal server error.")
+	}
+	writeJSON(w, apiErr.Status, api.ErrorResponse{Error: apiErr})
+}
+
+// writeTextError writes err like the original routes always did: as plain text.
+func writeTextError(w http.ResponseWriter, err error) {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("Error: %s", err)
+		apiErr = api.Internal("Internal server error.")
+	}
+	http.Error(w, apiErr.Message, apiErr.Status)
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/api"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/jqno/balGPT/internal/database/cache"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/fetcher"
+	"github.com/jqno/balGPT/internal/scraper"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	a, err := NewDemoApp(&config.Config{
+		AuthUsername:       "admin",
+		AuthPassword:       "secret",
+		DefaultCompetition: "Eredivisie",
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func request(t *testing.T, server *httptest.Server, method, path string) *http.Response {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeError(t *testing.T, resp *http.Response) *api.Error {
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var body api.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.NotNil(t, body.Error)
+	return body.Error
+}
+
+func TestAPIPredict(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := request(t, server, http.MethodGet, "/api/v1/teams")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var teams []api.Team
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&teams))
+	require.True(t, len(teams) >= 2)
+
+	resp = request(t, server, http.MethodGet, "/api/v1/predict?home_team_id="+strconv.Itoa(teams[0].ID)+"&away_team_id="+strconv.Itoa(teams[1].ID))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var prediction api.Prediction
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&prediction))
+	assert.Equal(t, teams[0].ID, prediction.HomeTeamID)
+	assert.NotZero(t, prediction.CompetitionID)
+}
+
+func TestAPIErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"invalid parameter", http.MethodGet, "/api/v1/predict?home_team_id=1", http.StatusBadRequest, api.CodeInvalidParameter},
+		{"unknown team", http.MethodGet, "/api/v1/team_id?team_name=Nobody", http.StatusNotFound, api.CodeNotFound},
+		{"wrong method", http.MethodPost, "/api/v1/standings", http.StatusMethodNotAllowed, api.CodeMethodNotAllowed},
+		{"scrape with GET", http.MethodGet, "/api/v1/scrape", http.StatusMethodNotAllowed, api.CodeMethodNotAllowed},
+		{"unknown endpoint", http.MethodGet, "/api/v1/nothing", http.StatusNotFound, api.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := request(t, server, tt.method, tt.path)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, tt.code, decodeError(t, resp).Code)
+		})
+	}
+}
+
+func TestAPIRequiresAuth(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/v1/teams")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	assert.Equal(t, api.CodeUnauthorized, decodeError(t, resp).Code)
+}
+
+func TestOriginalRoutesAnswerAsBefore(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := request(t, server, http.MethodGet, "/predict?home_team_id=1")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+
+	resp = request(t, server, http.MethodGet, "/scrape")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = request(t, server, http.MethodGet, "/standings")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var s api.Standings
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&s))
+	assert.NotZero(t, s.CompetitionID)
+	assert.NotEmpty(t, s.Table)
+}
+
+func TestAdminRoutesAnswerInSnakeCase(t *testing.T) {
+	db := memory.New()
+	require.NoError(t, db.RecordScrapeRuns([]scraperun.Run{{Trigger: scraperun.Scrape, StartedAt: time.Now(), FinishedAt: time.Now()}}))
+	require.NoError(t, db.RecordChange(audit.Change{ChangedAt: time.Now(), User: "admin", Reason: "typo", Entity: audit.TeamEntity, Action: audit.Update}))
+
+	for _, tt := range []struct {
+		name    string
+		handler http.HandlerFunc
+		key     string
+	}{
+		{"scrape runs", handleScrapeRuns(db), "started_at"},
+		{"changes", handleChanges(db), "entity_id"},
+		{"cache", handleCacheStats(cache.New(db)), "hits"},
+	} {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusOK, w.Code, tt.name)
+		assert.Contains(t, w.Body.String(), `"`+tt.key+`"`, tt.name)
+	}
+}
+
+func TestBufferedExportAnswersLikeStreamedExport(t *testing.T) {
+	db := memory.New()
+	require.NoError(t, db.LoadDemo(time.Now()))
+
+	for _, path := range []string{
+		"/export/matches",
+		"/export/matches?competition_id=all",
+		"/export/standings?competition_id=all",
+	} {
+		streamed := httptest.NewRecorder()
+		handleExport(db, "Eredivisie", false)(streamed, httptest.NewRequest(http.MethodGet, path, nil))
+		buffered := httptest.NewRecorder()
+		handleExport(db, "Eredivisie", true)(buffered, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, streamed.Code, buffered.Code, path)
+		assert.Equal(t, streamed.Body.String(), buffered.Body.String(), path)
+		assert.Equal(t, streamed.Header().Get("Content-Disposition"), buffered.Header().Get("Content-Disposition"), path)
+	}
+
+	w := httptest.NewRecorder()
+	handleExport(db, "Eredivisie", true)(w, httptest.NewRequest(http.MethodGet, "/export/standings?competition_id=all", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminPostsFromOtherSitesAreRefused(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, tt := range []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"Origin", "https://evil.example.com", http.StatusForbidden},
+		{"Referer", "https://evil.example.com/form.html", http.StatusForbidden},
+		{"Origin", server.URL, http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/admin/teams/merge", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("admin", "secret")
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tt.status, resp.StatusCode, "%s: %s", tt.header, tt.value)
+	}
+}
+
+// failingFetcher fails like a source that changed its layout, and counts the fetches.
+type failingFetcher struct {
+	fetches int
+}
+
+func (f *failingFetcher) Fetch(ctx context.Context, url string) (*fetcher.Page, error) {
+	f.fetches++
+	return nil, errors.New("layout changed")
+}
+
+func TestPredictionsSurviveAFailingScrape(t *testing.T) {
+	a, err := NewDemoApp(&config.Config{AuthUsername: "admin", AuthPassword: "secret", DefaultCompetition: "Eredivisie"})
+	require.NoError(t, err)
+	f := &failingFetcher{}
+	a.Scraper.Fetcher = f
+	a.Scraper.Sources = []scraper.Source{{Competition: "Eredivisie", URL: "https://example.com/eredivisie"}}
+	server := httptest.NewServer(a.Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/predict", "/api/v1/predict", "/predict"} {
+		resp := request(t, server, http.MethodGet, path+"?home_team_id=1&away_team_id=2")
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+	assert.Equal(t, 1, f.fetches, "predictions scraped again right after a failure")
+
+	resp := request(t, server, http.MethodGet, "/scrape")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 2, f.fetches)
+}
@@ -1,37 +1,99 @@
 package app
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jqno/balGPT/internal/admin"
+	"github.com/jqno/balGPT/internal/api"
+	"github.com/jqno/balGPT/internal/competition"
 	"github.com/jqno/balGPT/internal/config"
 	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/database/cache"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/database/sqlite"
+	"github.com/jqno/balGPT/internal/export"
+	"github.com/jqno/balGPT/internal/fetcher"
 	"github.com/jqno/balGPT/internal/predictor"
 	"github.com/jqno/balGPT/internal/scraper"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/standings"
 	"github.com/jqno/balGPT/internal/team"
 )
 
 type App struct {
 	Config    *config.Config
-	DB        *database.DB
+	DB        database.Store
+	Cache     *cache.Store
 	Scraper   *scraper.ScrapeData
 	Predictor predictor.Predictor
+	Editor    *admin.Editor
+
+	// checkSchema is nil for stores without a schema
+	checkSchema func() error
+}
+
+// Standings is the league table of a competition over a season or a range of dates.
+type Standings struct {
+	CompetitionID int
+	Season        string
+	From          string
+	To            string
+	Table         []standings.Standing
 }
 
 type TemplateData struct {
-	ApiBaseURL string
-	Teams      []team.Team
+	ApiBaseURL   string
+	Teams        []team.Team
+	Competitions []competition.Competition
 }
 
-func NewApp(cfg *config.Config) *App {
-	db := database.New(cfg.DBConnectionString, cfg.AppBaseDir)
-	scraper := scraper.NewScrapeData(db, cfg.ScraperURL)
+func NewApp(cfg *config.Config) (*App, error) {
+	sources := make([]scraper.Source, 0, len(cfg.ScraperSources))
+	for _, source := range cfg.ScraperSources {
+		sources = append(sources, scraper.Source{Competition: source.Competition, URL: source.URL})
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newApp(cfg, store, sources), nil
+}
+
+// NewDemoApp returns an App that keeps everything in memory, filled with demo data. It
+// needs neither a database nor network access, because it doesn't scrape.
+func NewDemoApp(cfg *config.Config) (*App, error) {
+	db := memory.New()
+	db.SeasonBoundary = cfg.SeasonBoundary
+	if err := db.LoadDemo(time.Now()); err != nil {
+		return nil, err
+	}
+	return newApp(cfg, db, nil), nil
+}
+
+func newApp(cfg *config.Config, store database.Store, sources []scraper.Source) *App {
+	db := cache.New(store)
+
+	// The statistics are refreshed after every scrape; refresh them now too, in case the
+	// table was just created or the matches were changed by hand
+	if err := db.RefreshTeamStats(); err != nil {
+		log.Printf("Error refreshing team stats: %v", err)
+	}
+
+	scraper := scraper.NewScrapeData(scraperDB{db}, fetcher.New(), sources...)
 
 	predictor := predictor.NewCompositePredictor(
 		predictor.NewHomeAdvantagePredictor(),
@@ -41,45 +103,192 @@ func NewApp(cfg *config.Config) *App {
 		predictor.NewLeaderboardDifferencePredictor(db),
 	)
 
-	return &App{
+	app := &App{
 		Config:    cfg,
 		DB:        db,
+		Cache:     db,
 		Scraper:   scraper,
 		Predictor: predictor,
+		Editor:    admin.NewEditor(adminDB{db}),
 	}
+	if s, ok := store.(schemaChecker); ok {
+		app.checkSchema = s.CheckSchema
+	}
+	return app
 }
 
-func (a *App) Run() {
-	http.HandleFunc("/", indexHandler(a.DB, a.Config.AppBaseDir, a.Config.ApiBaseURL, a.Config.AuthUsername, a.Config.AuthPassword))
-	http.HandleFunc("/login", checkAuth(loginHandler(), a.Config.AuthUsername, a.Config.AuthPassword))
-	http.HandleFunc("/predict", checkAuth(handlePrediction(a.Scraper, a.Predictor), a.Config.AuthUsername, a.Config.AuthPassword))
-	http.HandleFunc("/scrape", checkAuth(handleScrape(a.Scraper), a.Config.AuthUsername, a.Config.AuthPassword))
-	http.HandleFunc("/team_id", checkAuth(handleTeamID(a.DB), a.Config.AuthUsername, a.Config.AuthPassword))
-	http.HandleFunc("/health", healthCheckHandler(a.DB))
+// schemaChecker is implemented by the stores whose schema is managed by migrations.
+type schemaChecker interface {
+	CheckSchema() error
+}
+
+// newStore opens the storage backend that is selected in the config.
+func newStore(cfg *config.Config) (database.Store, error) {
+	if cfg.DBBackend == config.BackendSQLite {
+		log.Printf("Using SQLite database %s", cfg.SQLitePath)
+		db, err := sqlite.New(cfg.SQLitePath, cfg.AutoMigrate)
+		if err != nil {
+			return nil, err
+		}
+		db.SeasonBoundary = cfg.SeasonBoundary
+		return db, nil
+	}
 
+	db, err := database.New(cfg.DBConnectionString, cfg.AutoMigrate, cfg.StartupTimeout)
+	if err != nil {
+		return nil, err
+	}
+	db.SeasonBoundary = cfg.SeasonBoundary
+	return db, nil
+}
+
+// OpenMigrations connects to the storage backend that is selected in the config, without
+// migrating it. Close both the migrations and the connection when done.
+func OpenMigrations(cfg *config.Config) (*database.Migrations, *sql.DB, error) {
+	if cfg.DBBackend == config.BackendSQLite {
+		conn, err := sqlite.Open(cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrations, err := sqlite.Migrations(conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return migrations, conn, nil
+	}
+
+	conn, err := sql.Open("postgres", cfg.DBConnectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrations, err := database.PostgresMigrations(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return migrations, conn, nil
+}
+
+// scraperDB hands the scraper a transaction that satisfies its own interface.
+type scraperDB struct {
+	database.Store
+}
+
+func (db scraperDB) InTransaction(fn func(tx scraper.Tx) error) error {
+	return db.Store.InTransaction(func(tx database.Store) error {
+		return fn(tx)
+	})
+}
+
+// Handler routes the requests to the handlers. The original routes are kept as they
+// were, for the clients that use them; new clients should use the ones under /api/v1.
+func (a *App) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range a.routes() {
+		mux.HandleFunc(route.Path, route.Handler)
+	}
+	return mux
+}
+
+// route is a path that Handler serves.
+type route struct {
+	Path    string
+	Handler http.HandlerFunc
+	// Unlisted is set for the routes that aren't in the OpenAPI spec: the web pages and
+	// what they load, the spec itself, and the answer to unknown paths under /api/v1.
+	Unlisted bool
+}
+
+// routes lists every path that Handler serves. All of them, except the unlisted ones,
+// must be in the OpenAPI spec.
+func (a *App) routes() []route {
+	auth := func(h http.HandlerFunc) http.HandlerFunc {
+		return checkAuth(h, a.Config.AuthUsername, a.Config.AuthPassword)
+	}
 	staticDir := filepath.Join(a.Config.AppBaseDir, "static")
-	fs := http.FileServer(http.Dir(staticDir))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	static := http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir)))
+
+	routes := []route{
+		{Path: "/", Handler: indexHandler(a.DB, a.Config.AppBaseDir, a.Config.ApiBaseURL, a.Config.AuthUsername, a.Config.AuthPassword), Unlisted: true},
+		{Path: "/login", Handler: auth(loginHandler()), Unlisted: true},
+		{Path: "/static/", Handler: static.ServeHTTP, Unlisted: true},
+		{Path: "/predict", Handler: auth(handlePrediction(a.DB, a.Scraper, a.Predictor, a.Config.DefaultCompetition))},
+		{Path: "/scrape", Handler: auth(handleScrape(a.Scraper))},
+		{Path: "/team_id", Handler: auth(handleTeamID(a.DB))},
+		{Path: "/standings", Handler: auth(handleStandings(a.DB, a.Config.DefaultCompetition))},
+		{Path: "/team_stats", Handler: auth(handleTeamStats(a.DB, a.Config.DefaultCompetition))},
+		{Path: "/export/", Handler: auth(handleExport(a.DB, a.Config.DefaultCompetition, a.Config.DBBackend == config.BackendSQLite))},
+		{Path: "/admin/cache", Handler: auth(handleCacheStats(a.Cache))},
+		{Path: "/admin/scrape_runs", Handler: auth(handleScrapeRuns(a.DB))},
+		{Path: "/admin/scrapes", Handler: auth(scrapeRunsPageHandler(a.DB, a.Config.AppBaseDir)), Unlisted: true},
+		{Path: "/admin/matches", Handler: auth(handleMatchRecords(a.DB, a.Config.DefaultCompetition))},
+		{Path: "/admin/matches/create", Handler: auth(handleCreateMatch(a.Editor, a.Config.DefaultCompetition))},
+		{Path: "/admin/matches/update", Handler: auth(handleUpdateMatch(a.Editor))},
+		{Path: "/admin/matches/delete", Handler: auth(handleDeleteMatch(a.Editor))},
+		{Path: "/admin/teams/create", Handler: auth(handleCreateTeam(a.Editor))},
+		{Path: "/admin/teams/rename", Handler: auth(handleRenameTeam(a.Editor))},
+		{Path: "/admin/teams/delete", Handler: auth(handleDeleteTeam(a.Editor))},
+		{Path: "/admin/teams/merge", Handler: auth(handleMergeTeams(a.Editor))},
+		{Path: "/admin/teams/duplicates", Handler: auth(handleSuspectedDuplicates(a.DB))},
+		{Path: "/admin/changes", Handler: auth(handleChanges(a.DB))},
+		{Path: "/admin/corrections", Handler: auth(correctionsPageHandler(a.DB, a.Config.AppBaseDir, a.Config.DefaultCompetition)), Unlisted: true},
+		{Path: "/health", Handler: readinessHandler(a.DB, a.checkSchema, a.Scraper)},
+		{Path: "/health/live", Handler: livenessHandler()},
+		{Path: "/health/ready", Handler: readinessHandler(a.DB, a.checkSchema, a.Scraper)},
+	}
+
+	for _, r := range a.apiRoutes() {
+		routes = append(routes, route{Path: r.Path, Handler: checkAPIAuth(allowMethod(r.Method, r.Handler), a.Config.AuthUsername, a.Config.AuthPassword)})
+	}
+	return append(routes,
+		route{Path: "/api/v1/", Handler: apiNotFound(), Unlisted: true},
+		route{Path: "/api/openapi.json", Handler: handleOpenAPISpec(), Unlisted: true},
+		route{Path: "/api/docs", Handler: apiDocsHandler(a.Config.AppBaseDir), Unlisted: true},
+	)
+}
+
+func (a *App) Run() {
+	a.scrapeInBackground()
+
+	if err := listenAndServe(a.Handler()); err != nil {
+		log.Fatal(err)
+	}
+}
 
+func listenAndServe(handler http.Handler) error {
 	port := "8080"
 	if envPort := os.Getenv("PORT"); envPort != "" {
 		port = envPort
 	}
 
 	fmt.Printf("Listening on port %s...\n", port)
-	err := http.ListenAndServe(":"+port, nil)
-	if err != nil {
-		log.Fatal(err)
+	return http.ListenAndServe(":"+port, handler)
+}
+
+func (a *App) scrapeInBackground() {
+	if a.Config.ScrapeInterval > 0 && len(a.Scraper.Sources) > 0 {
+		go a.scrapeEvery(a.Config.ScrapeInterval)
+	}
+}
+
+// scrapeEvery scrapes in the background, so that the matches are up to date before
+// anyone asks for a prediction. Scrape itself skips sources that were already scraped
+// today.
+func (a *App) scrapeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := a.Scraper.Scrape(context.Background(), scraperun.Scheduler); err != nil {
+			log.Printf("Error: %s", err)
+		}
 	}
 }
 
 func checkAuth(h http.HandlerFunc, validUsername, validPassword string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		username, password, ok := r.BasicAuth()
-		log.Printf("Login attempt by %s: %v", username, ok)
-		log.Printf("Requested url: %s", r.URL.String())
-
-		if !ok || username != validUsername || password != validPassword {
+		if !authorized(r, validUsername, validPassword) {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
 			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
 			return
@@ -89,6 +298,14 @@ func checkAuth(h http.HandlerFunc, validUsername, validPassword string) http.Han
 	}
 }
 
+func authorized(r *http.Request, validUsername, validPassword string) bool {
+	username, password, ok := r.BasicAuth()
+	log.Printf("Login attempt by %s: %v", username, ok)
+	log.Printf("Requested url: %s", r.URL.String())
+
+	return ok && username == validUsername && password == validPassword
+}
+
 func loginHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -96,7 +313,7 @@ func loginHandler() http.HandlerFunc {
 	}
 }
 
-func indexHandler(db *database.DB, appBaseDir string, apiBaseURL string, validUsername, validPassword string) http.HandlerFunc {
+func indexHandler(db database.Store, appBaseDir string, apiBaseURL string, validUsername, validPassword string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		username, password, ok := r.BasicAuth()
 		isAuthenticated := ok && username == validUsername && password == validPassword
@@ -124,9 +341,16 @@ func indexHandler(db *database.DB, appBaseDir string, apiBaseURL string, validUs
 				return
 			}
 
+			competitions, err := db.FetchCompetitionsFromDB()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error fetching competitions: %v", err), http.StatusInternalServerError)
+				return
+			}
+
 			data := TemplateData{
-				ApiBaseURL: apiBaseURL,
-				Teams:      teams,
+				ApiBaseURL:   apiBaseURL,
+				Teams:        teams,
+				Competitions: competitions,
 			}
 
 			templateFile := filepath.Join(appBaseDir, "templates/main.html")
@@ -145,99 +369,379 @@ func indexHandler(db *database.DB, appBaseDir string, apiBaseURL string, validUs
 	}
 }
 
-func handlePrediction(s *scraper.ScrapeData, p predictor.Predictor) http.HandlerFunc {
+func handlePrediction(db database.Store, s *scraper.ScrapeData, p predictor.Predictor, defaultCompetition string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		homeTeamIDStr := r.URL.Query().Get("home_team_id")
-		awayTeamIDStr := r.URL.Query().Get("away_team_id")
+		req, err := api.ParsePredictRequest(r.URL.Query())
+		if err != nil {
+			writeTextError(w, err)
+			return
+		}
 
-		if homeTeamIDStr == "" || awayTeamIDStr == "" {
-			http.Error(w, "Both home_team_id and away_team_id are required.", http.StatusBadRequest)
+		_, prediction, err := predict(r.Context(), db, s, p, defaultCompetition, req)
+		if err != nil {
+			writeTextError(w, err)
 			return
 		}
 
-		homeTeamID, err := strconv.Atoi(homeTeamIDStr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(prediction)
+	}
+}
+
+func handleScrape(s *scraper.ScrapeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Anything but true was always a real scrape here
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+		report, err := scrape(r.Context(), s, api.ScrapeRequest{DryRun: dryRun})
 		if err != nil {
-			http.Error(w, "Invalid home_team_id.", http.StatusBadRequest)
+			writeTextError(w, err)
+			return
+		}
+
+		if report != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(api.NewDryRunReport(report))
 			return
 		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
 
-		awayTeamID, err := strconv.Atoi(awayTeamIDStr)
+func handleTeamID(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParseTeamIDRequest(r.URL.Query())
 		if err != nil {
-			http.Error(w, "Invalid away_team_id.", http.StatusBadRequest)
+			writeTextError(w, err)
 			return
 		}
 
-		err = s.Scrape()
+		teamID, err := lookupTeamID(db, req)
 		if err != nil {
-			log.Printf("Error: %s", err)
-			http.Error(w, "Error while scraping data.", http.StatusInternalServerError)
+			writeTextError(w, err)
 			return
 		}
 
-		prediction, err := p.Predict(homeTeamID, awayTeamID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"team_id": teamID})
+	}
+}
+
+func handleMergeTeams(editor *admin.Editor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		sourceIDStr := r.FormValue("source_id")
+		targetIDStr := r.FormValue("target_id")
+
+		if sourceIDStr == "" || targetIDStr == "" {
+			http.Error(w, "Both source_id and target_id are required.", http.StatusBadRequest)
+			return
+		}
+
+		sourceID, err := strconv.Atoi(sourceIDStr)
 		if err != nil {
-			log.Printf("Error: %s", err)
-			http.Error(w, "Error while generating prediction.", http.StatusInternalServerError)
+			http.Error(w, "Invalid source_id.", http.StatusBadRequest)
 			return
 		}
 
-		log.Printf("Prediction for home_team_id=%d, away_team_id=%d: %d - %d", homeTeamID, awayTeamID, prediction.HomeGoals, prediction.AwayGoals)
+		targetID, err := strconv.Atoi(targetIDStr)
+		if err != nil {
+			http.Error(w, "Invalid target_id.", http.StatusBadRequest)
+			return
+		}
+
+		if err := editor.MergeTeams(username(r), r.FormValue("reason"), sourceID, targetID); err != nil {
+			writeCorrectionError(w, err, "merging teams")
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(prediction)
+		json.NewEncoder(w).Encode(map[string]int{"team_id": targetID})
 	}
 }
 
-func handleScrape(s *scraper.ScrapeData) http.HandlerFunc {
+func handleStandings(db database.Store, defaultCompetition string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := api.ParseStandingsRequest(r.URL.Query())
+		if err != nil {
+			writeTextError(w, err)
+			return
+		}
+
+		s, err := getStandings(db, defaultCompetition, req)
+		if err != nil {
+			writeTextError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newStandings(s))
+	}
+}
+
+// handleTeamStats returns the statistics of all teams in a season, which defaults to the
+// current one.
+func handleTeamStats(db database.Store, defaultCompetition string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := s.Scrape()
+		req, err := api.ParseTeamStatsRequest(r.URL.Query())
+		if err != nil {
+			writeTextError(w, err)
+			return
+		}
+
+		stats, err := getTeamStats(db, defaultCompetition, req)
+		if err != nil {
+			writeTextError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.NewTeamStats(stats))
+	}
+}
+
+func handleSuspectedDuplicates(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		threshold := team.DefaultSimilarityThreshold
+		if thresholdStr := r.URL.Query().Get("threshold"); thresholdStr != "" {
+			var err error
+			threshold, err = strconv.ParseFloat(thresholdStr, 64)
+			if err != nil || threshold < 0 || threshold > 1 {
+				http.Error(w, "Invalid threshold.", http.StatusBadRequest)
+				return
+			}
+		}
+
+		teams, err := db.FetchTeamsFromDB()
 		if err != nil {
 			log.Printf("Error: %s", err)
-			http.Error(w, "Error while scraping data.", http.StatusInternalServerError)
+			http.Error(w, "Error while fetching teams.", http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("Scraping complete")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.NewDuplicateCandidates(team.SuspectedDuplicates(teams, threshold)))
+	}
+}
 
-		w.WriteHeader(http.StatusOK)
+// handleExport streams the dataset in the path, such as /export/matches, as CSV, JSON
+// Lines or a SQLite file. If buffer is set, it writes the export to a temporary file
+// first, so that a slow client doesn't hold on to the only connection of a SQLite
+// database.
+func handleExport(db database.Store, defaultCompetition string, buffer bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dataset := strings.TrimPrefix(r.URL.Path, "/export/")
+		if !export.IsDataset(dataset) {
+			http.Error(w, "Unknown dataset, expected matches, teams or standings.", http.StatusNotFound)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = export.CSV
+		}
+		if !export.IsFormat(format) {
+			http.Error(w, "Invalid format, expected csv, jsonl or sqlite.", http.StatusBadRequest)
+			return
+		}
+
+		filter, err := export.ParseFilter(db, r.URL.Query(), defaultCompetition)
+		var filterErr *export.FilterError
+		if errors.As(err, &filterErr) {
+			http.Error(w, filterErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Error while preparing export.", http.StatusInternalServerError)
+			return
+		}
+
+		if buffer {
+			writeBufferedExport(w, db, dataset, format, filter)
+			return
+		}
+
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(dataset, format)))
+
+		out := &writeTracker{w: w}
+		if err := export.Write(out, db, dataset, format, filter); err != nil {
+			// Once rows are streamed, the status can't change anymore; the client gets a
+			// truncated file
+			if !out.written {
+				w.Header().Del("Content-Disposition")
+				exportError(w, err)
+			} else {
+				log.Printf("Error: %s", err)
+			}
+		}
 	}
 }
 
-func handleTeamID(db *database.DB) http.HandlerFunc {
+func writeBufferedExport(w http.ResponseWriter, db database.Store, dataset, format string, filter export.Filter) {
+	f, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		log.Printf("Error: %s", err)
+		http.Error(w, "Error while exporting.", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := export.Write(f, db, dataset, format, filter); err != nil {
+		exportError(w, err)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Error: %s", err)
+		http.Error(w, "Error while exporting.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(dataset, format)))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("Error: %s", err)
+	}
+}
+
+func exportError(w http.ResponseWriter, err error) {
+	var filterErr *export.FilterError
+	if errors.As(err, &filterErr) {
+		http.Error(w, filterErr.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Error: %s", err)
+	http.Error(w, "Error while exporting.", http.StatusInternalServerError)
+}
+
+// writeTracker remembers whether anything was written to the response.
+type writeTracker struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
+
+const defaultLimit = 50
+
+// parseLimit reads the number of items to list from the limit parameter.
+func parseLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit %q", limitStr)
+	}
+	return limit, nil
+}
+
+// handleScrapeRuns lists the most recent scrape runs, newest first.
+func handleScrapeRuns(db database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := parseLimit(r)
+		if err != nil {
+			http.Error(w, "Invalid limit.", http.StatusBadRequest)
+			return
+		}
+
+		runs, err := db.GetScrapeRuns(limit)
+		if err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Error while fetching scrape runs.", http.StatusInternalServerError)
+			return
+		}
+		if runs == nil {
+			runs = []scraperun.Run{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runs)
+	}
+}
+
+func scrapeRunsPageHandler(db database.Store, appBaseDir string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		teamName := r.URL.Query().Get("team_name")
+		limit, err := parseLimit(r)
+		if err != nil {
+			http.Error(w, "Invalid limit.", http.StatusBadRequest)
+			return
+		}
 
-		if teamName == "" {
-			http.Error(w, "team_name is required.", http.StatusBadRequest)
+		runs, err := db.GetScrapeRuns(limit)
+		if err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Error while fetching scrape runs.", http.StatusInternalServerError)
 			return
 		}
 
-		teamID, err := db.GetTeamID(teamName)
+		templateFile := filepath.Join(appBaseDir, "templates/scrape_runs.html")
+		tmpl, err := template.ParseFiles(templateFile)
 		if err != nil {
-			http.Error(w, "Error while fetching team ID.", http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error parsing template: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		if teamID == 0 {
-			http.Error(w, "Team not found.", http.StatusNotFound)
+		err = tmpl.Execute(w, runs)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error executing template: %v", err), http.StatusInternalServerError)
 			return
 		}
+	}
+}
 
+func handleCacheStats(c *cache.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"team_id": teamID})
+		json.NewEncoder(w).Encode(c.Stats())
 	}
 }
 
-func healthCheckHandler(db *database.DB) http.HandlerFunc {
+// livenessHandler tells that the app is running, even when it can't reach the database,
+// so that it isn't restarted while the database is down.
+func livenessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check the database connection
-		if err := db.Conn.Ping(); err != nil {
-			// If there is an error, return a 500 Internal Server Error status code
-			http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// readinessHandler tells whether the app can serve requests: the database must be
+// reachable and fully migrated.
+func readinessHandler(db database.Store, checkSchema func() error, scraped *scraper.ScrapeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := db.Ping(); err != nil {
+			log.Printf("Error: %s", err)
+			http.Error(w, "Database connection failed", http.StatusServiceUnavailable)
+			return
+		}
+
+		if checkSchema != nil {
+			if err := checkSchema(); err != nil {
+				log.Printf("Error: %s", err)
+				http.Error(w, "Database schema is not up to date", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		// A failing scraper doesn't make the app unusable, since predictions are based on
+		// the matches that are already stored, so it doesn't affect the status code
+		if health := scraped.Health(); health.Failing() {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "DEGRADED: scrape failed at %s: %v", health.LastFailure.Format(time.RFC3339), health.LastError)
 			return
 		}
 
-		// If everything is fine, return a 200 OK status code
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}
@@ -0,0 +1,274 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jqno/balGPT/internal/api"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecListsEveryRoute(t *testing.T) {
+	spec, err := api.LoadSpec()
+	require.NoError(t, err)
+
+	a, err := NewDemoApp(&config.Config{DefaultCompetition: "Eredivisie"})
+	require.NoError(t, err)
+
+	listed := []string{}
+	for _, route := range a.routes() {
+		if route.Unlisted {
+			continue
+		}
+		listed = append(listed, route.Path)
+
+		inSpec := false
+		for path := range spec.Paths {
+			inSpec = inSpec || serves(route.Path, path)
+		}
+		assert.True(t, inSpec, "%s is not in the spec", route.Path)
+	}
+
+	for path := range spec.Paths {
+		routed := false
+		for _, route := range listed {
+			routed = routed || serves(route, path)
+		}
+		assert.True(t, routed, "%s is in the spec, but not routed", path)
+	}
+
+	for _, route := range a.apiRoutes() {
+		operations := spec.Paths[route.Path]
+		assert.Len(t, operations, 1, route.Path)
+		assert.Contains(t, operations, strings.ToLower(route.Method), route.Path)
+	}
+}
+
+// serves tells whether a route serves a path in the spec. Like in http.ServeMux, a route
+// that ends in a slash serves all paths below it.
+func serves(route, path string) bool {
+	return route == path || strings.HasSuffix(route, "/") && strings.HasPrefix(path, route)
+}
+
+// setups prepare the demo data for the endpoints whose examples can't succeed on it:
+// only a team without matches can be deleted or merged into another one.
+var setups = map[string]func(t *testing.T, server *httptest.Server, form url.Values){
+	"POST /admin/teams/delete": func(t *testing.T, server *httptest.Server, form url.Values) {
+		form.Set("id", createTeam(t, server))
+	},
+	"POST /admin/teams/merge": func(t *testing.T, server *httptest.Server, form url.Values) {
+		form.Set("source_id", createTeam(t, server))
+	},
+}
+
+// TestHandlersAnswerLikeTheSpecSays calls every endpoint in the spec with the examples of
+// its required parameters and form fields, and checks that the status is documented and
+// that the body fits the schema. Endpoints that need credentials must reject requests
+// without them, and those under /api/v1 and the admin forms must reject other methods.
+func TestHandlersAnswerLikeTheSpecSays(t *testing.T) {
+	spec, err := api.LoadSpec()
+	require.NoError(t, err)
+
+	for _, e := range spec.Endpoints() {
+		t.Run(e.Method+" "+e.Path, func(t *testing.T) {
+			// A fresh server for every endpoint, so that the corrections don't affect
+			// each other
+			server := newTestServer(t)
+
+			path := e.Path
+			query := url.Values{}
+			for _, p := range e.Parameters {
+				if !p.Required {
+					continue
+				}
+				require.NotNil(t, p.Example, "required parameter %s has no example", p.Name)
+				if p.In == "path" {
+					path = strings.Replace(path, "{"+p.Name+"}", fmt.Sprint(p.Example), 1)
+				} else {
+					query.Set(p.Name, fmt.Sprint(p.Example))
+				}
+			}
+
+			var form url.Values
+			if schema := e.RequestBody.Form(); schema != nil {
+				form = url.Values{}
+				for _, name := range schema.Required {
+					field := schema.Properties[name]
+					require.NotNil(t, field.Example, "required form field %s has no example", name)
+					form.Set(name, fmt.Sprint(field.Example))
+				}
+			}
+			if setup, ok := setups[e.Method+" "+e.Path]; ok {
+				setup(t, server, form)
+			}
+
+			resp := send(t, server, e.Method, path+"?"+query.Encode(), form, true)
+			checkResponse(t, spec, e, resp)
+			assert.Less(t, resp.StatusCode, 300)
+
+			if e.Security == nil {
+				resp := send(t, server, e.Method, path, form, false)
+				assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+				checkResponse(t, spec, e, resp)
+			}
+
+			if strings.HasPrefix(e.Path, "/api/v1/") {
+				resp := send(t, server, http.MethodDelete, path, nil, true)
+				assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+				checkResponse(t, spec, e, resp)
+			}
+			if form != nil {
+				resp := send(t, server, http.MethodGet, path, nil, true)
+				assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+				checkResponse(t, spec, e, resp)
+			}
+		})
+	}
+}
+
+// send makes a request, which posts the form if it isn't nil.
+func send(t *testing.T, server *httptest.Server, method, path string, form url.Values, auth bool) *http.Response {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, server.URL+path, body)
+	require.NoError(t, err)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if auth {
+		req.SetBasicAuth("admin", "secret")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// createTeam adds a team without matches, and returns its ID.
+func createTeam(t *testing.T, server *httptest.Server) string {
+	form := url.Values{"name": {"Jong AZ"}, "reason": {"Promoted."}}
+	resp := send(t, server, http.MethodPost, "/admin/teams/create", form, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var body map[string]int
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return strconv.Itoa(body["team_id"])
+}
+
+func TestOpenAPISpecAndDocsAreServed(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var doc map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+}
+
+func checkResponse(t *testing.T, spec *api.Spec, e api.Endpoint, resp *http.Response) {
+	t.Helper()
+
+	response, ok := e.Responses[strconv.Itoa(resp.StatusCode)]
+	if !assert.True(t, ok, "status %d is not documented", resp.StatusCode) {
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if len(response.Content) == 0 {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	media, ok := response.Content[mediaType]
+	if !assert.True(t, ok, "status %d has undocumented content type %s", resp.StatusCode, mediaType) {
+		return
+	}
+	if mediaType != "application/json" {
+		return
+	}
+
+	var value interface{}
+	require.NoError(t, json.Unmarshal(body, &value))
+	checkSchema(t, spec, media.Schema, value, "body")
+}
+
+// checkSchema checks that a decoded JSON value fits the schema: objects must have the
+// required properties, and no others.
+func checkSchema(t *testing.T, spec *api.Spec, schema *api.Schema, value interface{}, path string) {
+	schema = spec.Schema(schema)
+	require.NotNil(t, schema, "%s refers to an unknown schema", path)
+
+	if value == nil {
+		assert.True(t, schema.Nullable, "%s is null", path)
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !assert.True(t, ok, "%s is not an object", path) {
+			return
+		}
+		for _, name := range schema.Required {
+			assert.Contains(t, object, name, "%s lacks %s", path, name)
+		}
+		if schema.Properties == nil {
+			return
+		}
+		for name, v := range object {
+			property, ok := schema.Properties[name]
+			if assert.True(t, ok, "%s has undocumented property %s", path, name) {
+				checkSchema(t, spec, property, v, path+"."+name)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if assert.True(t, ok, "%s is not an array", path) {
+			for i, item := range items {
+				checkSchema(t, spec, schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		assert.True(t, ok && n == float64(int64(n)), "%s is not an integer", path)
+	case "string":
+		s, ok := value.(string)
+		if assert.True(t, ok, "%s is not a string", path) && len(schema.Enum) > 0 {
+			assert.Contains(t, schema.Enum, s, path)
+		}
+	case "number":
+		_, ok := value.(float64)
+		assert.True(t, ok, "%s is not a number", path)
+	case "boolean":
+		_, ok := value.(bool)
+		assert.True(t, ok, "%s is not a boolean", path)
+	}
+}
+
+// The docs page needs the templates, which are two directories up.
+func TestAPIDocsPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	apiDocsHandler("../..")(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "GET /api/v1/predict")
+	assert.Contains(t, rec.Body.String(), `id="Prediction"`)
+	assert.Contains(t, rec.Body.String(), "<code>source_id</code>")
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jqno/balGPT/internal/config"
+)
+
+// startupRetryPause is the pause between two attempts to start the app, after
+// connecting to the database timed out.
+const startupRetryPause = 10 * time.Second
+
+// Serve starts the web server right away, and connects to and migrates the database in
+// the background. Until that's done, /health/live answers, /health/ready fails and the
+// other routes answer that the app is starting up.
+func Serve(cfg *config.Config) error {
+	starting := &startingHandler{}
+
+	go func() {
+		for {
+			a, err := NewApp(cfg)
+			if err == nil {
+				a.scrapeInBackground()
+				starting.ready(a.Handler())
+				return
+			}
+
+			log.Printf("Error starting app: %v", err)
+			time.Sleep(startupRetryPause)
+		}
+	}()
+
+	return listenAndServe(starting)
+}
+
+// startingHandler answers the requests with handler once the app has started.
+type startingHandler struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+func (s *startingHandler) ready(handler http.Handler) {
+	s.handler.Store(&handler)
+}
+
+func (s *startingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler := s.handler.Load(); handler != nil {
+		(*handler).ServeHTTP(w, r)
+		return
+	}
+
+	if r.URL.Path == "/health/live" {
+		livenessHandler()(w, r)
+		return
+	}
+	http.Error(w, "Starting up", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartingAppIsLiveButNotReady(t *testing.T) {
+	starting := &startingHandler{}
+
+	for path, status := range map[string]int{
+		"/health/live":  http.StatusOK,
+		"/health/ready": http.StatusServiceUnavailable,
+		"/health":       http.StatusServiceUnavailable,
+		"/api/v1/teams": http.StatusServiceUnavailable,
+	} {
+		w := httptest.NewRecorder()
+		starting.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, w.Code, path)
+	}
+
+	starting.ready(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	w := httptest.NewRecorder()
+	starting.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
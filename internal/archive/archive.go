@@ -0,0 +1,21 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Page is a downloaded HTML page, identified by the hash of its contents.
+type Page struct {
+	Hash        string
+	URL         string
+	Competition string
+	Body        []byte
+	FetchedAt   time.Time
+}
+
+func Hash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
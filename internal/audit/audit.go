@@ -0,0 +1,38 @@
+// Package audit defines the record that is kept of every correction that an admin makes.
+package audit
+
+import "time"
+
+// Entity is the kind of thing that was changed.
+type Entity string
+
+const (
+	MatchEntity Entity = "match"
+	TeamEntity  Entity = "team"
+)
+
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	// Merge means that the team was merged into another one. Before is the team that was
+	// merged, and After the team that it was merged into.
+	Merge Action = "merge"
+)
+
+// Change is a single correction.
+type Change struct {
+	ID        int       `json:"id"`
+	ChangedAt time.Time `json:"changed_at"`
+	User      string    `json:"user"`
+	Reason    string    `json:"reason"`
+	Entity    Entity    `json:"entity"`
+	EntityID  int       `json:"entity_id"`
+	Action    Action    `json:"action"`
+	// Before and After are the entity as JSON. Before is empty when it was created, and
+	// After when it was deleted.
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/jqno/balGPT/internal/scraper"
+)
+
+func backfill(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	competition := flags.String("competition", cfg.DefaultCompetition, "competition the seasons belong to")
+	urlTemplate := flags.String("url-template", "", "URL of a season's results page, with "+scraper.SeasonPlaceholder+" in place of the season, e.g. .../programma-uitslagen/"+scraper.SeasonPlaceholder)
+	from := flags.Int("from", 0, "year in which the first season starts")
+	to := flags.Int("to", 0, "year in which the last season starts (defaults to -from)")
+	delay := flags.Duration("delay", 10*time.Second, "time to wait between requests")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *urlTemplate == "" || *from == 0 {
+		return errors.New("both -url-template and -from are required")
+	}
+	if *to == 0 {
+		*to = *from
+	}
+
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		return err
+	}
+	return a.Scraper.Backfill(*competition, *urlTemplate, *from, *to, *delay)
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+)
+
+const usage = `Usage: balGPT [command] [flags]
+
+Commands:
+  serve     Start the web server (default); with -demo, on demo data in memory
+  scrape    Scrape all sources, or report what would change with -dry-run
+  reparse   Re-run the parser over archived pages
+  backfill  Scrape the results of past seasons
+  export    Export matches, teams or standings as CSV, JSON Lines or SQLite
+  migrate   Manage the database schema: up, down N, status or force V`
+
+// Run executes the command given on the command line.
+func Run(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return serve(cfg, args)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(cfg, args[1:])
+	case "-demo", "--demo":
+		return serve(cfg, args)
+	case "scrape":
+		return scrape(cfg, args[1:])
+	case "reparse":
+		return reparse(cfg, args[1:])
+	case "backfill":
+		return backfill(cfg, args[1:])
+	case "export":
+		return exportData(cfg, args[1:])
+	case "migrate":
+		return migrate(cfg, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+func serve(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	demo := flags.Bool("demo", false, "serve demo data from memory, without a database or scraping")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if !*demo {
+		return app.Serve(cfg)
+	}
+
+	a, err := app.NewDemoApp(cfg)
+	if err != nil {
+		return err
+	}
+	log.Println("Serving demo data; nothing is stored or scraped")
+	a.Run()
+	return nil
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/jqno/balGPT/internal/export"
+)
+
+func exportData(cfg *config.Config, args []string) error {
+	if len(args) == 0 || !export.IsDataset(args[0]) {
+		return fmt.Errorf("usage: export matches|teams|standings [flags]")
+	}
+	dataset := args[0]
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", export.CSV, "csv, jsonl or sqlite")
+	output := flags.String("o", "", "file to write to (defaults to standard output, except for sqlite)")
+	competitionID := flags.String("competition-id", "", "competition to export, or all (defaults to the default competition)")
+	team := flags.String("team", "", "only export this team, by name or ID")
+	seasonName := flags.String("season", "", "only export this season, such as 2022-2023")
+	from := flags.String("from", "", "only export from this date (YYYY-MM-DD)")
+	to := flags.String("to", "", "only export until this date (YYYY-MM-DD)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if !export.IsFormat(*format) {
+		return fmt.Errorf("invalid -format %q, expected csv, jsonl or sqlite", *format)
+	}
+	if *format == export.SQLite && *output == "" {
+		*output = export.FileName(dataset, *format)
+	}
+
+	query := url.Values{}
+	query.Set("competition_id", *competitionID)
+	query.Set("team", *team)
+	query.Set("season", *seasonName)
+	query.Set("from", *from)
+	query.Set("to", *to)
+
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		return err
+	}
+	filter, err := export.ParseFilter(a.DB, query, cfg.DefaultCompetition)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return export.Write(w, a.DB, dataset, *format, filter)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/jqno/balGPT/internal/database"
+)
+
+const migrateUsage = `Usage: balGPT migrate [subcommand]
+
+Subcommands:
+  up        Apply all migrations that haven't been applied yet
+  down N    Roll back the last N migrations
+  status    Show the current and the latest version
+  force V   Set the version to V without migrating, after fixing a failed migration`
+
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand\n\n%s", migrateUsage)
+	}
+
+	var run func(m *database.Migrations) error
+	switch args[0] {
+	case "up":
+		run = func(m *database.Migrations) error {
+			return m.Up()
+		}
+	case "down":
+		n, err := numberArg(args, "down")
+		if err != nil {
+			return err
+		}
+		run = func(m *database.Migrations) error {
+			return m.Down(n)
+		}
+	case "status":
+		run = printMigrationStatus
+	case "force":
+		version, err := numberArg(args, "force")
+		if err != nil {
+			return err
+		}
+		run = func(m *database.Migrations) error {
+			return m.Force(version)
+		}
+	case "help", "-h", "--help":
+		fmt.Println(migrateUsage)
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand %q\n\n%s", args[0], migrateUsage)
+	}
+
+	migrations, conn, err := app.OpenMigrations(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer func() {
+		if err := migrations.Close(); err != nil {
+			log.Printf("Error closing migrations: %v", err)
+		}
+	}()
+
+	return run(migrations)
+}
+
+func printMigrationStatus(m *database.Migrations) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Version: %d\n", status.Version)
+	fmt.Printf("Latest:  %d\n", status.Latest)
+	if status.Dirty {
+		fmt.Println("The last migration failed; fix it by hand, then run: migrate force VERSION")
+	} else if status.Version < status.Latest {
+		fmt.Printf("%d migrations to apply\n", status.Latest-status.Version)
+	}
+	return nil
+}
+
+// numberArg parses the single number that the subcommand expects.
+func numberArg(args []string, subcommand string) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("usage: migrate %s N", subcommand)
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q: %v", args[1], err)
+	}
+	return n, nil
+}
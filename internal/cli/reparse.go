@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+)
+
+func reparse(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	sinceStr := flags.String("since", "", "only re-parse pages downloaded on or after this date (YYYY-MM-DD)")
+	rebuild := flags.Bool("rebuild", false, "delete stored matches in the date range of the archived pages before storing the re-parsed ones")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	since := time.Time{}
+	if *sinceStr != "" {
+		var err error
+		since, err = time.Parse("2006-01-02", *sinceStr)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %v", err)
+		}
+	}
+
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		return err
+	}
+	return a.Scraper.Reparse(since, *rebuild)
+}
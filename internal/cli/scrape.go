@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jqno/balGPT/internal/app"
+	"github.com/jqno/balGPT/internal/config"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraper"
+	"github.com/jqno/balGPT/internal/scraperun"
+)
+
+func scrape(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("scrape", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing to the database")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		return a.Scraper.Scrape(context.Background(), scraperun.CLI)
+	}
+
+	report, err := a.Scraper.DryRun(context.Background())
+	if err != nil {
+		return err
+	}
+
+	printDryRunReport(os.Stdout, report)
+	return nil
+}
+
+func printDryRunReport(w io.Writer, report *scraper.DryRunReport) {
+	for _, source := range report.Sources {
+		fmt.Fprintf(w, "%s (%s)\n", source.Competition, source.URL)
+
+		fmt.Fprintf(w, "  New teams: %d\n", len(source.NewTeams))
+		for _, team := range source.NewTeams {
+			fmt.Fprintf(w, "    - %s\n", team)
+		}
+
+		fmt.Fprintf(w, "  New matches: %d\n", len(source.NewMatches))
+		for _, m := range source.NewMatches {
+			fmt.Fprintf(w, "    - %s\n", formatMatch(m))
+		}
+
+		fmt.Fprintf(w, "  Score changes: %d\n", len(source.ScoreChanges))
+		for _, change := range source.ScoreChanges {
+			fmt.Fprintf(w, "    - %s (stored: %s)\n", formatMatch(change.Scraped), formatScore(change.Stored))
+		}
+
+		fmt.Fprintf(w, "  Unchanged: %d\n", source.Unchanged)
+
+		fmt.Fprintf(w, "  Skipped rows: %d\n", len(source.Skipped))
+		for _, reason := range source.Skipped {
+			fmt.Fprintf(w, "    - %s\n", reason)
+		}
+	}
+}
+
+func formatMatch(m match.Match) string {
+	return fmt.Sprintf("%s %s - %s %s", m.Date.Format("2006-01-02"), m.HomeTeam, m.AwayTeam, formatScore(m))
+}
+
+func formatScore(m match.Match) string {
+	if !m.HasScore() {
+		return string(m.Status)
+	}
+	return fmt.Sprintf("%d-%d", m.HomeGoals, m.AwayGoals)
+}
@@ -0,0 +1,6 @@
+package competition
+
+type Competition struct {
+	ID   int
+	Name string
+}
@@ -2,18 +2,48 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jqno/balGPT/internal/season"
+)
+
+const defaultCompetition = "Eredivisie"
+
+// The storage backends that can be selected with DB_BACKEND.
+const (
+	BackendPostgres = "postgres"
+	BackendSQLite   = "sqlite"
 )
 
+const defaultSQLitePath = "balgpt.db"
+
+const defaultStartupTimeout = 2 * time.Minute
+
 type Config struct {
+	DBBackend          string
 	DBConnectionString string
+	SQLitePath         string
+	AutoMigrate        bool
+	StartupTimeout     time.Duration
+	ScrapeInterval     time.Duration
 	AuthUsername       string
 	AuthPassword       string
-	ScraperURL         string
+	ScraperSources     []ScraperSource
+	DefaultCompetition string
+	SeasonBoundary     season.Boundary
 	ApiBaseURL         string
 	AppBaseDir         string
 }
 
+type ScraperSource struct {
+	Competition string
+	URL         string
+}
+
 func LoadConfig() *Config {
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
@@ -22,10 +52,34 @@ func LoadConfig() *Config {
 	dbName := os.Getenv("DB_NAME")
 	connectionString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPass, dbName)
 
+	dbBackend := parseDBBackend(os.Getenv("DB_BACKEND"))
+
+	sqlitePath := os.Getenv("SQLITE_PATH")
+	if sqlitePath == "" {
+		sqlitePath = defaultSQLitePath
+	}
+
+	autoMigrate := parseAutoMigrate(os.Getenv("AUTO_MIGRATE"))
+
+	startupTimeout := parseStartupTimeout(os.Getenv("DB_STARTUP_TIMEOUT"))
+
+	scrapeInterval := parseScrapeInterval(os.Getenv("SCRAPE_INTERVAL"))
+
 	authUsername := os.Getenv("AUTH_USERNAME")
 	authPassword := os.Getenv("AUTH_PASSWORD")
 
-	scraperURL := os.Getenv("SCRAPER_URL")
+	scraperSources := parseScraperSources(os.Getenv("SCRAPER_SOURCES"))
+	if len(scraperSources) == 0 && os.Getenv("SCRAPER_URL") != "" {
+		scraperSources = []ScraperSource{{Competition: defaultCompetition, URL: os.Getenv("SCRAPER_URL")}}
+	}
+
+	competition := defaultCompetition
+	if len(scraperSources) > 0 {
+		competition = scraperSources[0].Competition
+	}
+
+	seasonBoundary := parseSeasonBoundary(os.Getenv("SEASON_START"))
+
 	apiBaseURL := os.Getenv("API_BASE_URL")
 
 	appBaseDir := os.Getenv("APP_BASE_DIR")
@@ -34,11 +88,109 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
+		DBBackend:          dbBackend,
 		DBConnectionString: connectionString,
+		SQLitePath:         sqlitePath,
+		AutoMigrate:        autoMigrate,
+		StartupTimeout:     startupTimeout,
+		ScrapeInterval:     scrapeInterval,
 		AuthUsername:       authUsername,
 		AuthPassword:       authPassword,
-		ScraperURL:         scraperURL,
+		ScraperSources:     scraperSources,
+		DefaultCompetition: competition,
+		SeasonBoundary:     seasonBoundary,
 		ApiBaseURL:         apiBaseURL,
 		AppBaseDir:         appBaseDir,
 	}
 }
+
+// parseDBBackend returns the selected storage backend, and falls back to Postgres when
+// it's missing or unknown.
+func parseDBBackend(value string) string {
+	switch backend := strings.ToLower(strings.TrimSpace(value)); backend {
+	case "":
+		return BackendPostgres
+	case BackendPostgres, BackendSQLite:
+		return backend
+	default:
+		log.Printf("Ignoring DB_BACKEND: unknown backend %q", value)
+		return BackendPostgres
+	}
+}
+
+// parseAutoMigrate tells whether the app should migrate the database when it starts,
+// which it does unless AUTO_MIGRATE is false.
+func parseAutoMigrate(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	autoMigrate, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		log.Printf("Ignoring AUTO_MIGRATE: %v", err)
+		return true
+	}
+	return autoMigrate
+}
+
+// parseStartupTimeout parses how long the app keeps trying to connect to the database
+// when it starts, such as "90s", and falls back to the default when it's missing or
+// invalid.
+func parseStartupTimeout(value string) time.Duration {
+	if value == "" {
+		return defaultStartupTimeout
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || timeout <= 0 {
+		log.Printf("Ignoring DB_STARTUP_TIMEOUT: invalid duration %q", value)
+		return defaultStartupTimeout
+	}
+	return timeout
+}
+
+// parseScrapeInterval parses how often the app scrapes in the background, such as "1h".
+// It returns 0, which disables scheduled scrapes, when it's missing or invalid.
+func parseScrapeInterval(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	interval, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || interval < 0 {
+		log.Printf("Ignoring SCRAPE_INTERVAL: invalid duration %q", value)
+		return 0
+	}
+	return interval
+}
+
+// parseScraperSources parses a list of sources in the form
+// "Competition=URL;Other competition=URL".
+func parseScraperSources(value string) []ScraperSource {
+	sources := []ScraperSource{}
+	for _, entry := range strings.Split(value, ";") {
+		competition, url, found := strings.Cut(entry, "=")
+		competition = strings.TrimSpace(competition)
+		url = strings.TrimSpace(url)
+		if !found || competition == "" || url == "" {
+			continue
+		}
+		sources = append(sources, ScraperSource{Competition: competition, URL: url})
+	}
+	return sources
+}
+
+// parseSeasonBoundary parses the day on which seasons start, such as "08-01", and falls
+// back to the default when it's missing or invalid.
+func parseSeasonBoundary(value string) season.Boundary {
+	if value == "" {
+		return season.DefaultBoundary
+	}
+
+	boundary, err := season.ParseBoundary(value)
+	if err != nil {
+		log.Printf("Ignoring SEASON_START: %v", err)
+		return season.DefaultBoundary
+	}
+	return boundary
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScraperSources(t *testing.T) {
+	sources := parseScraperSources("Eredivisie=https://example.com/ere;Keuken Kampioen Divisie = https://example.com/kkd?a=b;invalid")
+
+	assert.Equal(t, []ScraperSource{
+		{Competition: "Eredivisie", URL: "https://example.com/ere"},
+		{Competition: "Keuken Kampioen Divisie", URL: "https://example.com/kkd?a=b"},
+	}, sources)
+}
+
+func TestParseDBBackend(t *testing.T) {
+	assert.Equal(t, BackendPostgres, parseDBBackend(""))
+	assert.Equal(t, BackendSQLite, parseDBBackend("SQLite"))
+	assert.Equal(t, BackendPostgres, parseDBBackend("mysql"))
+}
+
+func TestParseAutoMigrate(t *testing.T) {
+	assert.True(t, parseAutoMigrate(""))
+	assert.False(t, parseAutoMigrate("false"))
+	assert.True(t, parseAutoMigrate("1"))
+	assert.True(t, parseAutoMigrate("nope"))
+}
+
+func TestParseStartupTimeout(t *testing.T) {
+	assert.Equal(t, 90*time.Second, parseStartupTimeout("90s"))
+	assert.Equal(t, defaultStartupTimeout, parseStartupTimeout(""))
+	assert.Equal(t, defaultStartupTimeout, parseStartupTimeout("-1m"))
+	assert.Equal(t, defaultStartupTimeout, parseStartupTimeout("soon"))
+}
+
+func TestParseScrapeInterval(t *testing.T) {
+	assert.Equal(t, time.Hour, parseScrapeInterval("1h"))
+	assert.Equal(t, time.Duration(0), parseScrapeInterval(""))
+	assert.Equal(t, time.Duration(0), parseScrapeInterval("-1h"))
+	assert.Equal(t, time.Duration(0), parseScrapeInterval("hourly"))
+}
+
+func TestParseSeasonBoundary(t *testing.T) {
+	assert.Equal(t, season.Boundary{Month: time.July, Day: 15}, parseSeasonBoundary("07-15"))
+	assert.Equal(t, season.DefaultBoundary, parseSeasonBoundary(""))
+	assert.Equal(t, season.DefaultBoundary, parseSeasonBoundary("15 juli"))
+}
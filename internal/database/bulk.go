@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// stagingColumns are the columns of match_staging that copyMatches fills.
+var stagingColumns = []string{"seq", "external_id", "competition", "home_team", "away_team", "home_goals", "away_goals",
+	"date", "status", "kickoff", "half_time_home_goals", "half_time_away_goals"}
+
+// bulkMergeStatements returns the statements that merge the staged matches into the
+// competitions, teams and matches tables, in order. They resolve teams like lookupTeamID
+// does, and matches like findMatchID does. SQLite needs the WHERE before ON CONFLICT to
+// tell it apart from a join.
+func bulkMergeStatements(dialect Dialect) []string {
+	return []string{
+		`INSERT INTO competitions (name)
+			SELECT DISTINCT competition FROM match_staging WHERE true
+			ON CONFLICT (name) DO NOTHING`,
+
+		`INSERT INTO teams (name)
+			SELECT n.name FROM (
+				SELECT home_team AS name FROM match_staging
+				UNION
+				SELECT away_team FROM match_staging
+			) n
+			WHERE NOT EXISTS (SELECT 1 FROM team_aliases a WHERE a.alias = n.name)
+			ON CONFLICT (name) DO NOTHING`,
+
+		`UPDATE match_staging AS s SET
+			competition_id = (SELECT c.id FROM competitions c WHERE c.name = s.competition),
+			home_team_id = COALESCE(
+				(SELECT a.team_id FROM team_aliases a WHERE a.alias = s.home_team),
+				(SELECT t.id FROM teams t WHERE t.name = s.home_team)),
+			away_team_id = COALESCE(
+				(SELECT a.team_id FROM team_aliases a WHERE a.alias = s.away_team),
+				(SELECT t.id FROM teams t WHERE t.name = s.away_team))`,
+
+		`UPDATE match_staging AS s SET match_id = m.id
+			FROM matches m
+			WHERE s.external_id IS NOT NULL AND m.external_id = s.external_id`,
+
+		`UPDATE match_staging AS s SET match_id = m.id
+			FROM matches m
+			WHERE s.match_id IS NULL
+				AND m.home_team = s.home_team_id
+				AND m.away_team = s.away_team_id
+				AND m.date = s.date`,
+
+		// The same match can occur more than once, for instance when reparsing several
+		// versions of a page, or when it was rescheduled. InsertOrUpdateMatch would store
+		// the first one and update it with the next, so the last one wins, but it keeps
+		// the stored match and the external ID of the ones before it
+		`UPDATE match_staging AS b SET match_id = (
+				SELECT a.match_id FROM match_staging a
+				WHERE a.seq < b.seq AND a.match_id IS NOT NULL
+					AND (a.external_id = b.external_id
+						OR (a.home_team_id = b.home_team_id AND a.away_team_id = b.away_team_id AND a.date = b.date))
+				ORDER BY a.seq DESC LIMIT 1)
+			WHERE b.match_id IS NULL`,
+
+		`UPDATE match_staging AS b SET external_id = (
+				SELECT a.external_id FROM match_staging a
+				WHERE a.seq < b.seq AND a.external_id IS NOT NULL
+					AND a.home_team_id = b.home_team_id AND a.away_team_id = b.away_team_id AND a.date = b.date
+				ORDER BY a.seq DESC LIMIT 1)
+			WHERE b.external_id IS NULL`,
+
+		dialect.DeleteJoined("match_staging", "a", "match_staging b", `
+			a.seq < b.seq
+			AND (a.match_id = b.match_id
+				OR a.external_id = b.external_id
+				OR (a.home_team_id = b.home_team_id AND a.away_team_id = b.away_team_id AND a.date = b.date))`),
+
+		// Matches that were corrected by an admin are left alone
+		`DELETE FROM match_staging
+			WHERE match_id IN (SELECT id FROM matches WHERE corrected)`,
+
+		`UPDATE matches AS m SET
+				competition_id = s.competition_id, home_team = s.home_team_id, away_team = s.away_team_id,
+				home_goals = s.home_goals, away_goals = s.away_goals, date = s.date,
+				external_id = COALESCE(s.external_id, m.external_id), status = s.status, kickoff = s.kickoff,
+				half_time_home_goals = s.half_time_home_goals, half_time_away_goals = s.half_time_away_goals
+			FROM match_staging s
+			WHERE m.id = s.match_id`,
+
+		`INSERT INTO matches (competition_id, home_team, away_team, home_goals, away_goals, date,
+				external_id, status, kickoff, half_time_home_goals, half_time_away_goals)
+			SELECT competition_id, home_team_id, away_team_id, home_goals, away_goals, date,
+				external_id, status, kickoff, half_time_home_goals, half_time_away_goals
+			FROM match_staging
+			WHERE match_id IS NULL
+			ORDER BY seq`,
+
+		// Deleted teams that play a match again are brought back
+		`UPDATE teams SET deleted_at = NULL
+			WHERE deleted_at IS NOT NULL
+				AND id IN (SELECT home_team_id FROM match_staging UNION SELECT away_team_id FROM match_staging)`,
+	}
+}
+
+// BulkLoadMatches stores many matches at once, with the same result as calling
+// InsertOrUpdateMatch for each of them. The matches are copied into a temporary table
+// and merged from there with a handful of statements, instead of several round-trips
+// per match. It returns the number of matches that were stored.
+func (db *DB) BulkLoadMatches(matches []match.Match) (int64, error) {
+	if len(matches) == 0 {
+		return 0, nil
+	}
+
+	var stored int64
+	err := db.inTransaction(func(tx *DB) error {
+		_, err := tx.conn().Exec(`
+			CREATE TEMPORARY TABLE match_staging (
+				seq INTEGER NOT NULL,
+				external_id TEXT,
+				competition TEXT NOT NULL,
+				home_team TEXT NOT NULL,
+				away_team TEXT NOT NULL,
+				home_goals INTEGER,
+				away_goals INTEGER,
+				date DATE NOT NULL,
+				status VARCHAR(20) NOT NULL,
+				kickoff TIMESTAMP WITH TIME ZONE,
+				half_time_home_goals INTEGER,
+				half_time_away_goals INTEGER,
+				competition_id INTEGER,
+				home_team_id INTEGER,
+				away_team_id INTEGER,
+				match_id INTEGER
+			)`)
+		if err != nil {
+			return fmt.Errorf("Error creating staging table: %v", err)
+		}
+
+		if err := tx.copyMatches(matches); err != nil {
+			return fmt.Errorf("Error copying matches: %v", err)
+		}
+
+		for _, statement := range bulkMergeStatements(tx.dialect()) {
+			if _, err := tx.conn().Exec(statement); err != nil {
+				return fmt.Errorf("Error merging matches: %v", err)
+			}
+		}
+
+		if err := tx.conn().QueryRow("SELECT COUNT(*) FROM match_staging").Scan(&stored); err != nil {
+			return err
+		}
+
+		// Dropping the table explicitly allows another bulk load in the same transaction
+		_, err = tx.conn().Exec("DROP TABLE match_staging")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return stored, nil
+}
+
+func (db *DB) copyMatches(matches []match.Match) error {
+	rows := make([][]interface{}, len(matches))
+	for i, m := range matches {
+		var homeGoals, awayGoals sql.NullInt64
+		if m.HasScore() {
+			homeGoals = sql.NullInt64{Int64: int64(m.HomeGoals), Valid: true}
+			awayGoals = sql.NullInt64{Int64: int64(m.AwayGoals), Valid: true}
+		}
+
+		rows[i] = []interface{}{i, nullString(m.ExternalID), m.Competition, m.HomeTeam, m.AwayTeam, homeGoals, awayGoals,
+			dateString(m.Date), string(m.Status), db.nullTime(m.Kickoff), m.HalfTimeHomeGoals, m.HalfTimeAwayGoals}
+	}
+
+	return db.dialect().CopyRows(db.tx, "match_staging", stagingColumns, rows)
+}
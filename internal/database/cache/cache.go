@@ -0,0 +1,147 @@
+// Package cache keeps the statistics that predictions are based on, so that they aren't
+// computed again for every prediction.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jqno/balGPT/internal/database"
+)
+
+// Stats tells how well the cache is doing. Version is the data version of the cached
+// results.
+type Stats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+	Version int64 `json:"version"`
+}
+
+// Store is a read-through cache in front of another Store. Results are cached per query
+// and data version. Every change to the matches or teams, including a scrape and changes
+// by other instances of the app, starts a new version in the database, which throws away
+// everything that was cached.
+type Store struct {
+	database.Store
+
+	mu      sync.Mutex
+	version int64
+	entries map[string]interface{}
+	hits    int64
+	misses  int64
+}
+
+func New(store database.Store) *Store {
+	return &Store{Store: store, entries: map[string]interface{}{}}
+}
+
+func (c *Store) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{Hits: c.hits, Misses: c.misses, Entries: len(c.entries), Version: c.version}
+}
+
+// lookup returns the cached result of a query, or loads and caches it. The data version
+// is read before loading, so a result that was loaded while the data changed is thrown
+// away by the next lookup.
+func (c *Store) lookup(key string, load func() (interface{}, error)) (interface{}, error) {
+	version, err := c.Store.DataVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if version != c.version {
+		c.version = version
+		c.entries = map[string]interface{}{}
+	}
+	if value, ok := c.entries[key]; ok {
+		c.hits++
+		c.mu.Unlock()
+		return value, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.version == version {
+		c.entries[key] = value
+	}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+func (c *Store) AverageGoalsInLastMatches(competitionID, teamID, numberOfMatches int) (float64, error) {
+	key := fmt.Sprintf("AverageGoalsInLastMatches/%d/%d/%d", competitionID, teamID, numberOfMatches)
+	value, err := c.lookup(key, func() (interface{}, error) {
+		return c.Store.AverageGoalsInLastMatches(competitionID, teamID, numberOfMatches)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value.(float64), nil
+}
+
+type scores struct {
+	homeGoals int
+	awayGoals int
+}
+
+func (c *Store) LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error) {
+	key := fmt.Sprintf("LastYearMatchScores/%d/%d/%d", competitionID, homeTeamID, awayTeamID)
+	value, err := c.lookup(key, func() (interface{}, error) {
+		homeGoals, awayGoals, err := c.Store.LastYearMatchScores(competitionID, homeTeamID, awayTeamID)
+		return scores{homeGoals: homeGoals, awayGoals: awayGoals}, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	s := value.(scores)
+	return s.homeGoals, s.awayGoals, nil
+}
+
+// GetCurrentSeasonLeaderboard looks up the current season every time, because it
+// changes with the date, but caches the leaderboard itself.
+func (c *Store) GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error) {
+	currentSeason, err := c.Store.SeasonAt(competitionID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("GetCurrentSeasonLeaderboard/%d/%s", competitionID, currentSeason.Name)
+	return c.leaderboard(key, func() (map[int]int, error) {
+		return c.Store.GetCurrentSeasonLeaderboard(competitionID)
+	})
+}
+
+func (c *Store) GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error) {
+	key := fmt.Sprintf("GetLeaderboard/%d/%s/%s", competitionID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return c.leaderboard(key, func() (map[int]int, error) {
+		return c.Store.GetLeaderboard(competitionID, from, to)
+	})
+}
+
+func (c *Store) leaderboard(key string, fetch func() (map[int]int, error)) (map[int]int, error) {
+	value, err := c.lookup(key, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers get their own copy, so they can't change the cached one
+	points := make(map[int]int)
+	for teamID, p := range value.(map[int]int) {
+		points[teamID] = p
+	}
+	return points, nil
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/database/sqlite"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	from = time.Date(2022, 8, 1, 0, 0, 0, 0, dates.Amsterdam)
+	to   = time.Date(2023, 7, 31, 0, 0, 0, 0, dates.Amsterdam)
+)
+
+func played(home, away string, homeGoals, awayGoals int, day int) match.Match {
+	return match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    home,
+		AwayTeam:    away,
+		HomeGoals:   homeGoals,
+		AwayGoals:   awayGoals,
+		Date:        time.Date(2022, 9, day, 0, 0, 0, 0, dates.Amsterdam),
+		Status:      match.Played,
+	}
+}
+
+func TestLeaderboardIsCached(t *testing.T) {
+	db := memory.New()
+	db.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, 1))
+	c := New(db)
+
+	first, err := c.GetLeaderboard(1, from, to)
+	assert.NoError(t, err)
+	first[1] = 100
+
+	second, err := c.GetLeaderboard(1, from, to)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[int]int{1: 3}, second)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Entries: 1, Version: 1}, c.Stats())
+}
+
+func TestQueriesAreCachedSeparately(t *testing.T) {
+	c := New(memory.New())
+
+	c.AverageGoalsInLastMatches(1, 1, 5)
+	c.AverageGoalsInLastMatches(1, 2, 5)
+	c.LastYearMatchScores(1, 1, 2)
+	c.LastYearMatchScores(1, 1, 2)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 3, Entries: 3}, c.Stats())
+}
+
+func TestChangedMatchesInvalidateTheCache(t *testing.T) {
+	c := New(memory.New())
+	c.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, 1))
+
+	homeGoals, _, err := c.LastYearMatchScores(1, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, homeGoals)
+
+	c.InsertOrUpdateMatch(played("Ajax", "PSV", 4, 1, 8))
+
+	homeGoals, _, err = c.LastYearMatchScores(1, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, homeGoals)
+	assert.Equal(t, Stats{Hits: 0, Misses: 2, Entries: 1, Version: 2}, c.Stats())
+}
+
+func TestChangesByAnotherInstanceInvalidateTheCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balgpt.db")
+	db, err := sqlite.New(path, true)
+	require.NoError(t, err)
+	defer db.Close()
+	other, err := sqlite.New(path, false)
+	require.NoError(t, err)
+	defer other.Close()
+
+	require.NoError(t, db.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, 1)))
+	c := New(db)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+
+	leaderboard, err := c.GetLeaderboard(competitionID, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 3, leaderboard[ajax])
+
+	require.NoError(t, other.InsertOrUpdateMatch(played("PSV", "Ajax", 0, 1, 8)))
+
+	leaderboard, err = c.GetLeaderboard(competitionID, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 6, leaderboard[ajax])
+	assert.Equal(t, int64(0), c.Stats().Hits)
+}
+
+func TestTransactionThatChangesMatchesInvalidatesTheCache(t *testing.T) {
+	c := New(memory.New())
+	c.GetLeaderboard(1, from, to)
+
+	err := c.InTransaction(func(tx database.Store) error {
+		return tx.InTransaction(func(tx database.Store) error {
+			_, err := tx.BulkLoadMatches([]match.Match{played("Ajax", "PSV", 2, 1, 1)})
+			return err
+		})
+	})
+	assert.NoError(t, err)
+
+	leaderboard, err := c.GetLeaderboard(1, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 3}, leaderboard)
+	assert.Equal(t, int64(1), c.Stats().Version)
+}
+
+func TestTransactionWithoutChangesKeepsTheCache(t *testing.T) {
+	c := New(memory.New())
+	c.GetLeaderboard(1, from, to)
+
+	err := c.InTransaction(func(tx database.Store) error {
+		return tx.UpdateSourceRowCount("https://example.com", 10, time.Now())
+	})
+	assert.NoError(t, err)
+
+	c.GetLeaderboard(1, from, to)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Entries: 1}, c.Stats())
+}
+
+func TestErrorsAreNotCached(t *testing.T) {
+	c := New(failingStore{memory.New()})
+
+	_, err := c.GetLeaderboard(1, from, to)
+	assert.Error(t, err)
+
+	assert.Equal(t, Stats{Hits: 0, Misses: 1, Entries: 0}, c.Stats())
+}
+
+type failingStore struct {
+	*memory.DB
+}
+
+func (failingStore) GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error) {
+	return nil, errors.New("boom")
+}
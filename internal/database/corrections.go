@@ -0,0 +1,318 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// matchRecordColumns are the columns of a match.Record, in the order that
+// scanMatchRecord scans them.
+const matchRecordColumns = matchColumns + `, m.id, m.corrected, m.deleted_at`
+
+// GetMatchRecord returns a match, even if it was deleted, or ErrMatchNotFound.
+func (db *DB) GetMatchRecord(id int) (*match.Record, error) {
+	query := `SELECT ` + matchRecordColumns + ` FROM matches m ` + matchJoins + ` WHERE m.id = $1`
+
+	r, err := scanMatchRecord(db.conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrMatchNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("Error fetching match %d: %v", id, err)
+	}
+	return &r, nil
+}
+
+// GetMatchRecords returns the matches that the filter selects, including deleted ones,
+// in order of date.
+func (db *DB) GetMatchRecords(filter match.Filter) ([]match.Record, error) {
+	from, to := dateRange(filter)
+	query := `SELECT ` + matchRecordColumns + ` FROM matches m ` + matchJoins + `
+		WHERE ($1 = 0 OR m.competition_id = $1)
+			AND ($2 = 0 OR m.home_team = $2 OR m.away_team = $2)
+			AND m.date >= $3 AND m.date <= $4
+		ORDER BY m.date, m.id`
+
+	rows, err := db.conn().Query(query, filter.CompetitionID, filter.TeamID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []match.Record{}
+	for rows.Next() {
+		r, err := scanMatchRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+func scanMatchRecord(row scanner) (match.Record, error) {
+	var r match.Record
+	var deletedAt sql.NullTime
+	m, err := scanMatch(row, &r.ID, &r.Corrected, &deletedAt)
+	if err != nil {
+		return r, err
+	}
+
+	r.Match = m
+	r.DeletedAt = deletedAt.Time
+	return r, nil
+}
+
+// CreateMatch stores a match that an admin added. Like every corrected match, it's left
+// alone by the scraper.
+func (db *DB) CreateMatch(m match.Match) (int, error) {
+	var matchID int
+	err := db.inTransaction(func(tx *DB) error {
+		competitionID, homeTeamID, awayTeamID, err := tx.matchKeys(m)
+		if err != nil {
+			return err
+		}
+		if err := tx.checkDuplicateMatch(0, m.ExternalID, homeTeamID, awayTeamID, m.Date); err != nil {
+			return err
+		}
+
+		homeGoals, awayGoals := goals(m)
+		err = tx.conn().QueryRow(`
+			INSERT INTO matches (competition_id, home_team, away_team, home_goals, away_goals, date,
+				external_id, status, kickoff, half_time_home_goals, half_time_away_goals, corrected)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, TRUE)
+			RETURNING id`,
+			competitionID, homeTeamID, awayTeamID, homeGoals, awayGoals, dateString(m.Date),
+			nullString(m.ExternalID), string(m.Status), tx.nullTime(m.Kickoff), m.HalfTimeHomeGoals, m.HalfTimeAwayGoals).Scan(&matchID)
+		if err != nil {
+			return fmt.Errorf("Error creating match: %v", err)
+		}
+
+		return tx.restoreTeams(homeTeamID, awayTeamID)
+	})
+	return matchID, err
+}
+
+// UpdateMatch overwrites a match with a corrected version, which the scraper leaves
+// alone from then on.
+func (db *DB) UpdateMatch(id int, m match.Match) error {
+	return db.inTransaction(func(tx *DB) error {
+		competitionID, homeTeamID, awayTeamID, err := tx.matchKeys(m)
+		if err != nil {
+			return err
+		}
+		if err := tx.checkDuplicateMatch(id, m.ExternalID, homeTeamID, awayTeamID, m.Date); err != nil {
+			return err
+		}
+
+		homeGoals, awayGoals := goals(m)
+		result, err := tx.conn().Exec(`
+			UPDATE matches
+			SET competition_id = $2, home_team = $3, away_team = $4, home_goals = $5, away_goals = $6, date = $7,
+				external_id = $8, status = $9, kickoff = $10, half_time_home_goals = $11, half_time_away_goals = $12,
+				corrected = TRUE
+			WHERE id = $1`,
+			id, competitionID, homeTeamID, awayTeamID, homeGoals, awayGoals, dateString(m.Date),
+			nullString(m.ExternalID), string(m.Status), tx.nullTime(m.Kickoff), m.HalfTimeHomeGoals, m.HalfTimeAwayGoals)
+		if err != nil {
+			return fmt.Errorf("Error updating match %d: %v", id, err)
+		}
+		if err := expectRow(result, ErrMatchNotFound); err != nil {
+			return err
+		}
+
+		return tx.restoreTeams(homeTeamID, awayTeamID)
+	})
+}
+
+// DeleteMatch marks a match as deleted. It stays deleted, because the scraper leaves
+// corrected matches alone.
+func (db *DB) DeleteMatch(id int, deletedAt time.Time) error {
+	result, err := db.conn().Exec("UPDATE matches SET deleted_at = $2, corrected = TRUE WHERE id = $1 AND deleted_at IS NULL",
+		id, db.timestamp(deletedAt))
+	if err != nil {
+		return fmt.Errorf("Error deleting match %d: %v", id, err)
+	}
+	return expectRow(result, ErrMatchNotFound)
+}
+
+// matchKeys returns the IDs of the competition and teams of a match.
+func (db *DB) matchKeys(m match.Match) (int, int, int, error) {
+	competitionID, err := db.insertOrUpdateCompetition(m.Competition)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	homeTeamID, err := db.insertOrUpdateTeam(m.HomeTeam)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	awayTeamID, err := db.insertOrUpdateTeam(m.AwayTeam)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return competitionID, homeTeamID, awayTeamID, nil
+}
+
+// checkDuplicateMatch returns ErrDuplicate if a match other than exceptID has the same
+// external ID, or the same teams and date.
+func (db *DB) checkDuplicateMatch(exceptID int, externalID string, homeTeamID, awayTeamID int, date time.Time) error {
+	var matchID int
+	err := db.conn().QueryRow(`
+		SELECT id FROM matches
+		WHERE id <> $1 AND (external_id = $2 OR (home_team = $3 AND away_team = $4 AND date = $5))
+		LIMIT 1`,
+		exceptID, nullString(externalID), homeTeamID, awayTeamID, dateString(date)).Scan(&matchID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return ErrDuplicate
+}
+
+// CreateTeam adds a team, or brings back a deleted team with the same name.
+func (db *DB) CreateTeam(name string) (int, error) {
+	var teamID int
+	err := db.conn().QueryRow("SELECT team_id FROM team_aliases WHERE alias = $1", name).Scan(&teamID)
+	if err == nil {
+		return 0, ErrDuplicate
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = db.conn().QueryRow(`
+		INSERT INTO teams (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET deleted_at = NULL WHERE teams.deleted_at IS NOT NULL
+		RETURNING id`, name).Scan(&teamID)
+	if err == sql.ErrNoRows {
+		return 0, ErrDuplicate
+	} else if err != nil {
+		return 0, fmt.Errorf("Error creating team %s: %v", name, err)
+	}
+
+	return teamID, nil
+}
+
+// RenameTeam renames a team. The old name becomes an alias, so that the scraper still
+// recognizes the team.
+func (db *DB) RenameTeam(id int, name string) error {
+	return db.inTransaction(func(tx *DB) error {
+		var oldName string
+		err := tx.conn().QueryRow("SELECT name FROM teams WHERE id = $1 AND deleted_at IS NULL", id).Scan(&oldName)
+		if err == sql.ErrNoRows {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+		if oldName == name {
+			return nil
+		}
+
+		otherID, err := tx.lookupTeamID(name)
+		if err == nil && otherID != id {
+			return ErrDuplicate
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		statements := []struct {
+			query string
+			args  []interface{}
+		}{
+			{"DELETE FROM team_aliases WHERE alias = $1", []interface{}{name}},
+			{"UPDATE teams SET name = $2 WHERE id = $1", []interface{}{id, name}},
+			{"INSERT INTO team_aliases (alias, team_id) VALUES ($1, $2) ON CONFLICT (alias) DO UPDATE SET team_id = EXCLUDED.team_id", []interface{}{oldName, id}},
+		}
+		for _, statement := range statements {
+			if _, err := tx.conn().Exec(statement.query, statement.args...); err != nil {
+				return fmt.Errorf("Error renaming team %d to %s: %v", id, name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteTeam marks a team as deleted. Only teams without matches, other than deleted
+// ones, can be deleted; otherwise, it returns ErrTeamInUse.
+func (db *DB) DeleteTeam(id int, deletedAt time.Time) error {
+	return db.inTransaction(func(tx *DB) error {
+		var inUse bool
+		err := tx.conn().QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM matches WHERE (home_team = t.id OR away_team = t.id) AND deleted_at IS NULL)
+			FROM teams t
+			WHERE t.id = $1 AND t.deleted_at IS NULL`, id).Scan(&inUse)
+		if err == sql.ErrNoRows {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrTeamInUse
+		}
+
+		if _, err := tx.conn().Exec("UPDATE teams SET deleted_at = $2 WHERE id = $1", id, tx.timestamp(deletedAt)); err != nil {
+			return fmt.Errorf("Error deleting team %d: %v", id, err)
+		}
+		return nil
+	})
+}
+
+const changeColumns = `changed_at, username, reason, entity, entity_id, action, COALESCE(old_value, ''), COALESCE(new_value, '')`
+
+// RecordChange adds a correction to the audit log.
+func (db *DB) RecordChange(c audit.Change) error {
+	_, err := db.conn().Exec(`
+		INSERT INTO audit_log (changed_at, username, reason, entity, entity_id, action, old_value, new_value)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		db.timestamp(c.ChangedAt), c.User, c.Reason, string(c.Entity), c.EntityID, string(c.Action), nullString(c.Before), nullString(c.After))
+	if err != nil {
+		return fmt.Errorf("Error recording change to %s %d: %v", c.Entity, c.EntityID, err)
+	}
+	return nil
+}
+
+// GetChanges returns the most recent corrections, newest first.
+func (db *DB) GetChanges(limit int) ([]audit.Change, error) {
+	rows, err := db.conn().Query(`SELECT id, `+changeColumns+` FROM audit_log ORDER BY changed_at DESC, id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []audit.Change{}
+	for rows.Next() {
+		var c audit.Change
+		err := rows.Scan(&c.ID, &c.ChangedAt, &c.User, &c.Reason, &c.Entity, &c.EntityID, &c.Action, &c.Before, &c.After)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// goals returns the score of a match, which is NULL if it has none.
+func goals(m match.Match) (sql.NullInt64, sql.NullInt64) {
+	if !m.HasScore() {
+		return sql.NullInt64{}, sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(m.HomeGoals), Valid: true}, sql.NullInt64{Int64: int64(m.AwayGoals), Valid: true}
+}
+
+// expectRow returns notFound if the statement didn't affect any rows.
+func expectRow(result sql.Result, notFound error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -2,56 +2,252 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/competition"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/season"
 	"github.com/jqno/balGPT/internal/team"
+	"github.com/jqno/balGPT/internal/teamstats"
 	_ "github.com/lib/pq"
 )
 
+var ErrTeamNotFound = errors.New("team not found")
+
+var ErrMatchNotFound = errors.New("match not found")
+
+// ErrDuplicate means that a correction would make a match or team the same as another
+// one.
+var ErrDuplicate = errors.New("already exists")
+
+// ErrTeamInUse means that a team can't be deleted because it still has matches.
+var ErrTeamInUse = errors.New("team still has matches")
+
+// scrapeLockID identifies the advisory lock that is held while scraping.
+const scrapeLockID = 8462
+
+// DB is the Store that keeps everything in Postgres, or in SQLite with the Dialect of
+// package sqlite.
 type DB struct {
 	Conn *sql.DB
+	// Dialect covers the differences between databases. It defaults to Postgres.
+	Dialect Dialect
+	// SeasonBoundary is the start of seasons of competitions that don't register the
+	// dates of their seasons. It defaults to season.DefaultBoundary.
+	SeasonBoundary season.Boundary
+
+	// tx is set on the DB that InTransaction hands out
+	tx *sql.Tx
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
 }
 
-func New(connectionString string, appBaseDir string) *DB {
+// New connects to Postgres, retrying with increasing pauses until startupTimeout has
+// passed, so that the app survives a database that is still starting up. If autoMigrate
+// is set, it then applies the migrations that haven't been applied yet; otherwise, run
+// them with the migrate command. A failed migration doesn't stop the app, but it stays
+// unready until the schema is fixed.
+func New(connectionString string, autoMigrate bool, startupTimeout time.Duration) (*DB, error) {
 	conn, err := sql.Open("postgres", connectionString)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	if err := retry(startupTimeout, time.Second, "connecting to the database", conn.Ping); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if autoMigrate {
+		if err := migrateUp(conn); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+
+	return &DB{Conn: conn}, nil
+}
+
+func migrateUp(conn *sql.DB) error {
+	migrations, err := PostgresMigrations(conn)
+	if err != nil {
+		return err
 	}
+	// This releases the connection that the migrations ran on; conn stays open
+	defer func() {
+		if err := migrations.Close(); err != nil {
+			log.Printf("Error closing migrations: %v", err)
+		}
+	}()
+
+	return migrations.Up()
+}
 
-	pgDriver, err := postgres.WithInstance(conn, &postgres.Config{})
+// WithScrapeLock runs fn while holding a database-wide lock, so that multiple instances
+// of the app never scrape at the same time.
+func (db *DB) WithScrapeLock(fn func() error) error {
+	return db.dialect().WithScrapeLock(db.Conn, fn)
+}
+
+// InTransaction runs fn with a Store that performs all its queries in a single
+// transaction. The transaction is committed if fn returns nil, and rolled back
+// otherwise. Calling InTransaction on a DB that is already in a transaction reuses
+// that transaction.
+func (db *DB) InTransaction(fn func(tx Store) error) error {
+	return db.inTransaction(func(tx *DB) error {
+		return fn(tx)
+	})
+}
+
+func (db *DB) inTransaction(fn func(tx *DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+
+	tx, err := db.Conn.Begin()
 	if err != nil {
-		panic(fmt.Errorf("unable to create postgres driver instance: %v", err))
+		return fmt.Errorf("Error starting transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	if err := RunMigrations(pgDriver, appBaseDir); err != nil {
-		panic(fmt.Errorf("failed to run migrations: %v", err))
+	txDB := *db
+	txDB.tx = tx
+	if err := fn(&txDB); err != nil {
+		return err
 	}
 
-	return &DB{Conn: conn}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func (db *DB) Ping() error {
+	return db.Conn.Ping()
+}
+
+func (db *DB) Close() error {
+	return db.Conn.Close()
+}
+
+// DataVersion returns a number that goes up with every change to the matches or teams.
+// Triggers keep it up to date, so it also counts the changes of other instances.
+func (db *DB) DataVersion() (int64, error) {
+	var version int64
+	if err := db.conn().QueryRow("SELECT version FROM data_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("Error fetching data version: %v", err)
+	}
+	return version, nil
 }
 
-func (db *DB) GetLastScrape() (time.Time, error) {
-	var lastScrape time.Time
-	err := db.Conn.QueryRow("SELECT last_scrape FROM stats ORDER BY id DESC LIMIT 1").Scan(&lastScrape)
+func (db *DB) conn() queryer {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.Conn
+}
+
+// ArchivePage stores a downloaded page. Pages with the same contents are stored only
+// once, but every download is recorded.
+func (db *DB) ArchivePage(competition, url string, body []byte, fetchedAt time.Time) error {
+	hash := archive.Hash(body)
+
+	_, err := db.conn().Exec("INSERT INTO page_contents (hash, body) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING", hash, body)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return time.Time{}, nil
+		return fmt.Errorf("Error archiving page %s: %v", url, err)
+	}
+
+	_, err = db.conn().Exec("INSERT INTO archived_pages (url, competition, hash, fetched_at) VALUES ($1, $2, $3, $4)",
+		url, competition, hash, db.timestamp(fetchedAt))
+	if err != nil {
+		return fmt.Errorf("Error archiving page %s: %v", url, err)
+	}
+
+	return nil
+}
+
+// EachArchivedPage calls fn for every distinct page that was downloaded since the given
+// time, in the order in which they were first downloaded.
+func (db *DB) EachArchivedPage(since time.Time, fn func(page archive.Page) error) error {
+	query := `
+		SELECT a.hash, a.url, a.competition, a.fetched_at, c.body
+		FROM (
+			SELECT hash, url, competition, MIN(fetched_at) AS fetched_at
+			FROM archived_pages
+			WHERE fetched_at >= $1
+			GROUP BY hash, url, competition
+		) a
+		JOIN page_contents c ON c.hash = a.hash
+		ORDER BY a.fetched_at;
+	`
+
+	rows, err := db.conn().Query(query, db.timestamp(since))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var page archive.Page
+		if err := rows.Scan(&page.Hash, &page.URL, &page.Competition, textTime{&page.FetchedAt}, &page.Body); err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
 		}
-		return time.Time{}, err
 	}
 
-	return lastScrape, nil
+	return rows.Err()
+}
+
+// DeleteMatches deletes all matches of a competition that were played between the
+// given dates, inclusive, except the ones that were corrected by an admin.
+func (db *DB) DeleteMatches(competition string, from, to time.Time) (int64, error) {
+	result, err := db.conn().Exec(`
+		DELETE FROM matches
+		WHERE competition_id = (SELECT id FROM competitions WHERE name = $1)
+			AND date >= $2 AND date <= $3
+			AND NOT corrected`, competition, dateString(from), dateString(to))
+	if err != nil {
+		return 0, fmt.Errorf("Error deleting matches for %s: %v", competition, err)
+	}
+
+	return result.RowsAffected()
 }
 
-func (db *DB) UpdateLastScrape(scrapeTime time.Time) error {
-	_, err := db.Conn.Exec("INSERT INTO stats (last_scrape) VALUES ($1)", scrapeTime)
+// GetSourceRowCount returns the number of rows found on the given page during the last
+// successful scrape, or 0 if it wasn't scraped before.
+func (db *DB) GetSourceRowCount(url string) (int, error) {
+	var rows int
+	err := db.conn().QueryRow("SELECT rows_parsed FROM source_stats WHERE url = $1", url).Scan(&rows)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (db *DB) UpdateSourceRowCount(url string, rows int, scrapeTime time.Time) error {
+	_, err := db.conn().Exec(`
+		INSERT INTO source_stats (url, rows_parsed, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (url) DO UPDATE SET rows_parsed = EXCLUDED.rows_parsed, updated_at = EXCLUDED.updated_at`,
+		url, rows, db.timestamp(scrapeTime))
 	return err
 }
 
 func (db *DB) FetchTeamsFromDB() ([]team.Team, error) {
-	rows, err := db.Conn.Query("SELECT id, name FROM teams")
+	rows, err := db.conn().Query("SELECT id, name FROM teams WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -69,59 +265,280 @@ func (db *DB) FetchTeamsFromDB() ([]team.Team, error) {
 	return teams, nil
 }
 
-func (db *DB) InsertOrUpdateMatch(homeTeam, awayTeam string, homeGoals, awayGoals int, date time.Time) error {
+func (db *DB) FetchCompetitionsFromDB() ([]competition.Competition, error) {
+	rows, err := db.conn().Query("SELECT id, name FROM competitions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	competitions := []competition.Competition{}
+	for rows.Next() {
+		var competition competition.Competition
+		if err := rows.Scan(&competition.ID, &competition.Name); err != nil {
+			return nil, err
+		}
+		competitions = append(competitions, competition)
+	}
+
+	return competitions, nil
+}
+
+func (db *DB) GetCompetitionID(name string) (int, error) {
+	var competitionID int
+	err := db.conn().QueryRow("SELECT id FROM competitions WHERE name = $1", name).Scan(&competitionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return competitionID, nil
+}
+
+func (db *DB) InsertOrUpdateMatch(m match.Match) error {
+	// Insert or update the competition
+	competitionID, err := db.insertOrUpdateCompetition(m.Competition)
+	if err != nil {
+		return err
+	}
+
 	// Insert or update the home team
-	homeTeamID, err := db.insertOrUpdateTeam(homeTeam)
+	homeTeamID, err := db.insertOrUpdateTeam(m.HomeTeam)
 	if err != nil {
 		return err
 	}
 
 	// Insert or update the away team
-	awayTeamID, err := db.insertOrUpdateTeam(awayTeam)
+	awayTeamID, err := db.insertOrUpdateTeam(m.AwayTeam)
 	if err != nil {
 		return err
 	}
 
-	// Check if the match already exists
-	var matchID int
-	err = db.Conn.QueryRow("SELECT id FROM matches WHERE home_team = $1 AND away_team = $2 AND date = $3",
-		homeTeamID, awayTeamID, date).Scan(&matchID)
-
-	switch {
-	case err == sql.ErrNoRows:
-		// Insert a new match
-		_, err := db.Conn.Exec("INSERT INTO matches (home_team, away_team, home_goals, away_goals, date) VALUES ($1, $2, $3, $4, $5)",
-			homeTeamID, awayTeamID, homeGoals, awayGoals, date)
+	var homeGoals, awayGoals sql.NullInt64
+	if m.HasScore() {
+		homeGoals = sql.NullInt64{Int64: int64(m.HomeGoals), Valid: true}
+		awayGoals = sql.NullInt64{Int64: int64(m.AwayGoals), Valid: true}
+	}
+
+	// A match is identified by its external ID, so it's updated even if it has been
+	// rescheduled, and by its teams and date otherwise
+	matchID, err := db.findMatchID(m.ExternalID, homeTeamID, awayTeamID, m.Date)
+	if err == sql.ErrNoRows {
+		_, err = db.conn().Exec(`
+			INSERT INTO matches (competition_id, home_team, away_team, home_goals, away_goals, date,
+				external_id, status, kickoff, half_time_home_goals, half_time_away_goals)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			competitionID, homeTeamID, awayTeamID, homeGoals, awayGoals, dateString(m.Date),
+			nullString(m.ExternalID), string(m.Status), db.nullTime(m.Kickoff), m.HalfTimeHomeGoals, m.HalfTimeAwayGoals)
+		if err != nil {
+			return err
+		}
+		return db.restoreTeams(homeTeamID, awayTeamID)
+	} else if err != nil {
 		return err
-	case err != nil:
+	}
+
+	// Matches that were corrected by an admin are left alone
+	res, err := db.conn().Exec(`
+		UPDATE matches
+		SET competition_id = $1, home_team = $2, away_team = $3, home_goals = $4, away_goals = $5, date = $6,
+			external_id = COALESCE($7, external_id), status = $8, kickoff = $9,
+			half_time_home_goals = $10, half_time_away_goals = $11
+		WHERE id = $12 AND NOT corrected`,
+		competitionID, homeTeamID, awayTeamID, homeGoals, awayGoals, dateString(m.Date),
+		nullString(m.ExternalID), string(m.Status), db.nullTime(m.Kickoff), m.HalfTimeHomeGoals, m.HalfTimeAwayGoals, matchID)
+	if err != nil {
 		return err
-	default:
-		// Do nothing if the match already exists
-		return nil
 	}
+	if updated, err := res.RowsAffected(); err != nil || updated == 0 {
+		return err
+	}
+
+	return db.restoreTeams(homeTeamID, awayTeamID)
 }
 
-func (db *DB) insertOrUpdateTeam(name string) (int, error) {
-	var teamID int
-	err := db.Conn.QueryRow("SELECT id FROM teams WHERE name = $1", name).Scan(&teamID)
+// restoreTeams brings back deleted teams that play a match again.
+func (db *DB) restoreTeams(teamIDs ...int) error {
+	for _, teamID := range teamIDs {
+		if _, err := db.conn().Exec("UPDATE teams SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", teamID); err != nil {
+			return fmt.Errorf("Error restoring team %d: %v", teamID, err)
+		}
+	}
+	return nil
+}
 
-	switch {
-	case err == sql.ErrNoRows:
-		// Insert a new team
-		err := db.Conn.QueryRow("INSERT INTO teams (name) VALUES ($1) RETURNING id", name).Scan(&teamID)
+// FindMatch returns the stored version of the given match, or nil if it hasn't been
+// stored yet.
+func (db *DB) FindMatch(m match.Match) (*match.Match, error) {
+	// Unknown teams can still be part of a match that's found by its external ID
+	homeTeamID, err := db.lookupTeamID(m.HomeTeam)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	awayTeamID, err := db.lookupTeamID(m.AwayTeam)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	matchID, err := db.findMatchID(m.ExternalID, homeTeamID, awayTeamID, m.Date)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return db.getMatch(matchID)
+}
+
+const matchColumns = `COALESCE(m.external_id, ''), c.name, h.name, a.name, m.home_goals, m.away_goals, m.date, m.status,
+	m.kickoff, m.half_time_home_goals, m.half_time_away_goals`
+
+const matchJoins = `JOIN competitions c ON c.id = m.competition_id
+	JOIN teams h ON h.id = m.home_team
+	JOIN teams a ON a.id = m.away_team`
+
+func (db *DB) getMatch(matchID int) (*match.Match, error) {
+	query := `SELECT ` + matchColumns + ` FROM matches m ` + matchJoins + ` WHERE m.id = $1`
+
+	m, err := scanMatch(db.conn().QueryRow(query, matchID))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching match %d: %v", matchID, err)
+	}
+	return &m, nil
+}
+
+// EachMatch calls fn for every match that the filter selects, in order of date.
+func (db *DB) EachMatch(filter match.Filter, fn func(m match.Match) error) error {
+	from, to := dateRange(filter)
+	query := `SELECT ` + matchColumns + ` FROM matches m ` + matchJoins + `
+		WHERE ($1 = 0 OR m.competition_id = $1)
+			AND ($2 = 0 OR m.home_team = $2 OR m.away_team = $2)
+			AND m.date >= $3 AND m.date <= $4
+			AND m.deleted_at IS NULL
+		ORDER BY m.date, m.id`
+
+	rows, err := db.conn().Query(query, filter.CompetitionID, filter.TeamID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanMatch(rows)
 		if err != nil {
-			return 0, err
+			return err
 		}
-	case err != nil:
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// dateRange returns the dates that the filter selects, as strings that select every
+// date when the filter doesn't set them.
+func dateRange(filter match.Filter) (string, string) {
+	from, to := "0001-01-01", "9999-12-31"
+	if !filter.From.IsZero() {
+		from = dateString(filter.From)
+	}
+	if !filter.To.IsZero() {
+		to = dateString(filter.To)
+	}
+	return from, to
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMatch scans the matchColumns of a row, followed by the extra columns, if any.
+func scanMatch(row scanner, extra ...interface{}) (match.Match, error) {
+	var m match.Match
+	var homeGoals, awayGoals, halfTimeHomeGoals, halfTimeAwayGoals sql.NullInt64
+	var kickoff sql.NullTime
+	dest := []interface{}{&m.ExternalID, &m.Competition, &m.HomeTeam, &m.AwayTeam, &homeGoals, &awayGoals,
+		&m.Date, &m.Status, &kickoff, &halfTimeHomeGoals, &halfTimeAwayGoals}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return m, err
+	}
+
+	m.HomeGoals = int(homeGoals.Int64)
+	m.AwayGoals = int(awayGoals.Int64)
+	m.Kickoff = kickoff.Time
+	m.HalfTimeHomeGoals = intPointer(halfTimeHomeGoals)
+	m.HalfTimeAwayGoals = intPointer(halfTimeAwayGoals)
+	return m, nil
+}
+
+// findMatchID finds a match by its external ID, and by its teams and date otherwise,
+// which also finds a match that was stored under another external ID.
+func (db *DB) findMatchID(externalID string, homeTeamID, awayTeamID int, date time.Time) (int, error) {
+	var matchID int
+	if externalID != "" {
+		err := db.conn().QueryRow("SELECT id FROM matches WHERE external_id = $1", externalID).Scan(&matchID)
+		if err != sql.ErrNoRows {
+			return matchID, err
+		}
+	}
+
+	err := db.conn().QueryRow("SELECT id FROM matches WHERE home_team = $1 AND away_team = $2 AND date = $3",
+		homeTeamID, awayTeamID, dateString(date)).Scan(&matchID)
+	return matchID, err
+}
+
+func (db *DB) insertOrUpdateCompetition(name string) (int, error) {
+	// DO UPDATE rather than DO NOTHING, so that RETURNING also returns existing rows
+	var competitionID int
+	err := db.conn().QueryRow("INSERT INTO competitions (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id",
+		name).Scan(&competitionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return competitionID, nil
+}
+
+func (db *DB) insertOrUpdateTeam(name string) (int, error) {
+	var teamID int
+	err := db.conn().QueryRow("SELECT team_id FROM team_aliases WHERE alias = $1", name).Scan(&teamID)
+	if err != sql.ErrNoRows {
+		return teamID, err
+	}
+
+	err = db.conn().QueryRow("INSERT INTO teams (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id",
+		name).Scan(&teamID)
+	if err != nil {
 		return 0, err
 	}
 
 	return teamID, nil
 }
 
+// lookupTeamID finds a team by one of its aliases first, and by its name otherwise. It
+// also finds deleted teams, which the scraper restores when they play again.
+func (db *DB) lookupTeamID(name string) (int, error) {
+	var teamID int
+	err := db.conn().QueryRow("SELECT team_id FROM team_aliases WHERE alias = $1", name).Scan(&teamID)
+	if err != sql.ErrNoRows {
+		return teamID, err
+	}
+
+	err = db.conn().QueryRow("SELECT id FROM teams WHERE name = $1", name).Scan(&teamID)
+	return teamID, err
+}
+
+// GetTeamID finds a team like lookupTeamID does, except that deleted teams aren't found.
 func (db *DB) GetTeamID(teamName string) (int, error) {
 	var teamID int
-	err := db.Conn.QueryRow("SELECT id FROM teams WHERE name = $1", teamName).Scan(&teamID)
+	err := db.conn().QueryRow(`
+		SELECT id FROM teams
+		WHERE deleted_at IS NULL AND id = COALESCE(
+			(SELECT team_id FROM team_aliases WHERE alias = $1),
+			(SELECT id FROM teams WHERE name = $1))`, teamName).Scan(&teamID)
 	if err != nil {
 		return 0, err
 	}
@@ -129,7 +546,70 @@ func (db *DB) GetTeamID(teamName string) (int, error) {
 	return teamID, nil
 }
 
-func (db *DB) AverageGoalsInLastMatches(teamID int, numberOfMatches int) (float64, error) {
+// MergeTeams moves all matches and aliases of the source team to the target team,
+// registers the source team's name as an alias of the target team, and deletes the
+// source team. Deleted teams can't be merged, nor be merged into.
+func (db *DB) MergeTeams(sourceID, targetID int) error {
+	if sourceID == targetID {
+		return fmt.Errorf("Cannot merge team %d with itself", sourceID)
+	}
+
+	return db.inTransaction(func(tx *DB) error {
+		var sourceName, targetName string
+		err := tx.conn().QueryRow("SELECT name FROM teams WHERE id = $1 AND deleted_at IS NULL", sourceID).Scan(&sourceName)
+		if err == sql.ErrNoRows {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		err = tx.conn().QueryRow("SELECT name FROM teams WHERE id = $1 AND deleted_at IS NULL", targetID).Scan(&targetName)
+		if err == sql.ErrNoRows {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		// Both teams may have a row for the same match; keep the target team's, so that
+		// moving the matches doesn't violate the unique constraint
+		_, err = tx.conn().Exec(tx.dialect().DeleteJoined("matches", "s", "matches t", `
+			$2 IN (s.home_team, s.away_team)
+				AND t.id <> s.id
+				AND t.home_team = CASE WHEN s.home_team = $2 THEN $1 ELSE s.home_team END
+				AND t.away_team = CASE WHEN s.away_team = $2 THEN $1 ELSE s.away_team END
+				AND t.date = s.date`), targetID, sourceID)
+		if err != nil {
+			return fmt.Errorf("Error removing duplicate matches for team %d: %v", sourceID, err)
+		}
+
+		statements := []string{
+			"UPDATE matches SET home_team = $1 WHERE home_team = $2",
+			"UPDATE matches SET away_team = $1 WHERE away_team = $2",
+			"UPDATE team_aliases SET team_id = $1 WHERE team_id = $2",
+		}
+		for _, statement := range statements {
+			if _, err := tx.conn().Exec(statement, targetID, sourceID); err != nil {
+				return fmt.Errorf("Error merging team %d into %d: %v", sourceID, targetID, err)
+			}
+		}
+
+		_, err = tx.conn().Exec("INSERT INTO team_aliases (alias, team_id) VALUES ($1, $2) ON CONFLICT (alias) DO UPDATE SET team_id = EXCLUDED.team_id",
+			sourceName, targetID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.conn().Exec("DELETE FROM teams WHERE id = $1", sourceID); err != nil {
+			return err
+		}
+
+		log.Printf("Merged team %d (%s) into %d (%s)", sourceID, sourceName, targetID, targetName)
+
+		return nil
+	})
+}
+
+func (db *DB) AverageGoalsInLastMatches(competitionID int, teamID int, numberOfMatches int) (float64, error) {
 	if teamID == -1 {
 		return 0, nil
 	}
@@ -138,13 +618,13 @@ func (db *DB) AverageGoalsInLastMatches(teamID int, numberOfMatches int) (float6
 		WITH combined AS (
 			SELECT home_team AS team, home_goals AS goals, date
 			FROM matches
-			WHERE home_team = $1
+			WHERE home_team = $1 AND competition_id = $3 AND status IN ('played', 'awarded') AND deleted_at IS NULL
 			UNION ALL
 			SELECT away_team AS team, away_goals AS goals, date
 			FROM matches
-			WHERE away_team = $1
+			WHERE away_team = $1 AND competition_id = $3 AND status IN ('played', 'awarded') AND deleted_at IS NULL
 		)
-		SELECT AVG(goals)
+		SELECT COALESCE(AVG(goals), 0)
 		FROM (
 			SELECT goals
 			FROM combined
@@ -154,7 +634,7 @@ func (db *DB) AverageGoalsInLastMatches(teamID int, numberOfMatches int) (float6
 	`
 
 	var avgGoals float64
-	err := db.Conn.QueryRow(query, teamID, numberOfMatches).Scan(&avgGoals)
+	err := db.conn().QueryRow(query, teamID, numberOfMatches, competitionID).Scan(&avgGoals)
 	if err != nil {
 		return 0, fmt.Errorf("Error fetching average goals for team %d: %v", teamID, err)
 	}
@@ -162,17 +642,17 @@ func (db *DB) AverageGoalsInLastMatches(teamID int, numberOfMatches int) (float6
 	return avgGoals, nil
 }
 
-func (db *DB) LastYearMatchScores(homeTeamID, awayTeamID int) (int, int, error) {
+func (db *DB) LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error) {
 	query := `
 		SELECT home_goals, away_goals
 		FROM matches
-		WHERE home_team = $1 AND away_team = $2
+		WHERE home_team = $1 AND away_team = $2 AND competition_id = $3 AND status IN ('played', 'awarded') AND deleted_at IS NULL
 		ORDER BY date DESC
 		LIMIT 1;
 	`
 
 	var homeGoals, awayGoals int
-	err := db.Conn.QueryRow(query, homeTeamID, awayTeamID).Scan(&homeGoals, &awayGoals)
+	err := db.conn().QueryRow(query, homeTeamID, awayTeamID, competitionID).Scan(&homeGoals, &awayGoals)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, 0, nil
@@ -183,52 +663,103 @@ func (db *DB) LastYearMatchScores(homeTeamID, awayTeamID int) (int, int, error)
 	return homeGoals, awayGoals, nil
 }
 
-func (db *DB) GetCurrentSeasonLeaderboard() (map[int]int, error) {
-	seasonStart := time.Date(time.Now().Year(), time.August, 1, 0, 0, 0, 0, time.UTC)
-	if time.Now().Before(seasonStart) {
-		seasonStart = seasonStart.AddDate(-1, 0, 0)
+// GetCurrentSeasonLeaderboard returns the points of each team in the current season,
+// from the team statistics.
+func (db *DB) GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error) {
+	currentSeason, err := db.SeasonAt(competitionID, time.Now())
+	if err != nil {
+		return nil, err
 	}
-	query := `
-		SELECT
-			CASE
-				WHEN home_goals > away_goals THEN home_team
-				WHEN home_goals < away_goals THEN away_team
-				ELSE NULL
-			END AS winner,
-			CASE
-				WHEN home_goals = away_goals THEN home_team
-				ELSE NULL
-			END AS draw_team1,
-			CASE
-				WHEN home_goals = away_goals THEN away_team
-				ELSE NULL
-			END AS draw_team2
-		FROM matches
-		WHERE date >= $1;
-	`
 
-	rows, err := db.Conn.Query(query, seasonStart)
+	stats, err := db.GetTeamStats(competitionID, currentSeason.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching leaderboard: %v", err)
+	}
+
+	return teamstats.Leaderboard(stats), nil
+}
+
+// GetLeaderboard returns the points of each team, based on the matches of the
+// competition that were played between from and to, inclusive.
+func (db *DB) GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error) {
+	results, err := db.playedResults("competition_id = $1 AND date >= $2 AND date <= $3",
+		competitionID, dateString(from), dateString(to))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	points := make(map[int]int)
-	for rows.Next() {
-		var winner, drawTeam1, drawTeam2 sql.NullInt64
-		err := rows.Scan(&winner, &drawTeam1, &drawTeam2)
-		if err != nil {
-			return nil, err
-		}
+	return teamstats.Points(results), nil
+}
 
-		if winner.Valid {
-			points[int(winner.Int64)] += 3
-		}
-		if drawTeam1.Valid {
-			points[int(drawTeam1.Int64)]++
-			points[int(drawTeam2.Int64)]++
-		}
+// GetSeason returns the season of the competition with the given name, such as
+// "2022-2023". Seasons registered for the competition take precedence; otherwise,
+// seasons start on the configured SeasonBoundary.
+func (db *DB) GetSeason(competitionID int, name string) (season.Season, error) {
+	s := season.Season{Name: name}
+	err := db.conn().QueryRow("SELECT start_date, end_date FROM seasons WHERE competition_id = $1 AND name = $2",
+		competitionID, name).Scan(&s.Start, &s.End)
+	if err == nil {
+		return s, nil
+	} else if err != sql.ErrNoRows {
+		return season.Season{}, fmt.Errorf("Error fetching season %s for competition %d: %v", name, competitionID, err)
 	}
 
-	return points, nil
+	return db.seasonBoundary().Season(name)
+}
+
+// SeasonAt returns the season of the competition that is running at the given time.
+// Seasons registered for the competition take precedence; otherwise, seasons start on
+// the configured SeasonBoundary.
+func (db *DB) SeasonAt(competitionID int, t time.Time) (season.Season, error) {
+	var s season.Season
+	err := db.conn().QueryRow("SELECT name, start_date, end_date FROM seasons WHERE competition_id = $1 AND start_date <= $2 AND end_date >= $2 ORDER BY start_date DESC LIMIT 1",
+		competitionID, dateString(t)).Scan(&s.Name, &s.Start, &s.End)
+	if err == nil {
+		return s, nil
+	} else if err != sql.ErrNoRows {
+		return season.Season{}, fmt.Errorf("Error fetching current season for competition %d: %v", competitionID, err)
+	}
+
+	return db.seasonBoundary().SeasonAt(t), nil
+}
+
+// RegisterSeason registers the dates of a season that doesn't start on the
+// SeasonBoundary, such as one that was cut short.
+func (db *DB) RegisterSeason(competitionID int, s season.Season) error {
+	_, err := db.conn().Exec("INSERT INTO seasons (competition_id, name, start_date, end_date) VALUES ($1, $2, $3, $4)",
+		competitionID, s.Name, dateString(s.Start), dateString(s.End))
+	if err != nil {
+		return fmt.Errorf("Error registering season %s for competition %d: %v", s.Name, competitionID, err)
+	}
+	return nil
+}
+
+func (db *DB) seasonBoundary() season.Boundary {
+	if db.SeasonBoundary == (season.Boundary{}) {
+		return season.DefaultBoundary
+	}
+	return db.SeasonBoundary
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// dateString formats a time as a date in its own location, so that Postgres doesn't
+// convert it to another timezone when comparing it with a DATE column, and so that
+// SQLite, which stores dates as text, compares them correctly.
+func dateString(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+func intPointer(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	i := int(n.Int64)
+	return &i
 }
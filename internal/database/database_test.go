@@ -2,11 +2,22 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	dbfiles "github.com/jqno/balGPT/db"
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/season"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetLastScrape(t *testing.T) {
@@ -18,17 +29,17 @@ func TestGetLastScrape(t *testing.T) {
 
 	database := DB{Conn: db}
 
-	rows := sqlmock.NewRows([]string{"last_scrape"}).
+	rows := sqlmock.NewRows([]string{"finished_at"}).
 		AddRow(time.Now())
 
-	query := "SELECT last_scrape FROM stats ORDER BY id DESC LIMIT 1"
+	query := "SELECT finished_at FROM scrape_runs WHERE error IS NULL ORDER BY finished_at DESC LIMIT 1"
 	mock.ExpectQuery(query).WillReturnRows(rows)
 
 	_, err = database.GetLastScrape()
 	assert.NoError(t, err)
 }
 
-func TestUpdateLastScrape(t *testing.T) {
+func TestRecordScrapeRuns(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -37,12 +48,132 @@ func TestUpdateLastScrape(t *testing.T) {
 
 	database := DB{Conn: db}
 
-	mock.ExpectExec("INSERT INTO stats \\(last_scrape\\) VALUES \\(\\$1\\)").
-		WithArgs(sqlmock.AnyArg()).
+	now := time.Now()
+	mock.ExpectExec("INSERT INTO scrape_runs").
+		WithArgs("scrape", "Eredivisie", "https://example.com", now, now, 200, 10, 9, 1, sql.NullString{}).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO scrape_runs").
+		WithArgs("predict", "Eredivisie", "https://example.com", now, now, sql.NullInt64{}, 0, 0, 0, "connection refused").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	err = database.RecordScrapeRuns([]scraperun.Run{
+		{Trigger: scraperun.Scrape, Competition: "Eredivisie", SourceURL: "https://example.com", StartedAt: now, FinishedAt: now,
+			HTTPStatus: 200, RowsParsed: 10, RowsInserted: 9, RowsSkipped: 1},
+		{Trigger: scraperun.Predict, Competition: "Eredivisie", SourceURL: "https://example.com", StartedAt: now, FinishedAt: now,
+			Error: "connection refused"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetScrapeRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, triggered_by, .* FROM scrape_runs ORDER BY started_at DESC, id DESC LIMIT \\$1").
+		WithArgs(20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "triggered_by", "competition", "source_url", "started_at", "finished_at",
+			"http_status", "rows_parsed", "rows_inserted", "rows_skipped", "error"}).
+			AddRow(2, "predict", "Eredivisie", "https://example.com", now, now, nil, 0, 0, 0, "connection refused").
+			AddRow(1, "scrape", "Eredivisie", "https://example.com", now, now, 200, 10, 9, 1, ""))
+
+	runs, err := database.GetScrapeRuns(20)
+	assert.NoError(t, err)
+	assert.Equal(t, []scraperun.Run{
+		{ID: 2, Trigger: scraperun.Predict, Competition: "Eredivisie", SourceURL: "https://example.com", StartedAt: now, FinishedAt: now,
+			Error: "connection refused"},
+		{ID: 1, Trigger: scraperun.Scrape, Competition: "Eredivisie", SourceURL: "https://example.com", StartedAt: now, FinishedAt: now,
+			HTTPStatus: 200, RowsParsed: 10, RowsInserted: 9, RowsSkipped: 1},
+	}, runs)
+}
+
+func TestGetSourceRowCountForNewSource(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectQuery("SELECT rows_parsed FROM source_stats WHERE url = \\$1").
+		WithArgs("https://example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"rows_parsed"}))
 
-	err = database.UpdateLastScrape(time.Now())
+	rows, err := database.GetSourceRowCount("https://example.com")
 	assert.NoError(t, err)
+	assert.Equal(t, 0, rows)
+}
+
+func TestUpdateSourceRowCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectExec("INSERT INTO source_stats \\(url, rows_parsed, updated_at\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs("https://example.com", 42, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = database.UpdateSourceRowCount("https://example.com", 42, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInTransactionCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO scrape_runs").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = database.InTransaction(func(tx Store) error {
+		return tx.RecordScrapeRuns([]scraperun.Run{{Trigger: scraperun.Scrape, StartedAt: time.Now(), FinishedAt: time.Now()}})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInTransactionRollsBackOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO source_stats").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO scrape_runs").
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	err = database.InTransaction(func(tx Store) error {
+		if err := tx.UpdateSourceRowCount("https://example.com", 42, time.Now()); err != nil {
+			return err
+		}
+		return tx.RecordScrapeRuns([]scraperun.Run{{Trigger: scraperun.Scrape, StartedAt: time.Now(), FinishedAt: time.Now()}})
+	})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestFetchTeamsFromDB(t *testing.T) {
@@ -76,24 +207,88 @@ func TestInsertOrUpdateMatch(t *testing.T) {
 
 	database := DB{Conn: db}
 
-	mock.ExpectQuery("SELECT id FROM teams WHERE name = \\$1").
+	mock.ExpectQuery("INSERT INTO competitions \\(name\\) VALUES \\(\\$1\\) ON CONFLICT \\(name\\)").
+		WithArgs("Eredivisie").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").
+		WithArgs("Home").
+		WillReturnError(sql.ErrNoRows)
+
+	mock.ExpectQuery("INSERT INTO teams \\(name\\) VALUES \\(\\$1\\) ON CONFLICT \\(name\\)").
 		WithArgs("Home").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	mock.ExpectQuery("SELECT id FROM teams WHERE name = \\$1").
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").
+		WithArgs("Away").
+		WillReturnError(sql.ErrNoRows)
+
+	mock.ExpectQuery("INSERT INTO teams \\(name\\) VALUES \\(\\$1\\) ON CONFLICT \\(name\\)").
 		WithArgs("Away").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
 
+	// The match was stored under another external ID, so it's found by its teams and date
+	mock.ExpectQuery("SELECT id FROM matches WHERE external_id = \\$1").
+		WithArgs("https://example.com/home-away").
+		WillReturnError(sql.ErrNoRows)
 	mock.ExpectQuery("SELECT id FROM matches WHERE home_team = \\$1 AND away_team = \\$2 AND date = \\$3").
 		WithArgs(1, 2, sqlmock.AnyArg()).
-		WillReturnError(sql.ErrNoRows)
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	mock.ExpectExec("UPDATE matches.+external_id = COALESCE\\(\\$7, external_id\\).+WHERE id = \\$12 AND NOT corrected").
+		WithArgs(9, 1, 2, 3, 2, sqlmock.AnyArg(), "https://example.com/home-away", "played", nil, nil, nil, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE teams SET deleted_at = NULL WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE teams SET deleted_at = NULL WHERE id = \\$1").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = database.InsertOrUpdateMatch(match.Match{
+		ExternalID:  "https://example.com/home-away",
+		Competition: "Eredivisie",
+		HomeTeam:    "Home",
+		AwayTeam:    "Away",
+		HomeGoals:   3,
+		AwayGoals:   2,
+		Date:        time.Now(),
+		Status:      match.Played,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	mock.ExpectExec("INSERT INTO matches \\(home_team, away_team, home_goals, away_goals, date\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5\\)").
-		WithArgs(1, 2, 3, 2, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+func TestInsertOrUpdateMatchWithoutExternalID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectQuery("INSERT INTO competitions").WithArgs("Eredivisie").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("Home").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(1))
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("Away").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(2))
 
-	err = database.InsertOrUpdateMatch("Home", "Away", 3, 2, time.Now())
+	date := time.Date(2023, 4, 12, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id FROM matches WHERE home_team = \\$1 AND away_team = \\$2 AND date = \\$3").
+		WithArgs(1, 2, "2023-04-12").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO matches \\(competition_id, home_team, away_team, home_goals, away_goals, date,").
+		WithArgs(9, 1, 2, 1, 1, "2023-04-12", nil, "played", nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE teams SET deleted_at = NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE teams SET deleted_at = NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = database.InsertOrUpdateMatch(match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    "Home",
+		AwayTeam:    "Away",
+		HomeGoals:   1,
+		AwayGoals:   1,
+		Date:        date,
+		Status:      match.Played,
+	})
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetTeamID(t *testing.T) {
@@ -107,13 +302,75 @@ func TestGetTeamID(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
 
-	mock.ExpectQuery("SELECT id FROM teams WHERE name = \\$1").WithArgs("team1").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id FROM teams WHERE deleted_at IS NULL").WithArgs("team1").WillReturnRows(rows)
 
 	id, err := database.GetTeamID("team1")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, id)
 }
 
+func TestGetTeamIDByAlias(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	rows := sqlmock.NewRows([]string{"team_id"}).AddRow(7)
+
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("Jong FC Utrecht").WillReturnRows(rows)
+
+	id, err := database.GetTeamID("Jong FC Utrecht")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeTeams(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT name FROM teams WHERE id = \\$1").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Jong FC Utrecht"))
+	mock.ExpectQuery("SELECT name FROM teams WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Jong Utrecht"))
+	mock.ExpectExec("DELETE FROM matches s").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE matches SET home_team = \\$1 WHERE home_team = \\$2").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE matches SET away_team = \\$1 WHERE away_team = \\$2").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE team_aliases SET team_id = \\$1 WHERE team_id = \\$2").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO team_aliases").WithArgs("Jong FC Utrecht", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM teams WHERE id = \\$1").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = database.MergeTeams(2, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeTeamsWithUnknownTeam(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT name FROM teams WHERE id = \\$1").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = database.MergeTeams(2, 1)
+	assert.Equal(t, ErrTeamNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestAverageGoalsInLastMatches(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -125,9 +382,9 @@ func TestAverageGoalsInLastMatches(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{"AVG(goals)"}).AddRow(1.5)
 
-	mock.ExpectQuery(".*").WithArgs(1, 5).WillReturnRows(rows)
+	mock.ExpectQuery(".*").WithArgs(1, 5, 9).WillReturnRows(rows)
 
-	avg, err := database.AverageGoalsInLastMatches(1, 5)
+	avg, err := database.AverageGoalsInLastMatches(9, 1, 5)
 	assert.NoError(t, err)
 	assert.Equal(t, 1.5, avg)
 }
@@ -141,7 +398,7 @@ func TestAverageGoalsInLastMatchesWithNegativeTeamID(t *testing.T) {
 
 	database := DB{Conn: db}
 
-	avg, err := database.AverageGoalsInLastMatches(-1, 5)
+	avg, err := database.AverageGoalsInLastMatches(9, -1, 5)
 	assert.NoError(t, err)
 	assert.Equal(t, 0.0, avg)
 
@@ -162,9 +419,9 @@ func TestLastYearMatchScores(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{"home_goals", "away_goals"}).AddRow(2, 3)
 
-	mock.ExpectQuery(".*").WithArgs(1, 2).WillReturnRows(rows)
+	mock.ExpectQuery(".*").WithArgs(1, 2, 9).WillReturnRows(rows)
 
-	homeGoals, awayGoals, err := database.LastYearMatchScores(1, 2)
+	homeGoals, awayGoals, err := database.LastYearMatchScores(9, 1, 2)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, homeGoals)
 	assert.Equal(t, 3, awayGoals)
@@ -179,13 +436,547 @@ func TestGetCurrentSeasonLeaderboard(t *testing.T) {
 
 	database := DB{Conn: db}
 
-	rows := sqlmock.NewRows([]string{"winner", "draw_team1", "draw_team2"}).
-		AddRow(sql.NullInt64{Valid: true, Int64: 1}, sql.NullInt64{Valid: false, Int64: 0}, sql.NullInt64{Valid: false, Int64: 0}).
-		AddRow(sql.NullInt64{Valid: false, Int64: 0}, sql.NullInt64{Valid: true, Int64: 2}, sql.NullInt64{Valid: true, Int64: 3})
+	rows := teamStatsRows().
+		AddRow(9, 1, "", 2, 1, 0, 1, 2, 2, 1, 1, 0, 0, 2, 1, 1, 0, 0, 1, 0, 1, "WL").
+		AddRow(9, 2, "", 1, 0, 1, 0, 1, 1, 1, 0, 1, 0, 1, 1, 0, 0, 0, 0, 0, 0, "D").
+		AddRow(9, 3, "", 1, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 1, "L")
+
+	mock.ExpectQuery("SELECT name, start_date, end_date FROM seasons").WithArgs(9, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("FROM team_stats").WithArgs(9, sqlmock.AnyArg()).WillReturnRows(rows)
+
+	result, err := database.GetCurrentSeasonLeaderboard(9)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 3, 2: 1}, result)
+}
+
+func TestGetCurrentSeasonLeaderboardUsesRegisteredSeason(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	seasonStart := time.Date(2020, 9, 12, 0, 0, 0, 0, time.UTC)
+	seasonEnd := time.Date(2021, 5, 16, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT name, start_date, end_date FROM seasons").WithArgs(9, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "start_date", "end_date"}).AddRow("2020-2021", seasonStart, seasonEnd))
+	mock.ExpectQuery("FROM team_stats").WithArgs(9, "2020-2021").WillReturnRows(teamStatsRows())
+
+	result, err := database.GetCurrentSeasonLeaderboard(9)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSeasonUsesConfiguredBoundary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db, SeasonBoundary: season.Boundary{Month: time.July, Day: 1}}
+
+	mock.ExpectQuery("SELECT start_date, end_date FROM seasons WHERE competition_id = \\$1 AND name = \\$2").
+		WithArgs(9, "2018-2019").
+		WillReturnError(sql.ErrNoRows)
+
+	s, err := database.GetSeason(9, "2018-2019")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 7, 1, 0, 0, 0, 0, dates.Amsterdam), s.Start)
+	assert.Equal(t, time.Date(2019, 6, 30, 0, 0, 0, 0, dates.Amsterdam), s.End)
+}
+
+func TestGetSeasonUsesRegisteredSeason(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	// The 2019-2020 season was cut short by Covid
+	start := time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2020, 3, 8, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT start_date, end_date FROM seasons").
+		WithArgs(9, "2019-2020").
+		WillReturnRows(sqlmock.NewRows([]string{"start_date", "end_date"}).AddRow(start, end))
+
+	s, err := database.GetSeason(9, "2019-2020")
+	assert.NoError(t, err)
+	assert.Equal(t, season.Season{Name: "2019-2020", Start: start, End: end}, s)
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	rows := sqlmock.NewRows([]string{"competition_id", "home_team", "away_team", "home_goals", "away_goals", "date"}).
+		AddRow(9, 1, 2, 2, 1, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)).
+		AddRow(9, 3, 1, 0, 1, time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC))
+	mock.ExpectQuery("FROM matches").WithArgs(9, "2022-08-01", "2022-12-31").WillReturnRows(rows)
+
+	result, err := database.GetLeaderboard(9, time.Date(2022, 8, 1, 0, 0, 0, 0, dates.Amsterdam), time.Date(2022, 12, 31, 0, 0, 0, 0, dates.Amsterdam))
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 6}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestArchivePage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	body := []byte("<html></html>")
+	hash := archive.Hash(body)
+	fetchedAt := time.Now()
+
+	mock.ExpectExec("INSERT INTO page_contents \\(hash, body\\) VALUES \\(\\$1, \\$2\\) ON CONFLICT \\(hash\\) DO NOTHING").
+		WithArgs(hash, body).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO archived_pages \\(url, competition, hash, fetched_at\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\)").
+		WithArgs("https://example.com", "Eredivisie", hash, fetchedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = database.ArchivePage("Eredivisie", "https://example.com", body, fetchedAt)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEachArchivedPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	fetchedAt := time.Now()
+	rows := sqlmock.NewRows([]string{"hash", "url", "competition", "fetched_at", "body"}).
+		AddRow("abc", "https://example.com/1", "Eredivisie", fetchedAt, []byte("one")).
+		AddRow("def", "https://example.com/2", "Eredivisie", fetchedAt, []byte("two"))
+
+	mock.ExpectQuery(".*").WithArgs(time.Time{}).WillReturnRows(rows)
+
+	pages := []archive.Page{}
+	err = database.EachArchivedPage(time.Time{}, func(page archive.Page) error {
+		pages = append(pages, page)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "https://example.com/2", pages[1].URL)
+	assert.Equal(t, []byte("two"), pages[1].Body)
+}
+
+func TestWithScrapeLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectExec("SELECT pg_advisory_lock\\(\\$1\\)").WithArgs(scrapeLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WithArgs(scrapeLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	called := false
+	err = database.WithScrapeLock(func() error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
 
-	mock.ExpectQuery(".*").WillReturnRows(rows)
+	database := DB{Conn: db}
 
-	result, err := database.GetCurrentSeasonLeaderboard()
+	date := time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("MVV").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(1))
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("NAC").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(2))
+	mock.ExpectQuery("SELECT id FROM matches WHERE external_id = \\$1").WithArgs("https://example.com/mvv-nac").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectQuery("SELECT COALESCE\\(m.external_id, ''\\)").WithArgs(42).WillReturnRows(
+		sqlmock.NewRows([]string{"external_id", "competition", "home", "away", "home_goals", "away_goals", "date", "status", "kickoff", "ht_home", "ht_away"}).
+			AddRow("https://example.com/mvv-nac", "Keuken Kampioen Divisie", "MVV", "NAC", 3, 1, date, "played", nil, 1, 1))
+
+	m, err := database.FindMatch(match.Match{ExternalID: "https://example.com/mvv-nac", HomeTeam: "MVV", AwayTeam: "NAC", Date: date})
 	assert.NoError(t, err)
-	assert.Equal(t, map[int]int{1: 3, 2: 1, 3: 1}, result)
+
+	one := 1
+	assert.Equal(t, &match.Match{
+		ExternalID:        "https://example.com/mvv-nac",
+		Competition:       "Keuken Kampioen Divisie",
+		HomeTeam:          "MVV",
+		AwayTeam:          "NAC",
+		HomeGoals:         3,
+		AwayGoals:         1,
+		Date:              date,
+		Status:            match.Played,
+		HalfTimeHomeGoals: &one,
+		HalfTimeAwayGoals: &one,
+	}, m)
+}
+
+func TestEachMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	date := time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COALESCE\\(m.external_id, ''\\).*ORDER BY m.date, m.id").WithArgs(1, 2, "2022-08-01", "9999-12-31").WillReturnRows(
+		sqlmock.NewRows([]string{"external_id", "competition", "home", "away", "home_goals", "away_goals", "date", "status", "kickoff", "ht_home", "ht_away"}).
+			AddRow("", "Eredivisie", "Ajax", "PSV", 2, 0, date, "played", nil, nil, nil).
+			AddRow("", "Eredivisie", "AZ", "Ajax", nil, nil, date.AddDate(0, 0, 7), "postponed", nil, nil, nil))
+
+	matches := []match.Match{}
+	err = database.EachMatch(match.Filter{CompetitionID: 1, TeamID: 2, From: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)}, func(m match.Match) error {
+		matches = append(matches, m)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []match.Match{
+		{Competition: "Eredivisie", HomeTeam: "Ajax", AwayTeam: "PSV", HomeGoals: 2, Date: date, Status: match.Played},
+		{Competition: "Eredivisie", HomeTeam: "AZ", AwayTeam: "Ajax", Date: date.AddDate(0, 0, 7), Status: match.Postponed},
+	}, matches)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindMatchReturnsNilForNewMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("MVV").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id FROM teams WHERE name = \\$1").WithArgs("MVV").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("NAC").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id FROM teams WHERE name = \\$1").WithArgs("NAC").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id FROM matches WHERE home_team = \\$1").WithArgs(0, 0, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+
+	m, err := database.FindMatch(match.Match{HomeTeam: "MVV", AwayTeam: "NAC", Date: time.Now()})
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestBulkLoadMatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	date := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+	matches := []match.Match{
+		{ExternalID: "https://example.com/ajax-psv", Competition: "Eredivisie", HomeTeam: "Ajax", AwayTeam: "PSV", HomeGoals: 2, AwayGoals: 1, Date: date, Status: match.Played},
+		{Competition: "Eredivisie", HomeTeam: "Feyenoord", AwayTeam: "AZ", Date: date, Status: match.Postponed},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TEMPORARY TABLE match_staging").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	copyIn := mock.ExpectPrepare("COPY \"match_staging\"")
+	copyIn.ExpectExec().
+		WithArgs(0, "https://example.com/ajax-psv", "Eredivisie", "Ajax", "PSV", int64(2), int64(1), "2023-03-05", "played", nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().
+		WithArgs(1, nil, "Eredivisie", "Feyenoord", "AZ", nil, nil, "2023-03-05", "postponed", nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for range bulkMergeStatements(Postgres{}) {
+		mock.ExpectExec(".+").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM match_staging").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("DROP TABLE match_staging").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	stored, err := database.BulkLoadMatches(matches)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stored)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkLoadMatchesRollsBackOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TEMPORARY TABLE match_staging").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("COPY \"match_staging\"").
+		ExpectExec().
+		WillReturnError(errors.New("invalid input syntax"))
+	mock.ExpectRollback()
+
+	_, err = database.BulkLoadMatches([]match.Match{{Competition: "Eredivisie", HomeTeam: "Ajax", AwayTeam: "PSV", Status: match.Played}})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTeamStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("LOCK TABLE team_stats IN EXCLUSIVE MODE").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT competition_id, home_team, away_team, home_goals, away_goals, date FROM matches").
+		WillReturnRows(sqlmock.NewRows([]string{"competition_id", "home_team", "away_team", "home_goals", "away_goals", "date"}).
+			AddRow(1, 1, 2, 2, 1, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectQuery("SELECT competition_id, name, start_date, end_date FROM seasons").
+		WillReturnRows(sqlmock.NewRows([]string{"competition_id", "name", "start_date", "end_date"}))
+	mock.ExpectExec("DELETE FROM team_stats").
+		WillReturnResult(sqlmock.NewResult(0, 36))
+	insert := mock.ExpectPrepare("INSERT INTO team_stats")
+	insert.ExpectExec().
+		WithArgs(1, 1, "2022-2023", 1, 1, 0, 0, 2, 1, 1, 1, 0, 0, 2, 1, 0, 0, 0, 0, 0, 0, "W").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	insert.ExpectExec().
+		WithArgs(1, 2, "2022-2023", 1, 0, 0, 1, 1, 2, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 1, 2, "L").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = database.RefreshTeamStats()
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTeamStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectQuery("SELECT (.+) FROM team_stats WHERE competition_id = \\$1 AND season = \\$2").
+		WithArgs(1, "2022-2023").
+		WillReturnRows(teamStatsRows().
+			AddRow(1, 1, "2022-2023", 2, 1, 1, 0, 3, 2, 1, 1, 0, 0, 2, 1, 1, 0, 1, 0, 1, 1, "WD"))
+
+	stats, err := database.GetTeamStats(1, "2022-2023")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, 4, stats[0].Total.Points())
+	assert.Equal(t, 1, stats[0].Away.Drawn)
+	assert.Equal(t, "WD", stats[0].Form)
+}
+
+func teamStatsRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"competition_id", "team_id", "season",
+		"played", "won", "drawn", "lost", "goals_for", "goals_against",
+		"home_played", "home_won", "home_drawn", "home_lost", "home_goals_for", "home_goals_against",
+		"away_played", "away_won", "away_drawn", "away_lost", "away_goals_for", "away_goals_against", "form"})
+}
+
+func TestInsertOrUpdateMatchSkipsCorrectedMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectQuery("INSERT INTO competitions").WithArgs("Eredivisie").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("Home").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(1))
+	mock.ExpectQuery("SELECT team_id FROM team_aliases WHERE alias = \\$1").WithArgs("Away").WillReturnRows(sqlmock.NewRows([]string{"team_id"}).AddRow(2))
+
+	date := time.Date(2023, 4, 12, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id FROM matches WHERE home_team = \\$1 AND away_team = \\$2 AND date = \\$3").
+		WithArgs(1, 2, "2023-04-12").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec("UPDATE matches.+WHERE id = \\$12 AND NOT corrected").
+		WithArgs(9, 1, 2, 1, 1, "2023-04-12", nil, "played", nil, nil, nil, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = database.InsertOrUpdateMatch(match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    "Home",
+		AwayTeam:    "Away",
+		HomeGoals:   1,
+		AwayGoals:   1,
+		Date:        date,
+		Status:      match.Played,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+	deletedAt := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE matches SET deleted_at = \\$2, corrected = TRUE WHERE id = \\$1 AND deleted_at IS NULL").
+		WithArgs(7, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE matches SET deleted_at").
+		WithArgs(8, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, database.DeleteMatch(7, deletedAt))
+	assert.Equal(t, ErrMatchNotFound, database.DeleteMatch(8, deletedAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTeamInUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS (.+) FROM teams t WHERE t.id = \\$1 AND t.deleted_at IS NULL").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrTeamInUse, database.DeleteTeam(3, time.Now()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+	changedAt := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(changedAt, "admin", "Friendly", "match", 7, "delete", `{"ID":7}`, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = database.RecordChange(audit.Change{
+		ChangedAt: changedAt,
+		User:      "admin",
+		Reason:    "Friendly",
+		Entity:    audit.MatchEntity,
+		EntityID:  7,
+		Action:    audit.Delete,
+		Before:    `{"ID":7}`,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	attempts := 0
+	err := retry(time.Second, time.Millisecond, "testing", func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetryGivesUpAfterTimeout(t *testing.T) {
+	attempts := 0
+	err := retry(10*time.Millisecond, time.Millisecond, "testing", func() error {
+		attempts++
+		return errors.New("never")
+	})
+
+	assert.ErrorContains(t, err, "never")
+	assert.Greater(t, attempts, 1)
+	assert.Less(t, attempts, 10)
+}
+
+// latestMigration returns the version of the last embedded Postgres migration.
+func latestMigration(t *testing.T) uint {
+	src, err := iofs.New(dbfiles.Migrations, "migrations")
+	require.NoError(t, err)
+	defer src.Close()
+
+	versions, err := versions(src)
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+	return versions[len(versions)-1]
+}
+
+func TestCheckSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database := DB{Conn: db}
+	query := "SELECT version, dirty FROM schema_migrations LIMIT 1"
+	latest := latestMigration(t)
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest, false))
+	assert.NoError(t, database.CheckSchema())
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest, true))
+	assert.ErrorContains(t, database.CheckSchema(), fmt.Sprintf("migration %d failed", latest))
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest-1, false))
+	assert.ErrorContains(t, database.CheckSchema(), fmt.Sprintf("version %d instead of %d", latest-1, latest))
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}))
+	assert.ErrorContains(t, database.CheckSchema(), "hasn't been migrated")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	dbfiles "github.com/jqno/balGPT/db"
+	"github.com/lib/pq"
+)
+
+// Dialect covers what differs between the databases that DB can use. Everything else is
+// SQL that both Postgres and SQLite understand.
+type Dialect interface {
+	// WithScrapeLock runs fn while holding a lock, so that the app never scrapes twice at
+	// the same time.
+	WithScrapeLock(conn *sql.DB, fn func() error) error
+	// CopyRows inserts rows into a table, with as few round-trips as possible.
+	CopyRows(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error
+	// DeleteJoined returns a statement that deletes the rows of table, called alias, for
+	// which a row of other matches cond.
+	DeleteJoined(table, alias, other, cond string) string
+	// LockTable keeps other transactions from writing to table until tx ends.
+	LockTable(tx *sql.Tx, table string) error
+	// Time returns a timestamp the way it's stored.
+	Time(t time.Time) time.Time
+	// CheckSchema returns an error unless the database has been migrated to the latest
+	// version.
+	CheckSchema(conn *sql.DB) error
+}
+
+// Postgres is the Dialect of DBs that don't set one.
+type Postgres struct{}
+
+// WithScrapeLock holds an advisory lock, so that multiple instances of the app never
+// scrape at the same time.
+func (Postgres) WithScrapeLock(db *sql.DB, fn func() error) error {
+	ctx := context.Background()
+
+	// Advisory locks belong to a session, so lock and unlock on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", scrapeLockID); err != nil {
+		return fmt.Errorf("Error acquiring scrape lock: %v", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", scrapeLockID); err != nil {
+			log.Printf("Error releasing scrape lock: %v", err)
+		}
+	}()
+
+	return fn()
+}
+
+// CopyRows uses COPY.
+func (Postgres) CopyRows(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+
+	// Flush the buffered rows
+	_, err = stmt.Exec()
+	return err
+}
+
+func (Postgres) DeleteJoined(table, alias, other, cond string) string {
+	return fmt.Sprintf("DELETE FROM %s %s USING %s WHERE %s", table, alias, other, cond)
+}
+
+func (Postgres) LockTable(tx *sql.Tx, table string) error {
+	_, err := tx.Exec(fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", table))
+	return err
+}
+
+func (Postgres) Time(t time.Time) time.Time {
+	return t
+}
+
+func (Postgres) CheckSchema(conn *sql.DB) error {
+	return CheckSchema(conn, dbfiles.Migrations, "migrations")
+}
+
+func (db *DB) dialect() Dialect {
+	if db.Dialect == nil {
+		return Postgres{}
+	}
+	return db.Dialect
+}
+
+// timestamp returns t the way the dialect stores it.
+func (db *DB) timestamp(t time.Time) time.Time {
+	return db.dialect().Time(t)
+}
+
+func (db *DB) nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: db.timestamp(t), Valid: !t.IsZero()}
+}
+
+// textTime scans a timestamp that SQLite returns as text, because it only parses
+// columns that are declared as timestamps, which an aggregate isn't.
+type textTime struct {
+	t *time.Time
+}
+
+// sqliteTimeFormat is the format in which the SQLite driver writes timestamps.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func (s textTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*s.t = v
+		return nil
+	case string:
+		return s.parse(v)
+	case []byte:
+		return s.parse(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into a time", value)
+	}
+}
+
+func (s textTime) parse(value string) error {
+	t, err := time.Parse(sqliteTimeFormat, value)
+	if err != nil {
+		return err
+	}
+	*s.t = t
+	return nil
+}
@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// GetMatchRecord returns a match, even if it was deleted, or database.ErrMatchNotFound.
+func (db *DB) GetMatchRecord(id int) (*match.Record, error) {
+	db.lock()
+	defer db.unlock()
+
+	if _, ok := db.state.data.matches[id]; !ok {
+		return nil, database.ErrMatchNotFound
+	}
+	r := db.state.data.getMatchRecord(id)
+	return &r, nil
+}
+
+// GetMatchRecords returns the matches that the filter selects, including deleted ones,
+// in order of date.
+func (db *DB) GetMatchRecords(filter match.Filter) ([]match.Record, error) {
+	db.lock()
+	defer db.unlock()
+
+	ids := []int{}
+	for id, sm := range db.state.data.matches {
+		if filter.CompetitionID != 0 && sm.competitionID != filter.CompetitionID {
+			continue
+		}
+		if filter.TeamID != 0 && sm.homeTeamID != filter.TeamID && sm.awayTeamID != filter.TeamID {
+			continue
+		}
+		if (!filter.From.IsZero() && sm.date < dateString(filter.From)) || (!filter.To.IsZero() && sm.date > dateString(filter.To)) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := db.state.data.matches[ids[i]], db.state.data.matches[ids[j]]
+		if a.date != b.date {
+			return a.date < b.date
+		}
+		return ids[i] < ids[j]
+	})
+
+	records := make([]match.Record, 0, len(ids))
+	for _, id := range ids {
+		records = append(records, db.state.data.getMatchRecord(id))
+	}
+	return records, nil
+}
+
+// CreateMatch stores a match that an admin added. Like every corrected match, it's left
+// alone by the scraper.
+func (db *DB) CreateMatch(m match.Match) (int, error) {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	sm := d.correctedMatch(m)
+	if d.isDuplicate(0, sm) {
+		return 0, database.ErrDuplicate
+	}
+
+	d.nextMatchID++
+	d.matches[d.nextMatchID] = sm
+	delete(d.deletedTeams, sm.homeTeamID)
+	delete(d.deletedTeams, sm.awayTeamID)
+	return d.nextMatchID, nil
+}
+
+// UpdateMatch overwrites a match with a corrected version, which the scraper leaves
+// alone from then on.
+func (db *DB) UpdateMatch(id int, m match.Match) error {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	old, ok := d.matches[id]
+	if !ok {
+		return database.ErrMatchNotFound
+	}
+	sm := d.correctedMatch(m)
+	if d.isDuplicate(id, sm) {
+		return database.ErrDuplicate
+	}
+
+	sm.deletedAt = old.deletedAt
+	d.matches[id] = sm
+	delete(d.deletedTeams, sm.homeTeamID)
+	delete(d.deletedTeams, sm.awayTeamID)
+	return nil
+}
+
+// DeleteMatch marks a match as deleted. It stays deleted, because the scraper leaves
+// corrected matches alone.
+func (db *DB) DeleteMatch(id int, deletedAt time.Time) error {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	sm, ok := db.state.data.matches[id]
+	if !ok || !sm.deletedAt.IsZero() {
+		return database.ErrMatchNotFound
+	}
+
+	sm.corrected = true
+	sm.deletedAt = deletedAt
+	db.state.data.matches[id] = sm
+	return nil
+}
+
+// CreateTeam adds a team, or brings back a deleted team with the same name.
+func (db *DB) CreateTeam(name string) (int, error) {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	if _, ok := d.aliases[name]; ok {
+		return 0, database.ErrDuplicate
+	}
+	for id, n := range d.teams {
+		if n != name {
+			continue
+		}
+		if _, deleted := d.deletedTeams[id]; !deleted {
+			return 0, database.ErrDuplicate
+		}
+		delete(d.deletedTeams, id)
+		return id, nil
+	}
+
+	return d.teamID(name), nil
+}
+
+// RenameTeam renames a team. The old name becomes an alias, so that the scraper still
+// recognizes the team.
+func (db *DB) RenameTeam(id int, name string) error {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	oldName, ok := d.teams[id]
+	if _, deleted := d.deletedTeams[id]; !ok || deleted {
+		return database.ErrTeamNotFound
+	}
+	if oldName == name {
+		return nil
+	}
+	if otherID, ok := d.lookupTeamID(name); ok && otherID != id {
+		return database.ErrDuplicate
+	}
+
+	delete(d.aliases, name)
+	d.teams[id] = name
+	d.aliases[oldName] = id
+	return nil
+}
+
+// DeleteTeam marks a team as deleted. Only teams without matches, other than deleted
+// ones, can be deleted; otherwise, it returns database.ErrTeamInUse.
+func (db *DB) DeleteTeam(id int, deletedAt time.Time) error {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	_, ok := d.teams[id]
+	if _, deleted := d.deletedTeams[id]; !ok || deleted {
+		return database.ErrTeamNotFound
+	}
+	for _, sm := range d.matches {
+		if (sm.homeTeamID == id || sm.awayTeamID == id) && sm.deletedAt.IsZero() {
+			return database.ErrTeamInUse
+		}
+	}
+
+	d.deletedTeams[id] = deletedAt
+	return nil
+}
+
+// RecordChange adds a correction to the audit log.
+func (db *DB) RecordChange(c audit.Change) error {
+	db.lock()
+	defer db.unlock()
+
+	c.ID = len(db.state.data.changes) + 1
+	db.state.data.changes = append(db.state.data.changes, c)
+	return nil
+}
+
+// GetChanges returns the most recent corrections, newest first.
+func (db *DB) GetChanges(limit int) ([]audit.Change, error) {
+	db.lock()
+	defer db.unlock()
+
+	changes := append([]audit.Change(nil), db.state.data.changes...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		if !changes[i].ChangedAt.Equal(changes[j].ChangedAt) {
+			return changes[i].ChangedAt.After(changes[j].ChangedAt)
+		}
+		return changes[i].ID > changes[j].ID
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (d *data) correctedMatch(m match.Match) storedMatch {
+	sm := storedMatch{
+		competitionID: d.competitionID(m.Competition),
+		homeTeamID:    d.teamID(m.HomeTeam),
+		awayTeamID:    d.teamID(m.AwayTeam),
+		date:          dateString(m.Date),
+		m:             m,
+		corrected:     true,
+	}
+	if !m.HasScore() {
+		sm.m.HomeGoals, sm.m.AwayGoals = 0, 0
+	}
+	return sm
+}
+
+// isDuplicate tells whether a match other than exceptID has the same external ID, or the
+// same teams and date.
+func (d *data) isDuplicate(exceptID int, sm storedMatch) bool {
+	for id, other := range d.matches {
+		if id == exceptID {
+			continue
+		}
+		if sm.m.ExternalID != "" && other.m.ExternalID == sm.m.ExternalID {
+			return true
+		}
+		if other.homeTeamID == sm.homeTeamID && other.awayTeamID == sm.awayTeamID && other.date == sm.date {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *data) getMatchRecord(matchID int) match.Record {
+	sm := d.matches[matchID]
+	return match.Record{
+		ID:        matchID,
+		Match:     d.getMatch(matchID),
+		Corrected: sm.corrected,
+		DeletedAt: sm.deletedAt,
+	}
+}
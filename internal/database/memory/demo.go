@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// demoMatches contains one and a half made-up Eredivisie seasons. The scores are
+// generated, not real.
+//
+//go:embed demo.csv
+var demoMatches []byte
+
+// LoadDemo fills the DB with the demo matches. They are moved by whole years, so that
+// the last season in the demo data is the season that is running now.
+func (db *DB) LoadDemo(now time.Time) error {
+	matches, err := parseDemoMatches(demoMatches)
+	if err != nil {
+		return fmt.Errorf("Error loading demo data: %v", err)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	last := matches[len(matches)-1].Date
+	lastSeason := db.seasonBoundary().SeasonAt(last)
+	currentSeason := db.seasonBoundary().SeasonAt(now)
+	years := currentSeason.Start.Year() - lastSeason.Start.Year()
+	for i := range matches {
+		matches[i].Date = matches[i].Date.AddDate(years, 0, 0)
+	}
+
+	if _, err := db.BulkLoadMatches(matches); err != nil {
+		return err
+	}
+	return db.RefreshTeamStats()
+}
+
+// parseDemoMatches parses matches in the form
+// "competition,date,home_team,away_team,home_goals,away_goals", after a header.
+func parseDemoMatches(data []byte) ([]match.Match, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]match.Match, 0, len(records))
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		if len(record) != 6 {
+			return nil, fmt.Errorf("line %d: expected 6 fields, got %d", i+1, len(record))
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", record[1], dates.Amsterdam)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		homeGoals, err := strconv.Atoi(record[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		awayGoals, err := strconv.Atoi(record[5])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		matches = append(matches, match.Match{
+			Competition: record[0],
+			Date:        date,
+			HomeTeam:    record[2],
+			AwayTeam:    record[3],
+			HomeGoals:   homeGoals,
+			AwayGoals:   awayGoals,
+			Status:      match.Played,
+		})
+	}
+
+	return matches, nil
+}
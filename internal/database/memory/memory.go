@@ -0,0 +1,846 @@
+// Package memory implements database.Store in memory, for demos and tests that need a
+// realistic fake instead of a mock.
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/competition"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/team"
+	"github.com/jqno/balGPT/internal/teamstats"
+)
+
+// DB is the Store that keeps everything in memory. Lookups that find nothing return
+// sql.ErrNoRows, like the other Stores do.
+type DB struct {
+	// SeasonBoundary is the start of seasons of competitions that don't register the
+	// dates of their seasons. It defaults to season.DefaultBoundary.
+	SeasonBoundary season.Boundary
+
+	state *state
+	// inTx is set on the DB that InTransaction hands out
+	inTx bool
+}
+
+type state struct {
+	mu         sync.Mutex
+	scrapeLock sync.Mutex
+	// txLock makes transactions run one after the other, so that a rollback doesn't
+	// undo the work of another transaction
+	txLock sync.Mutex
+
+	data data
+}
+
+// data is everything that a transaction can roll back.
+type data struct {
+	scrapeRuns    []scraperun.Run
+	pageContents  map[string][]byte
+	archivedPages []archivedPage
+	sourceRows    map[string]int
+
+	competitions map[int]string
+	teams        map[int]string
+	deletedTeams map[int]time.Time
+	aliases      map[string]int
+	matches      map[int]storedMatch
+	seasons      map[int][]season.Season
+	teamStats    []teamstats.Stats
+	changes      []audit.Change
+
+	nextCompetitionID int
+	nextTeamID        int
+	nextMatchID       int
+	// dataVersion goes up with every change to the matches or teams
+	dataVersion int64
+}
+
+type archivedPage struct {
+	url         string
+	competition string
+	hash        string
+	fetchedAt   time.Time
+}
+
+// storedMatch refers to its competition and teams by ID, so that it follows them when
+// teams are merged.
+type storedMatch struct {
+	competitionID int
+	homeTeamID    int
+	awayTeamID    int
+	date          string
+	m             match.Match
+	corrected     bool
+	// deletedAt is zero unless the match was deleted
+	deletedAt time.Time
+}
+
+// counts tells whether the match counts for statistics: it has a score and wasn't
+// deleted.
+func (sm storedMatch) counts() bool {
+	return sm.m.HasScore() && sm.deletedAt.IsZero()
+}
+
+// result returns the match as the statistics see it.
+func (sm storedMatch) result() teamstats.Result {
+	date, _ := time.Parse("2006-01-02", sm.date)
+	return teamstats.Result{
+		CompetitionID: sm.competitionID,
+		HomeTeamID:    sm.homeTeamID,
+		AwayTeamID:    sm.awayTeamID,
+		HomeGoals:     sm.m.HomeGoals,
+		AwayGoals:     sm.m.AwayGoals,
+		Date:          date,
+	}
+}
+
+// New returns an empty DB, which knows only the Eredivisie, like a freshly migrated
+// database.
+func New() *DB {
+	db := &DB{state: &state{data: data{
+		pageContents: map[string][]byte{},
+		sourceRows:   map[string]int{},
+		competitions: map[int]string{},
+		teams:        map[int]string{},
+		deletedTeams: map[int]time.Time{},
+		aliases:      map[string]int{},
+		matches:      map[int]storedMatch{},
+		seasons:      map[int][]season.Season{},
+	}}}
+	db.state.data.competitionID("Eredivisie")
+	return db
+}
+
+// RegisterSeason registers the dates of a season that doesn't start on the
+// SeasonBoundary, like a row in the seasons table.
+func (db *DB) RegisterSeason(competitionID int, s season.Season) error {
+	db.lock()
+	defer db.unlock()
+
+	db.state.data.seasons[competitionID] = append(db.state.data.seasons[competitionID], s)
+	return nil
+}
+
+// DataVersion returns a number that goes up with every change to the matches or teams.
+func (db *DB) DataVersion() (int64, error) {
+	db.lock()
+	defer db.unlock()
+
+	return db.state.data.dataVersion, nil
+}
+
+func (db *DB) lock() {
+	db.state.mu.Lock()
+}
+
+func (db *DB) unlock() {
+	db.state.mu.Unlock()
+}
+
+func (db *DB) WithScrapeLock(fn func() error) error {
+	db.state.scrapeLock.Lock()
+	defer db.state.scrapeLock.Unlock()
+
+	return fn()
+}
+
+// InTransaction runs fn with a Store whose changes are undone if fn returns an error.
+// Calling InTransaction on a DB that is already in a transaction reuses that
+// transaction.
+func (db *DB) InTransaction(fn func(tx database.Store) error) error {
+	if db.inTx {
+		return fn(db)
+	}
+
+	db.state.txLock.Lock()
+	defer db.state.txLock.Unlock()
+
+	db.lock()
+	snapshot := db.state.data.clone()
+	db.unlock()
+
+	txDB := *db
+	txDB.inTx = true
+	if err := fn(&txDB); err != nil {
+		db.lock()
+		db.state.data = snapshot
+		db.unlock()
+		return err
+	}
+	return nil
+}
+
+func (db *DB) Ping() error {
+	return nil
+}
+
+func (db *DB) Close() error {
+	return nil
+}
+
+// GetLastScrape returns the time at which the last successful scrape of a source
+// finished, or the zero time if there never was one.
+func (db *DB) GetLastScrape() (time.Time, error) {
+	db.lock()
+	defer db.unlock()
+
+	lastScrape := time.Time{}
+	for _, run := range db.state.data.scrapeRuns {
+		if run.Succeeded() && run.FinishedAt.After(lastScrape) {
+			lastScrape = run.FinishedAt
+		}
+	}
+	return lastScrape, nil
+}
+
+// RecordScrapeRuns stores what happened when sources were scraped.
+func (db *DB) RecordScrapeRuns(runs []scraperun.Run) error {
+	db.lock()
+	defer db.unlock()
+
+	for _, run := range runs {
+		run.ID = len(db.state.data.scrapeRuns) + 1
+		db.state.data.scrapeRuns = append(db.state.data.scrapeRuns, run)
+	}
+	return nil
+}
+
+// GetScrapeRuns returns the most recent scrapes of sources, newest first.
+func (db *DB) GetScrapeRuns(limit int) ([]scraperun.Run, error) {
+	db.lock()
+	defer db.unlock()
+
+	runs := append([]scraperun.Run(nil), db.state.data.scrapeRuns...)
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// ArchivePage stores a downloaded page. Pages with the same contents are stored only
+// once, but every download is recorded.
+func (db *DB) ArchivePage(competition, url string, body []byte, fetchedAt time.Time) error {
+	db.lock()
+	defer db.unlock()
+
+	hash := archive.Hash(body)
+	if _, ok := db.state.data.pageContents[hash]; !ok {
+		db.state.data.pageContents[hash] = append([]byte(nil), body...)
+	}
+	db.state.data.archivedPages = append(db.state.data.archivedPages, archivedPage{
+		url:         url,
+		competition: competition,
+		hash:        hash,
+		fetchedAt:   fetchedAt,
+	})
+	return nil
+}
+
+// EachArchivedPage calls fn for every distinct page that was downloaded since the given
+// time, in the order in which they were first downloaded.
+func (db *DB) EachArchivedPage(since time.Time, fn func(page archive.Page) error) error {
+	db.lock()
+	pages := []archive.Page{}
+	first := map[archivedPage]int{}
+	for _, p := range db.state.data.archivedPages {
+		if p.fetchedAt.Before(since) {
+			continue
+		}
+		key := archivedPage{url: p.url, competition: p.competition, hash: p.hash}
+		if i, ok := first[key]; ok {
+			if p.fetchedAt.Before(pages[i].FetchedAt) {
+				pages[i].FetchedAt = p.fetchedAt
+			}
+			continue
+		}
+		first[key] = len(pages)
+		pages = append(pages, archive.Page{
+			Hash:        p.hash,
+			URL:         p.url,
+			Competition: p.competition,
+			Body:        db.state.data.pageContents[p.hash],
+			FetchedAt:   p.fetchedAt,
+		})
+	}
+	db.unlock()
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].FetchedAt.Before(pages[j].FetchedAt)
+	})
+
+	for _, page := range pages {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMatches deletes all matches of a competition that were played between the
+// given dates, inclusive, except the ones that were corrected by an admin.
+func (db *DB) DeleteMatches(competition string, from, to time.Time) (int64, error) {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	competitionID, ok := db.state.data.findCompetition(competition)
+	if !ok {
+		return 0, nil
+	}
+
+	var deleted int64
+	for id, sm := range db.state.data.matches {
+		if sm.competitionID == competitionID && inRange(sm.date, from, to) && !sm.corrected {
+			delete(db.state.data.matches, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// GetSourceRowCount returns the number of rows found on the given page during the last
+// successful scrape, or 0 if it wasn't scraped before.
+func (db *DB) GetSourceRowCount(url string) (int, error) {
+	db.lock()
+	defer db.unlock()
+
+	return db.state.data.sourceRows[url], nil
+}
+
+func (db *DB) UpdateSourceRowCount(url string, rows int, scrapeTime time.Time) error {
+	db.lock()
+	defer db.unlock()
+
+	db.state.data.sourceRows[url] = rows
+	return nil
+}
+
+func (db *DB) FetchTeamsFromDB() ([]team.Team, error) {
+	db.lock()
+	defer db.unlock()
+
+	teams := []team.Team{}
+	for id, name := range db.state.data.teams {
+		if _, deleted := db.state.data.deletedTeams[id]; deleted {
+			continue
+		}
+		teams = append(teams, team.Team{ID: id, Name: name})
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+	return teams, nil
+}
+
+func (db *DB) FetchCompetitionsFromDB() ([]competition.Competition, error) {
+	db.lock()
+	defer db.unlock()
+
+	competitions := []competition.Competition{}
+	for id, name := range db.state.data.competitions {
+		competitions = append(competitions, competition.Competition{ID: id, Name: name})
+	}
+	sort.Slice(competitions, func(i, j int) bool { return competitions[i].Name < competitions[j].Name })
+	return competitions, nil
+}
+
+func (db *DB) GetCompetitionID(name string) (int, error) {
+	db.lock()
+	defer db.unlock()
+
+	competitionID, ok := db.state.data.findCompetition(name)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return competitionID, nil
+}
+
+func (db *DB) InsertOrUpdateMatch(m match.Match) error {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	db.state.data.insertOrUpdateMatch(m)
+	return nil
+}
+
+// BulkLoadMatches stores many matches at once. It returns the number of matches that
+// were stored; a match that occurs more than once is counted once.
+func (db *DB) BulkLoadMatches(matches []match.Match) (int64, error) {
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	stored := make(map[int]bool)
+	for _, m := range matches {
+		if matchID := db.state.data.insertOrUpdateMatch(m); matchID != 0 {
+			stored[matchID] = true
+		}
+	}
+	return int64(len(stored)), nil
+}
+
+// FindMatch returns the stored version of the given match, or nil if it hasn't been
+// stored yet.
+func (db *DB) FindMatch(m match.Match) (*match.Match, error) {
+	db.lock()
+	defer db.unlock()
+
+	// Unknown teams can still be part of a match that's found by its external ID
+	homeTeamID, _ := db.state.data.lookupTeamID(m.HomeTeam)
+	awayTeamID, _ := db.state.data.lookupTeamID(m.AwayTeam)
+
+	matchID, ok := db.state.data.findMatchID(m.ExternalID, homeTeamID, awayTeamID, dateString(m.Date))
+	if !ok {
+		return nil, nil
+	}
+
+	found := db.state.data.getMatch(matchID)
+	return &found, nil
+}
+
+// EachMatch calls fn for every match that the filter selects, in order of date.
+func (db *DB) EachMatch(filter match.Filter, fn func(m match.Match) error) error {
+	from, to := "0001-01-01", "9999-12-31"
+	if !filter.From.IsZero() {
+		from = dateString(filter.From)
+	}
+	if !filter.To.IsZero() {
+		to = dateString(filter.To)
+	}
+
+	db.lock()
+	ids := []int{}
+	for id, sm := range db.state.data.matches {
+		if filter.CompetitionID != 0 && sm.competitionID != filter.CompetitionID {
+			continue
+		}
+		if filter.TeamID != 0 && sm.homeTeamID != filter.TeamID && sm.awayTeamID != filter.TeamID {
+			continue
+		}
+		if sm.date < from || sm.date > to || !sm.deletedAt.IsZero() {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := db.state.data.matches[ids[i]], db.state.data.matches[ids[j]]
+		if a.date != b.date {
+			return a.date < b.date
+		}
+		return ids[i] < ids[j]
+	})
+	matches := make([]match.Match, 0, len(ids))
+	for _, id := range ids {
+		matches = append(matches, db.state.data.getMatch(id))
+	}
+	db.unlock()
+
+	for _, m := range matches {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) GetTeamID(teamName string) (int, error) {
+	db.lock()
+	defer db.unlock()
+
+	teamID, ok := db.state.data.lookupTeamID(teamName)
+	if _, deleted := db.state.data.deletedTeams[teamID]; !ok || deleted {
+		return 0, sql.ErrNoRows
+	}
+	return teamID, nil
+}
+
+// MergeTeams moves all matches and aliases of the source team to the target team,
+// registers the source team's name as an alias of the target team, and deletes the
+// source team. Deleted teams can't be merged, nor be merged into.
+func (db *DB) MergeTeams(sourceID, targetID int) error {
+	if sourceID == targetID {
+		return fmt.Errorf("Cannot merge team %d with itself", sourceID)
+	}
+
+	db.lock()
+	defer db.unlock()
+	db.state.data.dataVersion++
+
+	d := &db.state.data
+	sourceName, ok := d.teams[sourceID]
+	if _, deleted := d.deletedTeams[sourceID]; !ok || deleted {
+		return database.ErrTeamNotFound
+	}
+	targetName, ok := d.teams[targetID]
+	if _, deleted := d.deletedTeams[targetID]; !ok || deleted {
+		return database.ErrTeamNotFound
+	}
+
+	replace := func(teamID int) int {
+		if teamID == sourceID {
+			return targetID
+		}
+		return teamID
+	}
+
+	// Both teams may have a row for the same match; keep the target team's
+	for id, sm := range d.matches {
+		if sm.homeTeamID != sourceID && sm.awayTeamID != sourceID {
+			continue
+		}
+		if _, ok := d.findMatchByKey(replace(sm.homeTeamID), replace(sm.awayTeamID), sm.date, id); ok {
+			delete(d.matches, id)
+		}
+	}
+
+	for id, sm := range d.matches {
+		sm.homeTeamID = replace(sm.homeTeamID)
+		sm.awayTeamID = replace(sm.awayTeamID)
+		d.matches[id] = sm
+	}
+	for alias, teamID := range d.aliases {
+		d.aliases[alias] = replace(teamID)
+	}
+	d.aliases[sourceName] = targetID
+	delete(d.teams, sourceID)
+	delete(d.deletedTeams, sourceID)
+
+	log.Printf("Merged team %d (%s) into %d (%s)", sourceID, sourceName, targetID, targetName)
+
+	return nil
+}
+
+func (db *DB) AverageGoalsInLastMatches(competitionID int, teamID int, numberOfMatches int) (float64, error) {
+	if teamID == -1 {
+		return 0, nil
+	}
+
+	db.lock()
+	defer db.unlock()
+
+	type result struct {
+		goals int
+		date  string
+	}
+	results := []result{}
+	for _, sm := range db.state.data.matches {
+		if sm.competitionID != competitionID || !sm.counts() {
+			continue
+		}
+		if sm.homeTeamID == teamID {
+			results = append(results, result{goals: sm.m.HomeGoals, date: sm.date})
+		}
+		if sm.awayTeamID == teamID {
+			results = append(results, result{goals: sm.m.AwayGoals, date: sm.date})
+		}
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].date > results[j].date })
+	if len(results) > numberOfMatches {
+		results = results[:numberOfMatches]
+	}
+
+	total := 0
+	for _, r := range results {
+		total += r.goals
+	}
+	return float64(total) / float64(len(results)), nil
+}
+
+func (db *DB) LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error) {
+	db.lock()
+	defer db.unlock()
+
+	var last *storedMatch
+	for _, sm := range db.state.data.matches {
+		sm := sm
+		if sm.competitionID != competitionID || sm.homeTeamID != homeTeamID || sm.awayTeamID != awayTeamID || !sm.counts() {
+			continue
+		}
+		if last == nil || sm.date > last.date {
+			last = &sm
+		}
+	}
+	if last == nil {
+		return 0, 0, nil
+	}
+
+	return last.m.HomeGoals, last.m.AwayGoals, nil
+}
+
+// GetCurrentSeasonLeaderboard returns the points of each team in the current season,
+// from the team statistics.
+func (db *DB) GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error) {
+	currentSeason, err := db.SeasonAt(competitionID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := db.GetTeamStats(competitionID, currentSeason.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return teamstats.Leaderboard(stats), nil
+}
+
+// GetLeaderboard returns the points of each team, based on the matches of the
+// competition that were played between from and to, inclusive.
+func (db *DB) GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error) {
+	db.lock()
+	defer db.unlock()
+
+	results := []teamstats.Result{}
+	for _, sm := range db.state.data.matches {
+		if sm.competitionID != competitionID || !sm.counts() || !inRange(sm.date, from, to) {
+			continue
+		}
+		results = append(results, sm.result())
+	}
+
+	return teamstats.Points(results), nil
+}
+
+// GetSeason returns the season of the competition with the given name, such as
+// "2022-2023". Registered seasons take precedence; otherwise, seasons start on the
+// configured SeasonBoundary.
+func (db *DB) GetSeason(competitionID int, name string) (season.Season, error) {
+	db.lock()
+	defer db.unlock()
+
+	for _, s := range db.state.data.seasons[competitionID] {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+
+	return db.seasonBoundary().Season(name)
+}
+
+// SeasonAt returns the season of the competition that is running at the given time.
+// Registered seasons take precedence; otherwise, seasons start on the configured
+// SeasonBoundary.
+func (db *DB) SeasonAt(competitionID int, t time.Time) (season.Season, error) {
+	db.lock()
+	defer db.unlock()
+
+	return db.calendar().SeasonAt(competitionID, t), nil
+}
+
+// RefreshTeamStats recomputes the statistics of all teams from the played matches.
+func (db *DB) RefreshTeamStats() error {
+	db.lock()
+	defer db.unlock()
+
+	results := []teamstats.Result{}
+	for _, sm := range db.state.data.matches {
+		if !sm.counts() {
+			continue
+		}
+		results = append(results, sm.result())
+	}
+	// Matches on the same day are in the order in which they were stored
+	sort.Slice(results, func(i, j int) bool { return results[i].Date.Before(results[j].Date) })
+
+	calendar := db.calendar()
+	db.state.data.teamStats = teamstats.Compute(results, func(competitionID int, date time.Time) string {
+		return calendar.SeasonAt(competitionID, date).Name
+	})
+	return nil
+}
+
+// GetTeamStats returns the statistics of the teams in a season of a competition, in
+// the order of the league table.
+func (db *DB) GetTeamStats(competitionID int, seasonName string) ([]teamstats.Stats, error) {
+	db.lock()
+	defer db.unlock()
+
+	stats := []teamstats.Stats{}
+	for _, s := range db.state.data.teamStats {
+		if s.CompetitionID == competitionID && s.Season == seasonName {
+			stats = append(stats, s)
+		}
+	}
+
+	teamstats.Sort(stats)
+	return stats, nil
+}
+
+// calendar returns the registered seasons of all competitions.
+func (db *DB) calendar() season.Calendar {
+	return season.Calendar{Boundary: db.seasonBoundary(), Registered: db.state.data.seasons}
+}
+
+func (db *DB) seasonBoundary() season.Boundary {
+	if db.SeasonBoundary == (season.Boundary{}) {
+		return season.DefaultBoundary
+	}
+	return db.SeasonBoundary
+}
+
+func (d *data) clone() data {
+	c := *d
+	c.scrapeRuns = append([]scraperun.Run(nil), d.scrapeRuns...)
+	c.archivedPages = append([]archivedPage(nil), d.archivedPages...)
+	c.teamStats = append([]teamstats.Stats(nil), d.teamStats...)
+	c.changes = append([]audit.Change(nil), d.changes...)
+	c.seasons = map[int][]season.Season{}
+	for competitionID, seasons := range d.seasons {
+		c.seasons[competitionID] = append([]season.Season(nil), seasons...)
+	}
+	c.pageContents = copyMap(d.pageContents)
+	c.sourceRows = copyMap(d.sourceRows)
+	c.competitions = copyMap(d.competitions)
+	c.teams = copyMap(d.teams)
+	c.deletedTeams = copyMap(d.deletedTeams)
+	c.aliases = copyMap(d.aliases)
+	c.matches = copyMap(d.matches)
+	return c
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (d *data) findCompetition(name string) (int, bool) {
+	for id, n := range d.competitions {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func (d *data) competitionID(name string) int {
+	if id, ok := d.findCompetition(name); ok {
+		return id
+	}
+	d.nextCompetitionID++
+	d.competitions[d.nextCompetitionID] = name
+	return d.nextCompetitionID
+}
+
+// lookupTeamID finds a team by one of its aliases first, and by its name otherwise. It
+// also finds deleted teams, which the scraper restores when they play again.
+func (d *data) lookupTeamID(name string) (int, bool) {
+	if id, ok := d.aliases[name]; ok {
+		return id, true
+	}
+	for id, n := range d.teams {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func (d *data) teamID(name string) int {
+	if id, ok := d.lookupTeamID(name); ok {
+		return id
+	}
+	d.nextTeamID++
+	d.teams[d.nextTeamID] = name
+	return d.nextTeamID
+}
+
+// findMatchID finds a match by its external ID, and by its teams and date otherwise,
+// which also finds a match that was stored under another external ID.
+func (d *data) findMatchID(externalID string, homeTeamID, awayTeamID int, date string) (int, bool) {
+	if externalID != "" {
+		for id, sm := range d.matches {
+			if sm.m.ExternalID == externalID {
+				return id, true
+			}
+		}
+	}
+	return d.findMatchByKey(homeTeamID, awayTeamID, date, 0)
+}
+
+// findMatchByKey finds a match other than exceptID by its teams and date, which are
+// unique.
+func (d *data) findMatchByKey(homeTeamID, awayTeamID int, date string, exceptID int) (int, bool) {
+	for id, sm := range d.matches {
+		if id != exceptID && sm.homeTeamID == homeTeamID && sm.awayTeamID == awayTeamID && sm.date == date {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// insertOrUpdateMatch returns the ID of the stored match, or 0 if it wasn't stored.
+func (d *data) insertOrUpdateMatch(m match.Match) int {
+	sm := storedMatch{
+		competitionID: d.competitionID(m.Competition),
+		homeTeamID:    d.teamID(m.HomeTeam),
+		awayTeamID:    d.teamID(m.AwayTeam),
+		date:          dateString(m.Date),
+		m:             m,
+	}
+	if !m.HasScore() {
+		sm.m.HomeGoals, sm.m.AwayGoals = 0, 0
+	}
+
+	// A match is identified by its external ID, so it's updated even if it has been
+	// rescheduled, and by its teams and date otherwise
+	matchID, found := d.findMatchID(m.ExternalID, sm.homeTeamID, sm.awayTeamID, sm.date)
+	if found && m.ExternalID == "" {
+		sm.m.ExternalID = d.matches[matchID].m.ExternalID
+	}
+	if found && d.matches[matchID].corrected {
+		// Matches that were corrected by an admin are left alone
+		return 0
+	}
+	if !found {
+		d.nextMatchID++
+		matchID = d.nextMatchID
+	}
+
+	d.matches[matchID] = sm
+	// Deleted teams that play a match again are brought back
+	delete(d.deletedTeams, sm.homeTeamID)
+	delete(d.deletedTeams, sm.awayTeamID)
+	return matchID
+}
+
+func (d *data) getMatch(matchID int) match.Match {
+	sm := d.matches[matchID]
+	m := sm.m
+	m.Competition = d.competitions[sm.competitionID]
+	m.HomeTeam = d.teams[sm.homeTeamID]
+	m.AwayTeam = d.teams[sm.awayTeamID]
+	m.Date, _ = time.Parse("2006-01-02", sm.date)
+	return m
+}
+
+// dateString formats a time as a date in its own location, so that dates compare like
+// DATE columns do.
+func dateString(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func inRange(date string, from, to time.Time) bool {
+	return date >= dateString(from) && date <= dateString(to)
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/database/storetest"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		return New()
+	})
+}
+
+func TestLoadDemo(t *testing.T) {
+	db := New()
+
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, dates.Amsterdam)
+	require.NoError(t, db.LoadDemo(now))
+
+	teams, err := db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	assert.Len(t, teams, 18)
+
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	current, err := db.SeasonAt(competitionID, now)
+	require.NoError(t, err)
+	leaderboard, err := db.GetLeaderboard(competitionID, current.Start, current.End)
+	require.NoError(t, err)
+	assert.Len(t, leaderboard, 18)
+}
@@ -1,26 +1,119 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
-	"path/filepath"
+	"os"
 
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	dbfiles "github.com/jqno/balGPT/db"
 )
 
-func RunMigrations(conn database.Driver, appBaseDir string) error {
-	migrationsPath := filepath.Join(appBaseDir, "db", "migrations")
+// Migrations manages the schema of a database, with the migrations that are embedded in
+// the binary.
+type Migrations struct {
+	m      *migrate.Migrate
+	source source.Driver
+}
+
+// MigrationStatus tells which migration was applied last, and which one is the latest.
+// Dirty means that the last migration failed halfway, and has to be fixed by hand and
+// forced.
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+}
+
+// NewMigrations returns the migrations in the given directory of files, for a database
+// of the given kind, such as "postgres".
+func NewMigrations(driver migratedb.Driver, databaseName string, files fs.FS, dir string) (*Migrations, error) {
+	src, err := iofs.New(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migrations: %v", err)
+	}
 
-	migrationUrl := fmt.Sprintf("file://%s", migrationsPath)
+	m, err := migrate.NewWithInstance("iofs", src, databaseName, driver)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create migration instance: %v", err)
+	}
+
+	return &Migrations{m: m, source: src}, nil
+}
 
-	m, err := migrate.NewWithDatabaseInstance(migrationUrl, "postgres", conn)
+// PostgresMigrations returns the migrations of the Postgres database. They run on a
+// connection of their own, which closing them releases; conn stays open.
+func PostgresMigrations(conn *sql.DB) (*Migrations, error) {
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to create migration instance: %v", err)
+		return nil, fmt.Errorf("unable to get a connection for the migrations: %v", err)
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+	// Unlike WithInstance, WithConnection doesn't close conn when the driver is closed
+	driver, err := postgres.WithConnection(ctx, c, &postgres.Config{})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("unable to create postgres driver instance: %v", err)
+	}
+
+	return NewMigrations(driver, "postgres", dbfiles.Migrations, "migrations")
+}
+
+// CheckSchema returns an error unless all migrations in the given directory of files
+// have been applied to conn, and the last one succeeded.
+func CheckSchema(conn *sql.DB, files fs.FS, dir string) error {
+	src, err := iofs.New(files, dir)
+	if err != nil {
+		return fmt.Errorf("unable to read migrations: %v", err)
+	}
+	defer src.Close()
+
+	versions, err := versions(src)
+	if err != nil {
+		return fmt.Errorf("unable to read migrations: %v", err)
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	latest := versions[len(versions)-1]
+
+	var version uint
+	var dirty bool
+	err = conn.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return errors.New("the database hasn't been migrated")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read schema version: %v", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d failed", version)
+	}
+	if version != latest {
+		return fmt.Errorf("the schema is at version %d instead of %d", version, latest)
+	}
+	return nil
+}
+
+// CheckSchema returns an error unless the database has been migrated to the latest
+// version.
+func (db *DB) CheckSchema() error {
+	return db.dialect().CheckSchema(db.Conn)
+}
+
+// Up applies all migrations that haven't been applied yet.
+func (m *Migrations) Up() error {
+	if err := m.m.Up(); err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("migration failed: %v", err)
 	}
 
@@ -28,3 +121,105 @@ func RunMigrations(conn database.Driver, appBaseDir string) error {
 
 	return nil
 }
+
+// Down rolls back the last n migrations.
+func (m *Migrations) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of migrations to roll back: %d", n)
+	}
+
+	// Steps would roll back everything before complaining that there's too little
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if n > applied {
+		return fmt.Errorf("cannot roll back %d migrations: only %d have been applied", n, applied)
+	}
+
+	if err := m.m.Steps(-n); err != nil {
+		return fmt.Errorf("rollback failed: %v", err)
+	}
+
+	log.Printf("Rolled back %d migrations", n)
+
+	return nil
+}
+
+// Force sets the version, without running any migrations, and marks it as clean. Use it
+// after fixing a failed migration by hand.
+func (m *Migrations) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("forcing version %d failed: %v", version, err)
+	}
+	return nil
+}
+
+// Status returns the version of the database, and the latest version that is available.
+func (m *Migrations) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	version, dirty, err := m.m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return status, err
+	}
+	status.Version = version
+	status.Dirty = dirty
+
+	versions, err := versions(m.source)
+	if err != nil {
+		return status, err
+	}
+	if len(versions) > 0 {
+		status.Latest = versions[len(versions)-1]
+	}
+
+	return status, nil
+}
+
+// applied returns the number of migrations that have been applied.
+func (m *Migrations) applied() (int, error) {
+	version, _, err := m.m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	versions, err := versions(m.source)
+	if err != nil {
+		return 0, err
+	}
+	applied := 0
+	for _, v := range versions {
+		if v <= version {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// versions returns the versions of all migrations in src, in order.
+func versions(src source.Driver) ([]uint, error) {
+	versions := []uint{}
+	version, err := src.First()
+	for err == nil {
+		versions = append(versions, version)
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Close releases the migrations. Some drivers, such as SQLite's, close the database
+// connection as well.
+func (m *Migrations) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return dbErr
+}
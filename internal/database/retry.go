@@ -0,0 +1,33 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxBackoff is the longest that retry waits between two attempts.
+const maxBackoff = 15 * time.Second
+
+// retry calls fn until it succeeds, waiting twice as long after each failure, starting
+// with backoff. It gives up when the next attempt would start after timeout has passed.
+func retry(timeout, backoff time.Duration, what string, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("Error %s: gave up after %d attempts in %s: %v", what, attempt, timeout, err)
+		}
+		log.Printf("Error %s (attempt %d), retrying in %s: %v", what, attempt, backoff, err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jqno/balGPT/internal/scraperun"
+)
+
+const scrapeRunColumns = `triggered_by, COALESCE(competition, ''), COALESCE(source_url, ''), started_at, finished_at,
+	http_status, rows_parsed, rows_inserted, rows_skipped, COALESCE(error, '')`
+
+// GetLastScrape returns the time at which the last successful scrape of a source
+// finished, or the zero time if there never was one.
+func (db *DB) GetLastScrape() (time.Time, error) {
+	var lastScrape time.Time
+	err := db.conn().QueryRow("SELECT finished_at FROM scrape_runs WHERE error IS NULL ORDER BY finished_at DESC LIMIT 1").Scan(&lastScrape)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	return lastScrape, nil
+}
+
+// RecordScrapeRuns stores what happened when sources were scraped.
+func (db *DB) RecordScrapeRuns(runs []scraperun.Run) error {
+	for _, run := range runs {
+		_, err := db.conn().Exec(`
+			INSERT INTO scrape_runs (triggered_by, competition, source_url, started_at, finished_at,
+				http_status, rows_parsed, rows_inserted, rows_skipped, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			string(run.Trigger), nullString(run.Competition), nullString(run.SourceURL), db.timestamp(run.StartedAt), db.timestamp(run.FinishedAt),
+			nullInt(run.HTTPStatus), run.RowsParsed, run.RowsInserted, run.RowsSkipped, nullString(run.Error))
+		if err != nil {
+			return fmt.Errorf("Error recording scrape of %s: %v", run.SourceURL, err)
+		}
+	}
+	return nil
+}
+
+// GetScrapeRuns returns the most recent scrapes of sources, newest first.
+func (db *DB) GetScrapeRuns(limit int) ([]scraperun.Run, error) {
+	rows, err := db.conn().Query(`SELECT id, `+scrapeRunColumns+` FROM scrape_runs ORDER BY started_at DESC, id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []scraperun.Run{}
+	for rows.Next() {
+		var run scraperun.Run
+		var httpStatus sql.NullInt64
+		err := rows.Scan(&run.ID, &run.Trigger, &run.Competition, &run.SourceURL, &run.StartedAt, &run.FinishedAt,
+			&httpStatus, &run.RowsParsed, &run.RowsInserted, &run.RowsSkipped, &run.Error)
+		if err != nil {
+			return nil, err
+		}
+		run.HTTPStatus = int(httpStatus.Int64)
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
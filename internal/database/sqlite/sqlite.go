@@ -0,0 +1,126 @@
+// Package sqlite keeps the database.Store in a single SQLite file, for local development
+// and demos.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	dbfiles "github.com/jqno/balGPT/db"
+	"github.com/jqno/balGPT/internal/database"
+	_ "modernc.org/sqlite"
+)
+
+// New opens the SQLite file at the given path. If autoMigrate is set, it applies the
+// migrations that haven't been applied yet; otherwise, run them with the migrate command.
+// A failed migration doesn't stop the app, but it stays unready until the schema is fixed.
+func New(path string, autoMigrate bool) (*database.DB, error) {
+	conn, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoMigrate {
+		// Don't close the migrations: that would close conn too
+		migrations, err := Migrations(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := migrations.Up(); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+
+	return &database.DB{Conn: conn, Dialect: &Dialect{}}, nil
+}
+
+// Migrations returns the migrations of the SQLite database. Closing them closes conn.
+func Migrations(conn *sql.DB) (*database.Migrations, error) {
+	driver, err := migratesqlite.WithInstance(conn, &migratesqlite.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create sqlite driver instance: %v", err)
+	}
+
+	return database.NewMigrations(driver, "sqlite", dbfiles.SQLiteMigrations, "sqlite_migrations")
+}
+
+// Open opens the SQLite file at the given path, creating it if it doesn't exist.
+func Open(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite", path))
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows only one writer at a time; a single connection makes sure that we
+	// wait for each other instead of failing with "database is locked"
+	conn.SetMaxOpenConns(1)
+
+	return conn, nil
+}
+
+// Dialect is the database.Dialect of SQLite. Use it by pointer, so that the DBs that
+// InTransaction hands out share the scrape lock.
+type Dialect struct {
+	scrapeLock sync.Mutex
+}
+
+// WithScrapeLock holds a lock in the process. Only one process can use the file at a
+// time, so the lock doesn't have to live in the database.
+func (d *Dialect) WithScrapeLock(conn *sql.DB, fn func() error) error {
+	d.scrapeLock.Lock()
+	defer d.scrapeLock.Unlock()
+
+	return fn()
+}
+
+// CopyRows inserts the rows one by one. SQLite runs in the same process, so there are no
+// round-trips to save.
+func (d *Dialect) CopyRows(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteJoined selects the rows to delete by their rowid, because SQLite doesn't support
+// DELETE ... USING.
+func (d *Dialect) DeleteJoined(table, alias, other, cond string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT %s.rowid FROM %s %s JOIN %s ON %s)",
+		table, alias, table, alias, other, cond)
+}
+
+// LockTable does nothing: SQLite allows only one writer at a time, so a transaction that
+// writes to table already keeps the others out.
+func (d *Dialect) LockTable(tx *sql.Tx, table string) error {
+	return nil
+}
+
+// Time returns t in UTC. The driver stores timestamps as text, which only compare
+// correctly if they're all in the same timezone.
+func (d *Dialect) Time(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func (d *Dialect) CheckSchema(conn *sql.DB) error {
+	return database.CheckSchema(conn, dbfiles.SQLiteMigrations, "sqlite_migrations")
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dbfiles "github.com/jqno/balGPT/db"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/database/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests run against a real SQLite file, with the real migrations.
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		db, err := New(filepath.Join(t.TempDir(), "balgpt.db"), true)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	conn, err := Open(filepath.Join(t.TempDir(), "balgpt.db"))
+	require.NoError(t, err)
+	migrations, err := Migrations(conn)
+	require.NoError(t, err)
+	defer migrations.Close()
+
+	db := &database.DB{Conn: conn, Dialect: &Dialect{}}
+	assert.Error(t, db.CheckSchema())
+
+	status, err := migrations.Status()
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), status.Version)
+	latest := status.Latest
+	assert.Greater(t, latest, uint(1))
+
+	require.NoError(t, migrations.Up())
+	status, err = migrations.Status()
+	require.NoError(t, err)
+	assert.Equal(t, database.MigrationStatus{Version: latest, Latest: latest}, status)
+	assert.NoError(t, db.CheckSchema())
+
+	require.NoError(t, migrations.Down(1))
+	assert.Error(t, db.CheckSchema())
+	status, err = migrations.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, status.Version)
+	assert.Error(t, migrations.Down(int(latest)))
+	status, err = migrations.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, status.Version)
+
+	require.NoError(t, migrations.Force(int(latest)))
+	status, err = migrations.Status()
+	require.NoError(t, err)
+	assert.Equal(t, database.MigrationStatus{Version: latest, Latest: latest}, status)
+}
+
+func TestMigrationStripsDatesFromExternalIDs(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "balgpt.db"), true)
+	require.NoError(t, err)
+	defer db.Close()
+	files, err := fs.Glob(dbfiles.SQLiteMigrations, "sqlite_migrations/*_strip_dates_from_external_ids.up.sql")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	migration, err := fs.ReadFile(dbfiles.SQLiteMigrations, files[0])
+	require.NoError(t, err)
+
+	// MVV - NAC was rescheduled, and stored again under the ID with its new date
+	_, err = db.Conn.Exec(`INSERT INTO teams (name) VALUES ('MVV'), ('NAC');
+		INSERT INTO matches (competition_id, home_team, away_team, date, external_id)
+		SELECT id, 1, 2, '2022-08-15', 'https://example.com/2022-2023/mvv-nac-15-08' FROM competitions
+		UNION ALL
+		SELECT id, 2, 1, '2023-01-15', 'https://example.com/2022-2023/nac-mvv' FROM competitions
+		UNION ALL
+		SELECT id, 1, 2, '2022-09-20', 'https://example.com/2022-2023/mvv-nac-20-09' FROM competitions`)
+	require.NoError(t, err)
+	_, err = db.Conn.Exec(string(migration))
+	require.NoError(t, err)
+
+	rows, err := db.Conn.Query("SELECT date, external_id FROM matches ORDER BY external_id")
+	require.NoError(t, err)
+	defer rows.Close()
+	matches := []string{}
+	for rows.Next() {
+		var date time.Time
+		var id string
+		require.NoError(t, rows.Scan(&date, &id))
+		matches = append(matches, date.Format("2006-01-02")+" "+id)
+	}
+	assert.Equal(t, []string{
+		"2022-09-20 https://example.com/2022-2023/mvv-nac",
+		"2023-01-15 https://example.com/2022-2023/nac-mvv",
+	}, matches)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"time"
+
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/competition"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/team"
+	"github.com/jqno/balGPT/internal/teamstats"
+)
+
+// Store contains everything the app stores. DB stores it in Postgres, or in a single
+// SQLite file with the Dialect of package sqlite.
+type Store interface {
+	Ping() error
+	Close() error
+	// DataVersion returns a number that goes up with every change to the matches or
+	// teams, by any instance of the app.
+	DataVersion() (int64, error)
+	WithScrapeLock(fn func() error) error
+	// InTransaction runs fn with a Store that performs all its work in a single
+	// transaction, which is committed only if fn returns nil.
+	InTransaction(fn func(tx Store) error) error
+
+	// GetLastScrape returns when the last successful scrape finished.
+	GetLastScrape() (time.Time, error)
+	RecordScrapeRuns(runs []scraperun.Run) error
+	GetScrapeRuns(limit int) ([]scraperun.Run, error)
+	ArchivePage(competition, url string, body []byte, fetchedAt time.Time) error
+	EachArchivedPage(since time.Time, fn func(page archive.Page) error) error
+	GetSourceRowCount(url string) (int, error)
+	UpdateSourceRowCount(url string, rows int, scrapeTime time.Time) error
+
+	FetchTeamsFromDB() ([]team.Team, error)
+	FetchCompetitionsFromDB() ([]competition.Competition, error)
+	GetCompetitionID(name string) (int, error)
+	GetTeamID(teamName string) (int, error)
+	MergeTeams(sourceID, targetID int) error
+
+	InsertOrUpdateMatch(m match.Match) error
+	BulkLoadMatches(matches []match.Match) (int64, error)
+	FindMatch(m match.Match) (*match.Match, error)
+	// EachMatch calls fn for every match that the filter selects, in order of date.
+	EachMatch(filter match.Filter, fn func(m match.Match) error) error
+	DeleteMatches(competition string, from, to time.Time) (int64, error)
+
+	AverageGoalsInLastMatches(competitionID, teamID, numberOfMatches int) (float64, error)
+	LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error)
+	GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error)
+	GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error)
+	GetSeason(competitionID int, name string) (season.Season, error)
+	SeasonAt(competitionID int, t time.Time) (season.Season, error)
+
+	RefreshTeamStats() error
+	GetTeamStats(competitionID int, seasonName string) ([]teamstats.Stats, error)
+
+	// Corrections by admins. Corrected matches aren't overwritten by the scraper, and
+	// deleted matches and teams are left out everywhere except in GetMatchRecords.
+	GetMatchRecord(id int) (*match.Record, error)
+	GetMatchRecords(filter match.Filter) ([]match.Record, error)
+	CreateMatch(m match.Match) (int, error)
+	UpdateMatch(id int, m match.Match) error
+	DeleteMatch(id int, deletedAt time.Time) error
+	CreateTeam(name string) (int, error)
+	RenameTeam(id int, name string) error
+	DeleteTeam(id int, deletedAt time.Time) error
+	RecordChange(c audit.Change) error
+	GetChanges(limit int) ([]audit.Change, error)
+}
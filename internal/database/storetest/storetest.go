@@ -0,0 +1,652 @@
+// Package storetest checks that every database.Store behaves the same.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/audit"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/team"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Store is a database.Store whose seasons can be registered, which the app only does in
+// migrations.
+type Store interface {
+	database.Store
+	RegisterSeason(competitionID int, s season.Season) error
+}
+
+// Run runs every test against an empty store that newStore returns. Only the Eredivisie
+// competition exists in it.
+func Run(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, db Store)
+	}{
+		{"InsertOrUpdateMatchAndFindMatch", testInsertOrUpdateMatchAndFindMatch},
+		{"InsertOrUpdateMatchWithoutExternalID", testInsertOrUpdateMatchWithoutExternalID},
+		{"MatchWithNewExternalIDIsFoundByTeamsAndDate", testMatchWithNewExternalIDIsFoundByTeamsAndDate},
+		{"FindMatchReturnsNilForUnknownMatch", testFindMatchReturnsNilForUnknownMatch},
+		{"EachMatch", testEachMatch},
+		{"BulkLoadMatchesStoresDuplicatesOnce", testBulkLoadMatchesStoresDuplicatesOnce},
+		{"BulkLoadMatchesStoresWhatInsertOrUpdateMatchStores", testBulkLoadMatchesStoresWhatInsertOrUpdateMatchStores},
+		{"InTransactionRollsBack", testInTransactionRollsBack},
+		{"DataVersion", testDataVersion},
+		{"LeaderboardAndPredictorQueries", testLeaderboardAndPredictorQueries},
+		{"RegisteredSeason", testRegisteredSeason},
+		{"MergeTeams", testMergeTeams},
+		{"ArchivedPages", testArchivedPages},
+		{"ScrapeStats", testScrapeStats},
+		{"TeamStats", testTeamStats},
+		{"ConcurrentTeamStatsRefreshes", testConcurrentTeamStatsRefreshes},
+		{"CurrentSeasonLeaderboardComesFromTeamStats", testCurrentSeasonLeaderboardComesFromTeamStats},
+		{"CorrectedMatchesAreLeftAloneByTheScraper", testCorrectedMatchesAreLeftAloneByTheScraper},
+		{"DeletedMatchesDontCount", testDeletedMatchesDontCount},
+		{"RenameTeamKeepsOldNameAsAlias", testRenameTeamKeepsOldNameAsAlias},
+		{"DeleteTeam", testDeleteTeam},
+		{"Changes", testChanges},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func played(home, away string, homeGoals, awayGoals int, date time.Time) match.Match {
+	return match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    home,
+		AwayTeam:    away,
+		HomeGoals:   homeGoals,
+		AwayGoals:   awayGoals,
+		Date:        date,
+		Status:      match.Played,
+	}
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, dates.Amsterdam)
+}
+
+func testInsertOrUpdateMatchAndFindMatch(t *testing.T, db Store) {
+	halfTime := 1
+	m := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	m.ExternalID = "12345"
+	m.Kickoff = time.Date(2023, time.May, 1, 14, 30, 0, 0, dates.Amsterdam)
+	m.HalfTimeHomeGoals = &halfTime
+	m.HalfTimeAwayGoals = &halfTime
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	// Rescheduled, and found by its external ID
+	m.Date = day(2023, time.May, 8)
+	m.HomeGoals = 3
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	found, err := db.FindMatch(m)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "12345", found.ExternalID)
+	assert.Equal(t, 3, found.HomeGoals)
+	assert.Equal(t, "2023-05-08", found.Date.Format("2006-01-02"))
+	assert.True(t, m.Kickoff.Equal(found.Kickoff))
+	assert.Equal(t, 1, *found.HalfTimeHomeGoals)
+
+	teams, err := db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	assert.Len(t, teams, 2)
+}
+
+func testInsertOrUpdateMatchWithoutExternalID(t *testing.T, db Store) {
+	m := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	// Stored before it had an external ID
+	m.ExternalID = "12345"
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	count, err := db.BulkLoadMatches([]match.Match{m})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	deleted, err := db.DeleteMatches("Eredivisie", day(2023, time.January, 1), day(2023, time.December, 31))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func testMatchWithNewExternalIDIsFoundByTeamsAndDate(t *testing.T, db Store) {
+	m := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	m.ExternalID = "https://example.com/ajax-psv-01-05"
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	// The source changed the link of the match
+	m.ExternalID = "https://example.com/ajax-psv"
+	m.HomeGoals = 3
+	found, err := db.FindMatch(m)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "https://example.com/ajax-psv-01-05", found.ExternalID)
+
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+	found, err = db.FindMatch(match.Match{ExternalID: m.ExternalID})
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, 3, found.HomeGoals)
+
+	// And back again, in bulk
+	m.ExternalID = "https://example.com/ajax-psv-01-05"
+	m.HomeGoals = 4
+	count, err := db.BulkLoadMatches([]match.Match{m})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	matches := []match.Match{}
+	require.NoError(t, db.EachMatch(match.Filter{}, func(m match.Match) error {
+		matches = append(matches, m)
+		return nil
+	}))
+	require.Len(t, matches, 1)
+	assert.Equal(t, "https://example.com/ajax-psv-01-05", matches[0].ExternalID)
+	assert.Equal(t, 4, matches[0].HomeGoals)
+}
+
+func testFindMatchReturnsNilForUnknownMatch(t *testing.T, db Store) {
+	found, err := db.FindMatch(played("Ajax", "PSV", 2, 1, day(2023, time.May, 1)))
+
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func testEachMatch(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{
+		played("Ajax", "PSV", 2, 1, day(2023, time.May, 8)),
+		played("PSV", "AZ", 0, 0, day(2023, time.May, 1)),
+		played("AZ", "Ajax", 1, 3, day(2023, time.May, 15)),
+	})
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+
+	collect := func(filter match.Filter) []string {
+		result := []string{}
+		require.NoError(t, db.EachMatch(filter, func(m match.Match) error {
+			result = append(result, m.Date.Format("2006-01-02")+" "+m.HomeTeam+"-"+m.AwayTeam)
+			return nil
+		}))
+		return result
+	}
+
+	assert.Equal(t, []string{"2023-05-01 PSV-AZ", "2023-05-08 Ajax-PSV", "2023-05-15 AZ-Ajax"}, collect(match.Filter{}))
+	assert.Equal(t, []string{"2023-05-08 Ajax-PSV", "2023-05-15 AZ-Ajax"}, collect(match.Filter{TeamID: ajax}))
+	assert.Equal(t, []string{"2023-05-08 Ajax-PSV"}, collect(match.Filter{TeamID: ajax, To: day(2023, time.May, 8)}))
+	assert.Equal(t, []string{"2023-05-15 AZ-Ajax"}, collect(match.Filter{From: day(2023, time.May, 9)}))
+	assert.Empty(t, collect(match.Filter{CompetitionID: 99}))
+}
+
+func testBulkLoadMatchesStoresDuplicatesOnce(t *testing.T, db Store) {
+	first := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	second := played("Feyenoord", "AZ", 0, 0, day(2023, time.May, 2))
+	updated := first
+	updated.HomeGoals = 4
+
+	count, err := db.BulkLoadMatches([]match.Match{first, second, updated})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	found, err := db.FindMatch(first)
+	require.NoError(t, err)
+	assert.Equal(t, 4, found.HomeGoals)
+}
+
+func testBulkLoadMatchesStoresWhatInsertOrUpdateMatchStores(t *testing.T, db Store) {
+	stored := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	stored.ExternalID = "ajax-psv"
+	require.NoError(t, db.InsertOrUpdateMatch(stored))
+
+	rescheduled := stored
+	rescheduled.Date = day(2023, time.May, 8)
+	withoutExternalID := rescheduled
+	withoutExternalID.ExternalID = ""
+	withoutExternalID.HomeGoals = 3
+	relinked := played("Feyenoord", "AZ", 0, 0, day(2023, time.May, 2))
+	relinked.ExternalID = "feyenoord-az-02-05"
+	relinkedAgain := relinked
+	relinkedAgain.ExternalID = "feyenoord-az"
+	unlinked := played("Twente", "NEC", 1, 0, day(2023, time.May, 3))
+	unlinkedLater := unlinked
+	unlinkedLater.ExternalID = "twente-nec"
+	unlinkedLater.AwayGoals = 1
+	batch := []match.Match{rescheduled, relinked, unlinked, withoutExternalID, relinkedAgain, unlinkedLater}
+
+	all := func(db database.Store) []match.Match {
+		matches := []match.Match{}
+		require.NoError(t, db.EachMatch(match.Filter{}, func(m match.Match) error {
+			matches = append(matches, m)
+			return nil
+		}))
+		return matches
+	}
+
+	// Store the batch one by one, and roll it back
+	var expected []match.Match
+	err := db.InTransaction(func(tx database.Store) error {
+		for _, m := range batch {
+			require.NoError(t, tx.InsertOrUpdateMatch(m))
+		}
+		expected = all(tx)
+		return errors.New("rollback")
+	})
+	require.EqualError(t, err, "rollback")
+	require.Len(t, expected, 3)
+
+	count, err := db.BulkLoadMatches(batch)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, expected, all(db))
+}
+
+func testInTransactionRollsBack(t *testing.T, db Store) {
+	err := db.InTransaction(func(tx database.Store) error {
+		require.NoError(t, tx.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))))
+		return errors.New("boom")
+	})
+	assert.EqualError(t, err, "boom")
+
+	teams, err := db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+}
+
+func testDataVersion(t *testing.T, db Store) {
+	version := func() int64 {
+		v, err := db.DataVersion()
+		require.NoError(t, err)
+		return v
+	}
+
+	initial := version()
+	require.NoError(t, db.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))))
+	afterInsert := version()
+	assert.Greater(t, afterInsert, initial)
+
+	require.NoError(t, db.UpdateSourceRowCount("https://example.com", 10, time.Now()))
+	assert.Equal(t, afterInsert, version())
+
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	require.NoError(t, db.RenameTeam(ajax, "AFC Ajax"))
+	afterRename := version()
+	assert.Greater(t, afterRename, afterInsert)
+
+	err = db.InTransaction(func(tx database.Store) error {
+		require.NoError(t, tx.InsertOrUpdateMatch(played("PSV", "Ajax", 0, 0, day(2023, time.May, 8))))
+		return errors.New("rollback")
+	})
+	require.EqualError(t, err, "rollback")
+	assert.Equal(t, afterRename, version())
+}
+
+func testLeaderboardAndPredictorQueries(t *testing.T, db Store) {
+	matches := []match.Match{
+		played("Ajax", "PSV", 2, 1, day(2022, time.September, 1)),
+		played("PSV", "Ajax", 1, 1, day(2023, time.March, 1)),
+		played("Ajax", "PSV", 0, 3, day(2023, time.September, 1)),
+	}
+	_, err := db.BulkLoadMatches(matches)
+	require.NoError(t, err)
+
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	psv, err := db.GetTeamID("PSV")
+	require.NoError(t, err)
+
+	s, err := db.GetSeason(competitionID, "2022-2023")
+	require.NoError(t, err)
+	leaderboard, err := db.GetLeaderboard(competitionID, s.Start, s.End)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{ajax: 4, psv: 1}, leaderboard)
+
+	homeGoals, awayGoals, err := db.LastYearMatchScores(competitionID, ajax, psv)
+	require.NoError(t, err)
+	assert.Equal(t, 0, homeGoals)
+	assert.Equal(t, 3, awayGoals)
+
+	average, err := db.AverageGoalsInLastMatches(competitionID, ajax, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, average)
+}
+
+func testRegisteredSeason(t *testing.T, db Store) {
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	require.NoError(t, db.RegisterSeason(competitionID, season.Season{Name: "2023-2024", Start: day(2023, time.August, 11), End: day(2024, time.May, 19)}))
+
+	s, err := db.SeasonAt(competitionID, day(2023, time.December, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "2023-2024", s.Name)
+	assert.Equal(t, "2023-08-11", s.Start.Format("2006-01-02"))
+	assert.Equal(t, "2024-05-19", s.End.Format("2006-01-02"))
+
+	s, err = db.SeasonAt(competitionID, day(2023, time.July, 31))
+	require.NoError(t, err)
+	assert.Equal(t, "2022-2023", s.Name)
+}
+
+func testMergeTeams(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{
+		played("Ajax", "PSV", 2, 1, day(2023, time.May, 1)),
+		played("AFC Ajax", "PSV", 2, 1, day(2023, time.May, 1)),
+		played("AFC Ajax", "AZ", 1, 0, day(2023, time.May, 8)),
+	})
+	require.NoError(t, err)
+
+	source, err := db.GetTeamID("AFC Ajax")
+	require.NoError(t, err)
+	target, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+
+	require.NoError(t, db.MergeTeams(source, target))
+
+	merged, err := db.GetTeamID("AFC Ajax")
+	require.NoError(t, err)
+	assert.Equal(t, target, merged)
+
+	count, err := db.DeleteMatches("Eredivisie", day(2023, time.January, 1), day(2023, time.December, 31))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.Equal(t, database.ErrTeamNotFound, db.MergeTeams(source, target))
+}
+
+func testArchivedPages(t *testing.T, db Store) {
+	first := time.Date(2023, time.May, 1, 12, 0, 0, 0, dates.Amsterdam)
+	require.NoError(t, db.ArchivePage("Eredivisie", "https://example.com", []byte("page"), first))
+	require.NoError(t, db.ArchivePage("Eredivisie", "https://example.com", []byte("page"), first.Add(time.Hour)))
+	require.NoError(t, db.ArchivePage("Eredivisie", "https://example.com", []byte("other page"), first.Add(2*time.Hour)))
+
+	var pages []archive.Page
+	err := db.EachArchivedPage(first, func(page archive.Page) error {
+		pages = append(pages, page)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, pages, 2)
+	assert.Equal(t, []byte("page"), pages[0].Body)
+	assert.True(t, first.Equal(pages[0].FetchedAt))
+	assert.Equal(t, []byte("other page"), pages[1].Body)
+}
+
+func testScrapeStats(t *testing.T, db Store) {
+	lastScrape, err := db.GetLastScrape()
+	require.NoError(t, err)
+	assert.True(t, lastScrape.IsZero())
+
+	now := time.Now().Truncate(time.Second)
+	succeeded := scraperun.Run{Trigger: scraperun.Scrape, Competition: "Eredivisie", SourceURL: "https://example.com",
+		StartedAt: now.Add(-time.Minute), FinishedAt: now, HTTPStatus: 200, RowsParsed: 10, RowsInserted: 9, RowsSkipped: 1}
+	failed := scraperun.Run{Trigger: scraperun.Predict, Competition: "Eredivisie", SourceURL: "https://example.com",
+		StartedAt: now.Add(time.Minute), FinishedAt: now.Add(2 * time.Minute), Error: "connection refused"}
+	require.NoError(t, db.WithScrapeLock(func() error {
+		return db.InTransaction(func(tx database.Store) error {
+			if err := tx.UpdateSourceRowCount("https://example.com", 10, now); err != nil {
+				return err
+			}
+			return tx.RecordScrapeRuns([]scraperun.Run{succeeded})
+		})
+	}))
+	require.NoError(t, db.RecordScrapeRuns([]scraperun.Run{failed}))
+
+	// Only successful runs count
+	lastScrape, err = db.GetLastScrape()
+	require.NoError(t, err)
+	assert.True(t, now.Equal(lastScrape))
+
+	runs, err := db.GetScrapeRuns(10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "connection refused", runs[0].Error)
+	assert.Equal(t, scraperun.Scrape, runs[1].Trigger)
+	assert.Equal(t, 9, runs[1].RowsInserted)
+	assert.True(t, now.Equal(runs[1].FinishedAt))
+
+	runs, err = db.GetScrapeRuns(1)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	rows, err := db.GetSourceRowCount("https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 10, rows)
+}
+
+func testTeamStats(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{
+		played("Ajax", "PSV", 2, 1, day(2022, time.September, 1)),
+		played("PSV", "Ajax", 1, 1, day(2023, time.March, 1)),
+		played("Ajax", "PSV", 0, 3, day(2023, time.September, 1)),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.RefreshTeamStats())
+
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+
+	stats, err := db.GetTeamStats(competitionID, "2022-2023")
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, ajax, stats[0].TeamID)
+	assert.Equal(t, 4, stats[0].Total.Points())
+	assert.Equal(t, 1, stats[0].Away.Drawn)
+	assert.Equal(t, "WD", stats[0].Form)
+
+	stats, err = db.GetTeamStats(competitionID, "2023-2024")
+	require.NoError(t, err)
+	assert.Len(t, stats, 2)
+}
+
+func testConcurrentTeamStatsRefreshes(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{
+		played("Ajax", "PSV", 2, 1, day(2022, time.September, 1)),
+		played("PSV", "Ajax", 1, 1, day(2023, time.March, 1)),
+	})
+	require.NoError(t, err)
+
+	errs := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			errs <- db.RefreshTeamStats()
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	stats, err := db.GetTeamStats(competitionID, "2022-2023")
+	require.NoError(t, err)
+	assert.Len(t, stats, 2)
+}
+
+func testCurrentSeasonLeaderboardComesFromTeamStats(t *testing.T, db Store) {
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	current, err := db.SeasonAt(competitionID, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, db.InsertOrUpdateMatch(played("Ajax", "PSV", 2, 1, current.Start)))
+	require.NoError(t, db.InsertOrUpdateMatch(played("AZ", "Ajax", 1, 1, current.Start.AddDate(0, 0, 7))))
+
+	leaderboard, err := db.GetCurrentSeasonLeaderboard(competitionID)
+	require.NoError(t, err)
+	assert.Empty(t, leaderboard)
+
+	require.NoError(t, db.RefreshTeamStats())
+	leaderboard, err = db.GetCurrentSeasonLeaderboard(competitionID)
+	require.NoError(t, err)
+	expected, err := db.GetLeaderboard(competitionID, current.Start, current.End)
+	require.NoError(t, err)
+	assert.Equal(t, expected, leaderboard)
+	assert.Len(t, leaderboard, 2)
+}
+
+func testCorrectedMatchesAreLeftAloneByTheScraper(t *testing.T, db Store) {
+	m := played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))
+	m.ExternalID = "12345"
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+
+	records, err := db.GetMatchRecords(match.Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	corrected := m
+	corrected.HomeGoals = 3
+	require.NoError(t, db.UpdateMatch(records[0].ID, corrected))
+
+	require.NoError(t, db.InsertOrUpdateMatch(m))
+	_, err = db.BulkLoadMatches([]match.Match{m})
+	require.NoError(t, err)
+	count, err := db.DeleteMatches("Eredivisie", day(2023, time.January, 1), day(2023, time.December, 31))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	record, err := db.GetMatchRecord(records[0].ID)
+	require.NoError(t, err)
+	assert.True(t, record.Corrected)
+	assert.Equal(t, 3, record.HomeGoals)
+	assert.Equal(t, "12345", record.ExternalID)
+
+	_, err = db.CreateMatch(m)
+	assert.Equal(t, database.ErrDuplicate, err)
+	assert.Equal(t, database.ErrMatchNotFound, db.UpdateMatch(records[0].ID+100, played("AZ", "PSV", 1, 1, day(2023, time.May, 8))))
+}
+
+func testDeletedMatchesDontCount(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{
+		played("Ajax", "PSV", 2, 1, day(2023, time.March, 1)),
+		played("Ajax", "AZ", 5, 0, day(2023, time.April, 1)),
+	})
+	require.NoError(t, err)
+
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+
+	records, err := db.GetMatchRecords(match.Filter{CompetitionID: competitionID})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	friendly := records[1].ID
+	require.NoError(t, db.DeleteMatch(friendly, day(2023, time.May, 1)))
+	assert.Equal(t, database.ErrMatchNotFound, db.DeleteMatch(friendly, day(2023, time.May, 1)))
+
+	s, err := db.GetSeason(competitionID, "2022-2023")
+	require.NoError(t, err)
+	leaderboard, err := db.GetLeaderboard(competitionID, s.Start, s.End)
+	require.NoError(t, err)
+	assert.Equal(t, 3, leaderboard[ajax])
+
+	average, err := db.AverageGoalsInLastMatches(competitionID, ajax, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, average)
+
+	count := 0
+	require.NoError(t, db.EachMatch(match.Filter{}, func(m match.Match) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 1, count)
+
+	// Deleted matches stay deleted, and can still be found for another correction
+	_, err = db.BulkLoadMatches([]match.Match{played("Ajax", "AZ", 5, 0, day(2023, time.April, 1))})
+	require.NoError(t, err)
+	record, err := db.GetMatchRecord(friendly)
+	require.NoError(t, err)
+	assert.False(t, record.DeletedAt.IsZero())
+	records, err = db.GetMatchRecords(match.Filter{CompetitionID: competitionID})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func testRenameTeamKeepsOldNameAsAlias(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))})
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+
+	require.NoError(t, db.RenameTeam(ajax, "AFC Ajax"))
+	renamed, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	assert.Equal(t, ajax, renamed)
+
+	psv, err := db.GetTeamID("PSV")
+	require.NoError(t, err)
+	assert.Equal(t, database.ErrDuplicate, db.RenameTeam(psv, "Ajax"))
+	assert.Equal(t, database.ErrTeamNotFound, db.RenameTeam(ajax+100, "Feyenoord"))
+
+	_, err = db.CreateTeam("Ajax")
+	assert.Equal(t, database.ErrDuplicate, err)
+}
+
+func testDeleteTeam(t *testing.T, db Store) {
+	_, err := db.BulkLoadMatches([]match.Match{played("Ajax", "PSV", 2, 1, day(2023, time.May, 1))})
+	require.NoError(t, err)
+	ajax, err := db.GetTeamID("Ajax")
+	require.NoError(t, err)
+	assert.Equal(t, database.ErrTeamInUse, db.DeleteTeam(ajax, day(2023, time.June, 1)))
+
+	feyenoord, err := db.CreateTeam("Feyenoord")
+	require.NoError(t, err)
+	require.NoError(t, db.DeleteTeam(feyenoord, day(2023, time.June, 1)))
+	assert.Equal(t, database.ErrTeamNotFound, db.DeleteTeam(feyenoord, day(2023, time.June, 1)))
+
+	teams, err := db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	for _, team := range teams {
+		assert.NotEqual(t, "Feyenoord", team.Name)
+	}
+	_, err = db.GetTeamID("Feyenoord")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, database.ErrTeamNotFound, db.MergeTeams(feyenoord, ajax))
+
+	// A team that plays again is back
+	_, err = db.BulkLoadMatches([]match.Match{played("Feyenoord", "PSV", 0, 0, day(2023, time.August, 1))})
+	require.NoError(t, err)
+	teams, err = db.FetchTeamsFromDB()
+	require.NoError(t, err)
+	assert.Contains(t, teams, team.Team{ID: feyenoord, Name: "Feyenoord"})
+}
+
+func testChanges(t *testing.T, db Store) {
+	at := time.Date(2023, time.May, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.RecordChange(audit.Change{ChangedAt: at, User: "admin", Reason: "Friendly", Entity: audit.MatchEntity, EntityID: 1, Action: audit.Delete, Before: `{"ID":1}`}))
+	require.NoError(t, db.RecordChange(audit.Change{ChangedAt: at.Add(time.Hour), User: "admin", Reason: "Typo", Entity: audit.TeamEntity, EntityID: 2, Action: audit.Update, Before: `{"ID":2}`, After: `{"ID":2}`}))
+
+	changes, err := db.GetChanges(1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "Typo", changes[0].Reason)
+	assert.Equal(t, audit.TeamEntity, changes[0].Entity)
+	assert.True(t, at.Add(time.Hour).Equal(changes[0].ChangedAt))
+
+	changes, err = db.GetChanges(10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "", changes[1].After)
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/teamstats"
+)
+
+const teamStatsColumns = `competition_id, team_id, season,
+	played, won, drawn, lost, goals_for, goals_against,
+	home_played, home_won, home_drawn, home_lost, home_goals_for, home_goals_against,
+	away_played, away_won, away_drawn, away_lost, away_goals_for, away_goals_against,
+	form`
+
+// RefreshTeamStats recomputes the statistics of all teams from the played matches.
+func (db *DB) RefreshTeamStats() error {
+	return db.inTransaction(func(tx *DB) error {
+		// The table is cleared and filled again, so two refreshes at the same time would
+		// insert the same rows twice
+		if err := tx.dialect().LockTable(tx.tx, "team_stats"); err != nil {
+			return fmt.Errorf("Error locking team stats: %v", err)
+		}
+
+		results, err := tx.playedResults("TRUE")
+		if err != nil {
+			return fmt.Errorf("Error fetching results for team stats: %v", err)
+		}
+
+		calendar, err := tx.calendar()
+		if err != nil {
+			return fmt.Errorf("Error fetching seasons for team stats: %v", err)
+		}
+
+		stats := teamstats.Compute(results, func(competitionID int, date time.Time) string {
+			return calendar.SeasonAt(competitionID, date).Name
+		})
+
+		if _, err := tx.conn().Exec("DELETE FROM team_stats"); err != nil {
+			return fmt.Errorf("Error clearing team stats: %v", err)
+		}
+
+		stmt, err := tx.conn().Prepare(`INSERT INTO team_stats (` + teamStatsColumns + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, s := range stats {
+			_, err := stmt.Exec(s.CompetitionID, s.TeamID, s.Season,
+				s.Total.Played, s.Total.Won, s.Total.Drawn, s.Total.Lost, s.Total.GoalsFor, s.Total.GoalsAgainst,
+				s.Home.Played, s.Home.Won, s.Home.Drawn, s.Home.Lost, s.Home.GoalsFor, s.Home.GoalsAgainst,
+				s.Away.Played, s.Away.Won, s.Away.Drawn, s.Away.Lost, s.Away.GoalsFor, s.Away.GoalsAgainst,
+				s.Form)
+			if err != nil {
+				return fmt.Errorf("Error storing team stats: %v", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetTeamStats returns the statistics of the teams in a season of a competition, in
+// the order of the league table.
+func (db *DB) GetTeamStats(competitionID int, seasonName string) ([]teamstats.Stats, error) {
+	rows, err := db.conn().Query(`
+		SELECT `+teamStatsColumns+`
+		FROM team_stats
+		WHERE competition_id = $1 AND season = $2`,
+		competitionID, seasonName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []teamstats.Stats{}
+	for rows.Next() {
+		var s teamstats.Stats
+		err := rows.Scan(&s.CompetitionID, &s.TeamID, &s.Season,
+			&s.Total.Played, &s.Total.Won, &s.Total.Drawn, &s.Total.Lost, &s.Total.GoalsFor, &s.Total.GoalsAgainst,
+			&s.Home.Played, &s.Home.Won, &s.Home.Drawn, &s.Home.Lost, &s.Home.GoalsFor, &s.Home.GoalsAgainst,
+			&s.Away.Played, &s.Away.Won, &s.Away.Drawn, &s.Away.Lost, &s.Away.GoalsFor, &s.Away.GoalsAgainst,
+			&s.Form)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	teamstats.Sort(stats)
+	return stats, nil
+}
+
+// playedResults returns the played matches that also match cond.
+func (db *DB) playedResults(cond string, args ...interface{}) ([]teamstats.Result, error) {
+	rows, err := db.conn().Query(`
+		SELECT competition_id, home_team, away_team, home_goals, away_goals, date
+		FROM matches
+		WHERE status IN ('played', 'awarded') AND deleted_at IS NULL AND `+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []teamstats.Result{}
+	for rows.Next() {
+		var r teamstats.Result
+		if err := rows.Scan(&r.CompetitionID, &r.HomeTeamID, &r.AwayTeamID, &r.HomeGoals, &r.AwayGoals, &r.Date); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// calendar returns the registered seasons of all competitions.
+func (db *DB) calendar() (season.Calendar, error) {
+	calendar := season.Calendar{Boundary: db.seasonBoundary(), Registered: map[int][]season.Season{}}
+
+	rows, err := db.conn().Query("SELECT competition_id, name, start_date, end_date FROM seasons")
+	if err != nil {
+		return calendar, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var competitionID int
+		var s season.Season
+		if err := rows.Scan(&competitionID, &s.Name, &s.Start, &s.End); err != nil {
+			return calendar, err
+		}
+		calendar.Registered[competitionID] = append(calendar.Registered[competitionID], s)
+	}
+
+	return calendar, rows.Err()
+}
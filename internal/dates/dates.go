@@ -0,0 +1,156 @@
+package dates
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the timezone database, so Europe/Amsterdam is available in minimal images
+	_ "time/tzdata"
+)
+
+// Amsterdam is the timezone in which the source site lists its matches.
+var Amsterdam = mustLoadLocation("Europe/Amsterdam")
+
+type Locale struct {
+	Name string
+	// Months maps full and abbreviated month names, in lower case, to months.
+	Months map[string]time.Month
+	// Weekdays contains full and abbreviated weekday names, in lower case.
+	Weekdays map[string]bool
+}
+
+var Dutch = Locale{
+	Name: "nl",
+	Months: map[string]time.Month{
+		"januari": time.January, "jan": time.January,
+		"februari": time.February, "feb": time.February,
+		"maart": time.March, "mrt": time.March, "mar": time.March,
+		"april": time.April, "apr": time.April,
+		"mei":  time.May,
+		"juni": time.June, "jun": time.June,
+		"juli": time.July, "jul": time.July,
+		"augustus": time.August, "aug": time.August,
+		"september": time.September, "sep": time.September, "sept": time.September,
+		"oktober": time.October, "okt": time.October,
+		"november": time.November, "nov": time.November,
+		"december": time.December, "dec": time.December,
+	},
+	Weekdays: map[string]bool{
+		"maandag": true, "ma": true,
+		"dinsdag": true, "di": true,
+		"woensdag": true, "wo": true,
+		"donderdag": true, "do": true,
+		"vrijdag": true, "vr": true,
+		"zaterdag": true, "za": true,
+		"zondag": true, "zo": true,
+	},
+}
+
+var English = Locale{
+	Name: "en",
+	Months: map[string]time.Month{
+		"january": time.January, "jan": time.January,
+		"february": time.February, "feb": time.February,
+		"march": time.March, "mar": time.March,
+		"april": time.April, "apr": time.April,
+		"may":  time.May,
+		"june": time.June, "jun": time.June,
+		"july": time.July, "jul": time.July,
+		"august": time.August, "aug": time.August,
+		"september": time.September, "sep": time.September, "sept": time.September,
+		"october": time.October, "oct": time.October,
+		"november": time.November, "nov": time.November,
+		"december": time.December, "dec": time.December,
+	},
+	Weekdays: map[string]bool{
+		"monday": true, "mon": true,
+		"tuesday": true, "tue": true, "tues": true,
+		"wednesday": true, "wed": true,
+		"thursday": true, "thu": true, "thurs": true,
+		"friday": true, "fri": true,
+		"saturday": true, "sat": true,
+		"sunday": true, "sun": true,
+	},
+}
+
+// Parser parses written-out dates, such as "Maandag 15 augustus 2022" or
+// "Sun, Mar 5th 2023", in any of its locales.
+type Parser struct {
+	Locales  []Locale
+	Location *time.Location
+}
+
+func NewParser(location *time.Location, locales ...Locale) *Parser {
+	return &Parser{Locales: locales, Location: location}
+}
+
+// Parse returns midnight of the given date in the parser's location. It fails unless
+// the whole string is a date in one of the parser's locales.
+func (p *Parser) Parse(s string) (time.Time, error) {
+	tokens := strings.Fields(strings.NewReplacer(",", " ", ".", " ").Replace(strings.ToLower(s)))
+
+	for _, locale := range p.Locales {
+		if date, ok := p.parseInLocale(tokens, locale); ok {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+func (p *Parser) parseInLocale(tokens []string, locale Locale) (time.Time, bool) {
+	if len(tokens) == 4 && locale.Weekdays[tokens[0]] {
+		tokens = tokens[1:]
+	}
+	if len(tokens) != 3 {
+		return time.Time{}, false
+	}
+
+	year, err := strconv.Atoi(tokens[2])
+	if err != nil || len(tokens[2]) != 4 {
+		return time.Time{}, false
+	}
+
+	// Both "5 march" and "march 5"
+	dayToken, monthToken := tokens[0], tokens[1]
+	if _, ok := locale.Months[dayToken]; ok {
+		dayToken, monthToken = monthToken, dayToken
+	}
+
+	month, ok := locale.Months[monthToken]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	day, err := strconv.Atoi(stripOrdinal(dayToken))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.Location)
+	if date.Day() != day || date.Month() != month {
+		// time.Date normalizes dates like February 30th; we don't
+		return time.Time{}, false
+	}
+
+	return date, true
+}
+
+func stripOrdinal(day string) string {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if strings.HasSuffix(day, suffix) {
+			return strings.TrimSuffix(day, suffix)
+		}
+	}
+	return day
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -0,0 +1,60 @@
+package dates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	parser := NewParser(Amsterdam, Dutch, English)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"Maandag 15 augustus 2022", time.Date(2022, time.August, 15, 0, 0, 0, 0, Amsterdam)},
+		{"15 augustus 2022", time.Date(2022, time.August, 15, 0, 0, 0, 0, Amsterdam)},
+		{"zo 2 okt. 2022", time.Date(2022, time.October, 2, 0, 0, 0, 0, Amsterdam)},
+		{"vr 3 mrt 2023", time.Date(2023, time.March, 3, 0, 0, 0, 0, Amsterdam)},
+		{"1 juni 2023", time.Date(2023, time.June, 1, 0, 0, 0, 0, Amsterdam)},
+		{"1 juli 2023", time.Date(2023, time.July, 1, 0, 0, 0, 0, Amsterdam)},
+		{"Sunday, 5 March 2023", time.Date(2023, time.March, 5, 0, 0, 0, 0, Amsterdam)},
+		{"Sun, Mar 5th 2023", time.Date(2023, time.March, 5, 0, 0, 0, 0, Amsterdam)},
+		{"October 21, 2022", time.Date(2022, time.October, 21, 0, 0, 0, 0, Amsterdam)},
+	}
+
+	for _, test := range tests {
+		date, err := parser.Parse(test.input)
+		assert.NoError(t, err, test.input)
+		assert.Equal(t, test.expected, date, test.input)
+	}
+}
+
+func TestParseRejectsInvalidDates(t *testing.T) {
+	parser := NewParser(Amsterdam, Dutch, English)
+
+	inputs := []string{
+		"",
+		"Maandag",
+		"30 februari 2023",
+		"15 augustuss 2022",
+		"15 08 2022",
+		"Vandaag 15 augustus 2022",
+		"15 augustus 22",
+		"Monday 15 augustus 2022 extra",
+	}
+
+	for _, input := range inputs {
+		_, err := parser.Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseOnlyUsesGivenLocales(t *testing.T) {
+	parser := NewParser(time.UTC, Dutch)
+
+	_, err := parser.Parse("5 March 2023")
+	assert.Error(t, err)
+}
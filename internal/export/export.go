@@ -0,0 +1,322 @@
+// Package export writes matches, teams and standings in formats that other tools can
+// load easily: CSV, JSON Lines and SQLite.
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/season"
+	"github.com/jqno/balGPT/internal/standings"
+	"github.com/jqno/balGPT/internal/team"
+)
+
+// The datasets that can be exported.
+const (
+	Matches   = "matches"
+	Teams     = "teams"
+	Standings = "standings"
+)
+
+// The formats that datasets can be exported in.
+const (
+	CSV    = "csv"
+	JSONL  = "jsonl"
+	SQLite = "sqlite"
+)
+
+type Store interface {
+	EachMatch(filter match.Filter, fn func(m match.Match) error) error
+	FetchTeamsFromDB() ([]team.Team, error)
+	GetCompetitionID(name string) (int, error)
+	GetTeamID(teamName string) (int, error)
+	GetLeaderboard(competitionID int, from, to time.Time) (map[int]int, error)
+	GetSeason(competitionID int, name string) (season.Season, error)
+	SeasonAt(competitionID int, t time.Time) (season.Season, error)
+}
+
+// AllCompetitions is the value of competition_id that selects every competition.
+const AllCompetitions = "all"
+
+// Filter selects what is exported. Teams are filtered only by TeamID.
+type Filter struct {
+	// CompetitionID is 0 to export the matches of every competition. Standings always
+	// need a competition.
+	CompetitionID int
+	TeamID        int
+	// From and To are zero for matches when neither a season nor dates were asked for.
+	// Standings are then exported for the current season.
+	From time.Time
+	To   time.Time
+}
+
+// FilterError means that a filter doesn't make sense, as opposed to a failure to look
+// it up.
+type FilterError struct {
+	msg string
+}
+
+func (e *FilterError) Error() string {
+	return e.msg
+}
+
+// ParseFilter reads a filter from the parameters competition_id, team (a name or an ID),
+// season (such as "2022-2023"), from and to (YYYY-MM-DD). The competition defaults to
+// defaultCompetition; competition_id=all selects every competition, whose seasons are
+// then the ones of the configured season boundary.
+func ParseFilter(store Store, query url.Values, defaultCompetition string) (Filter, error) {
+	var filter Filter
+	var err error
+
+	if competitionIDStr := query.Get("competition_id"); competitionIDStr == AllCompetitions {
+		filter.CompetitionID = 0
+	} else if competitionIDStr != "" {
+		filter.CompetitionID, err = strconv.Atoi(competitionIDStr)
+		if err != nil || filter.CompetitionID <= 0 {
+			return filter, &FilterError{"Invalid competition_id, expected an ID or all."}
+		}
+	} else {
+		filter.CompetitionID, err = store.GetCompetitionID(defaultCompetition)
+		if err != nil {
+			return filter, fmt.Errorf("Error fetching default competition: %v", err)
+		}
+	}
+
+	if teamStr := query.Get("team"); teamStr != "" {
+		filter.TeamID, err = strconv.Atoi(teamStr)
+		if err != nil {
+			filter.TeamID, err = store.GetTeamID(teamStr)
+			if err != nil {
+				return filter, &FilterError{fmt.Sprintf("Unknown team %q.", teamStr)}
+			}
+		}
+	}
+
+	switch {
+	case query.Get("from") != "" || query.Get("to") != "":
+		if query.Get("from") != "" {
+			filter.From, err = time.ParseInLocation("2006-01-02", query.Get("from"), dates.Amsterdam)
+			if err != nil {
+				return filter, &FilterError{"Invalid from, expected YYYY-MM-DD."}
+			}
+		}
+		if query.Get("to") != "" {
+			filter.To, err = time.ParseInLocation("2006-01-02", query.Get("to"), dates.Amsterdam)
+			if err != nil || filter.To.Before(filter.From) {
+				return filter, &FilterError{"Invalid to, expected YYYY-MM-DD on or after from."}
+			}
+		}
+	case query.Get("season") != "":
+		if _, err := season.StartYear(query.Get("season")); err != nil {
+			return filter, &FilterError{"Invalid season, expected a name like 2022-2023."}
+		}
+		s, err := store.GetSeason(filter.CompetitionID, query.Get("season"))
+		if err != nil {
+			return filter, fmt.Errorf("Error fetching season: %v", err)
+		}
+		filter.From, filter.To = s.Start, s.End
+	}
+
+	return filter, nil
+}
+
+// IsDataset tells whether the name is one of the datasets.
+func IsDataset(name string) bool {
+	return name == Matches || name == Teams || name == Standings
+}
+
+// IsFormat tells whether the name is one of the formats.
+func IsFormat(name string) bool {
+	return name == CSV || name == JSONL || name == SQLite
+}
+
+// ContentType returns the MIME type of the format.
+func ContentType(format string) string {
+	switch format {
+	case JSONL:
+		return "application/jsonl"
+	case SQLite:
+		return "application/vnd.sqlite3"
+	default:
+		return "text/csv"
+	}
+}
+
+// FileName returns the name of a file that contains the dataset in the format.
+func FileName(dataset, format string) string {
+	if format == SQLite {
+		return dataset + ".db"
+	}
+	return dataset + "." + format
+}
+
+// Write writes the dataset in the format to w. Matches and teams are streamed, so that
+// they don't have to fit in memory; SQLite files are written to a temporary file first.
+// Streaming holds a database connection until the last row is written, so a slow w
+// keeps a store with a single connection busy.
+func Write(w io.Writer, store Store, dataset, format string, filter Filter) error {
+	if !IsDataset(dataset) {
+		return fmt.Errorf("unknown dataset %q", dataset)
+	}
+	if dataset == Standings && filter.CompetitionID == 0 {
+		return &FilterError{"Standings need a competition_id."}
+	}
+
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return err
+	}
+
+	switch dataset {
+	case Matches:
+		err = writeMatches(enc, store, filter)
+	case Teams:
+		err = writeTeams(enc, store, filter)
+	case Standings:
+		err = writeStandings(enc, store, filter)
+	}
+	if err != nil {
+		enc.abort()
+		return err
+	}
+
+	return enc.close()
+}
+
+var matchesTable = table{
+	name: Matches,
+	columns: []column{
+		{"competition", "TEXT"},
+		{"date", "TEXT"},
+		{"kickoff", "TEXT"},
+		{"home_team", "TEXT"},
+		{"away_team", "TEXT"},
+		{"home_goals", "INTEGER"},
+		{"away_goals", "INTEGER"},
+		{"half_time_home_goals", "INTEGER"},
+		{"half_time_away_goals", "INTEGER"},
+		{"status", "TEXT"},
+		{"external_id", "TEXT"},
+	},
+}
+
+func writeMatches(enc encoder, store Store, filter Filter) error {
+	if err := enc.header(matchesTable); err != nil {
+		return err
+	}
+
+	f := match.Filter{CompetitionID: filter.CompetitionID, TeamID: filter.TeamID, From: filter.From, To: filter.To}
+	return store.EachMatch(f, func(m match.Match) error {
+		var kickoff, homeGoals, awayGoals, externalID interface{}
+		if !m.Kickoff.IsZero() {
+			kickoff = m.Kickoff.Format(time.RFC3339)
+		}
+		if m.HasScore() {
+			homeGoals, awayGoals = m.HomeGoals, m.AwayGoals
+		}
+		if m.ExternalID != "" {
+			externalID = m.ExternalID
+		}
+
+		return enc.row([]interface{}{
+			m.Competition,
+			m.Date.Format("2006-01-02"),
+			kickoff,
+			m.HomeTeam,
+			m.AwayTeam,
+			homeGoals,
+			awayGoals,
+			intOrNil(m.HalfTimeHomeGoals),
+			intOrNil(m.HalfTimeAwayGoals),
+			string(m.Status),
+			externalID,
+		})
+	})
+}
+
+var teamsTable = table{
+	name: Teams,
+	columns: []column{
+		{"id", "INTEGER"},
+		{"name", "TEXT"},
+	},
+}
+
+func writeTeams(enc encoder, store Store, filter Filter) error {
+	if err := enc.header(teamsTable); err != nil {
+		return err
+	}
+
+	teams, err := store.FetchTeamsFromDB()
+	if err != nil {
+		return fmt.Errorf("Error fetching teams: %v", err)
+	}
+
+	for _, t := range teams {
+		if filter.TeamID != 0 && t.ID != filter.TeamID {
+			continue
+		}
+		if err := enc.row([]interface{}{t.ID, t.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var standingsTable = table{
+	name: Standings,
+	columns: []column{
+		{"position", "INTEGER"},
+		{"team_id", "INTEGER"},
+		{"team", "TEXT"},
+		{"points", "INTEGER"},
+	},
+}
+
+func writeStandings(enc encoder, store Store, filter Filter) error {
+	if err := enc.header(standingsTable); err != nil {
+		return err
+	}
+
+	from, to := filter.From, filter.To
+	if from.IsZero() && to.IsZero() {
+		s, err := store.SeasonAt(filter.CompetitionID, time.Now())
+		if err != nil {
+			return fmt.Errorf("Error fetching season: %v", err)
+		}
+		from, to = s.Start, s.End
+	} else if to.IsZero() {
+		to = time.Now()
+	}
+
+	leaderboard, err := store.GetLeaderboard(filter.CompetitionID, from, to)
+	if err != nil {
+		return fmt.Errorf("Error fetching standings: %v", err)
+	}
+	teams, err := store.FetchTeamsFromDB()
+	if err != nil {
+		return fmt.Errorf("Error fetching teams: %v", err)
+	}
+
+	for _, s := range standings.Table(leaderboard, teams) {
+		if filter.TeamID != 0 && s.TeamID != filter.TeamID {
+			continue
+		}
+		if err := enc.row([]interface{}{s.Position, s.TeamID, s.Team, s.Points}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func intOrNil(n *int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, dates.Amsterdam)
+}
+
+func newTestStore(t *testing.T) *memory.DB {
+	db := memory.New()
+	halfTime := 1
+	matches := []match.Match{
+		{Competition: "Eredivisie", HomeTeam: "Ajax", AwayTeam: "PSV", HomeGoals: 2, AwayGoals: 1, Date: day(2022, time.September, 4), Status: match.Played, HalfTimeHomeGoals: &halfTime, HalfTimeAwayGoals: &halfTime},
+		{Competition: "Eredivisie", HomeTeam: "PSV", AwayTeam: "AZ", HomeGoals: 1, AwayGoals: 1, Date: day(2022, time.September, 11), Status: match.Played, ExternalID: "psv-az"},
+		{Competition: "Eredivisie", HomeTeam: "AZ", AwayTeam: "Ajax", Date: day(2023, time.September, 3), Status: match.Postponed},
+	}
+	_, err := db.BulkLoadMatches(matches)
+	require.NoError(t, err)
+	return db
+}
+
+func export(t *testing.T, db Store, dataset, format string, query url.Values) string {
+	filter, err := ParseFilter(db, query, "Eredivisie")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, db, dataset, format, filter))
+	return out.String()
+}
+
+func TestExportMatchesAsCSV(t *testing.T) {
+	db := newTestStore(t)
+
+	out := export(t, db, Matches, CSV, url.Values{})
+
+	assert.Equal(t, `competition,date,kickoff,home_team,away_team,home_goals,away_goals,half_time_home_goals,half_time_away_goals,status,external_id
+Eredivisie,2022-09-04,,Ajax,PSV,2,1,1,1,played,
+Eredivisie,2022-09-11,,PSV,AZ,1,1,,,played,psv-az
+Eredivisie,2023-09-03,,AZ,Ajax,,,,,postponed,
+`, out)
+}
+
+func TestExportMatchesOfTeamAndSeasonAsJSONLines(t *testing.T) {
+	db := newTestStore(t)
+
+	out := export(t, db, Matches, JSONL, url.Values{"team": {"Ajax"}, "season": {"2022-2023"}})
+
+	assert.Equal(t, `{"competition":"Eredivisie","date":"2022-09-04","kickoff":null,"home_team":"Ajax","away_team":"PSV","home_goals":2,"away_goals":1,"half_time_home_goals":1,"half_time_away_goals":1,"status":"played","external_id":null}
+`, out)
+}
+
+func TestExportStandingsAndTeams(t *testing.T) {
+	db := newTestStore(t)
+
+	standings := export(t, db, Standings, CSV, url.Values{"from": {"2022-09-01"}, "to": {"2022-09-30"}})
+	assert.Equal(t, "position,team_id,team,points\n1,1,Ajax,3\n2,3,AZ,1\n3,2,PSV,1\n", standings)
+
+	teams := export(t, db, Teams, JSONL, url.Values{"team": {"2"}})
+	assert.Equal(t, "{\"id\":2,\"name\":\"PSV\"}\n", teams)
+}
+
+func TestExportAsSQLite(t *testing.T) {
+	db := newTestStore(t)
+
+	out := export(t, db, Matches, SQLite, url.Values{"team": {"PSV"}})
+
+	path := filepath.Join(t.TempDir(), "matches.db")
+	require.NoError(t, os.WriteFile(path, []byte(out), 0o600))
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var count, homeGoals int
+	require.NoError(t, conn.QueryRow("SELECT COUNT(*), SUM(home_goals) FROM matches").Scan(&count, &homeGoals))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 3, homeGoals)
+}
+
+func TestParseFilterRejectsInvalidInput(t *testing.T) {
+	db := newTestStore(t)
+
+	for _, query := range []url.Values{
+		{"competition_id": {"one"}},
+		{"competition_id": {"0"}},
+		{"team": {"Feyenoord"}},
+		{"from": {"1 september"}},
+		{"from": {"2023-01-01"}, "to": {"2022-01-01"}},
+		{"season": {"2022"}},
+	} {
+		_, err := ParseFilter(db, query, "Eredivisie")
+		var filterErr *FilterError
+		assert.True(t, errors.As(err, &filterErr), "%v: %v", query, err)
+	}
+}
+
+func TestExportMatchesOfAllCompetitions(t *testing.T) {
+	db := newTestStore(t)
+
+	filter, err := ParseFilter(db, url.Values{"competition_id": {AllCompetitions}, "season": {"2022-2023"}}, "Eredivisie")
+	require.NoError(t, err)
+	assert.Equal(t, 0, filter.CompetitionID)
+	assert.Equal(t, day(2022, time.August, 1), filter.From)
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, db, Matches, CSV, filter))
+	assert.Contains(t, out.String(), "Eredivisie,2022-09-11,,PSV,AZ,")
+
+	err = Write(&out, db, Standings, CSV, filter)
+	var filterErr *FilterError
+	assert.True(t, errors.As(err, &filterErr), "%v", err)
+}
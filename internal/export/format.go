@@ -0,0 +1,215 @@
+package export
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// table describes the columns of a dataset.
+type table struct {
+	name    string
+	columns []column
+}
+
+type column struct {
+	name    string
+	sqlType string
+}
+
+// encoder writes a table in some format. Values are ints, strings or nil.
+type encoder interface {
+	header(t table) error
+	row(values []interface{}) error
+	// close finishes the output; abort cleans up after a failure instead
+	close() error
+	abort()
+}
+
+func newEncoder(w io.Writer, format string) (encoder, error) {
+	switch format {
+	case CSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case JSONL:
+		return &jsonlEncoder{w: bufio.NewWriter(w)}, nil
+	case SQLite:
+		return &sqliteEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) header(t table) error {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return e.w.Write(names)
+}
+
+func (e *csvEncoder) row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			record[i] = ""
+		case int:
+			record[i] = strconv.Itoa(v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) abort() {
+	e.w.Flush()
+}
+
+// jsonlEncoder writes one JSON object per row, with the keys in the order of the
+// columns.
+type jsonlEncoder struct {
+	w     *bufio.Writer
+	names []string
+}
+
+func (e *jsonlEncoder) header(t table) error {
+	e.names = make([]string, len(t.columns))
+	for i, c := range t.columns {
+		e.names[i] = c.name
+	}
+	return nil
+}
+
+func (e *jsonlEncoder) row(values []interface{}) error {
+	var line strings.Builder
+	line.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		name, err := json.Marshal(e.names[i])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		line.Write(name)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+
+	_, err := e.w.WriteString(line.String())
+	return err
+}
+
+func (e *jsonlEncoder) close() error {
+	return e.w.Flush()
+}
+
+func (e *jsonlEncoder) abort() {
+	e.w.Flush()
+}
+
+// sqliteEncoder builds a SQLite file with a single table in a temporary file, and
+// copies it to w when it's complete.
+type sqliteEncoder struct {
+	w    io.Writer
+	path string
+	conn *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+func (e *sqliteEncoder) header(t table) error {
+	f, err := os.CreateTemp("", "balgpt-export-*.db")
+	if err != nil {
+		return err
+	}
+	e.path = f.Name()
+	f.Close()
+
+	e.conn, err = sql.Open("sqlite", e.path)
+	if err != nil {
+		return err
+	}
+
+	definitions := make([]string, len(t.columns))
+	names := make([]string, len(t.columns))
+	placeholders := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		definitions[i] = c.name + " " + c.sqlType
+		names[i] = c.name
+		placeholders[i] = "?"
+	}
+	if _, err := e.conn.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", t.name, strings.Join(definitions, ", "))); err != nil {
+		return fmt.Errorf("Error creating table %s: %v", t.name, err)
+	}
+
+	e.tx, err = e.conn.Begin()
+	if err != nil {
+		return err
+	}
+	e.stmt, err = e.tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.name, strings.Join(names, ", "), strings.Join(placeholders, ", ")))
+	return err
+}
+
+func (e *sqliteEncoder) row(values []interface{}) error {
+	_, err := e.stmt.Exec(values...)
+	return err
+}
+
+func (e *sqliteEncoder) close() error {
+	defer e.abort()
+
+	if err := e.stmt.Close(); err != nil {
+		return err
+	}
+	if err := e.tx.Commit(); err != nil {
+		return err
+	}
+	if err := e.conn.Close(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(e.w, f)
+	return err
+}
+
+func (e *sqliteEncoder) abort() {
+	if e.tx != nil {
+		e.tx.Rollback()
+	}
+	if e.conn != nil {
+		e.conn.Close()
+	}
+	if e.path != "" {
+		os.Remove(e.path)
+	}
+}
@@ -0,0 +1,223 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 2 * time.Second
+	defaultUserAgent  = "balGPT (+https://github.com/jqno/balGPT)"
+
+	// maxRetryAfter is the longest a server can ask us to wait before we give up instead
+	maxRetryAfter = 5 * time.Minute
+)
+
+// ErrNotModified is returned when the server reports that a page hasn't changed since
+// the last time it was fetched.
+var ErrNotModified = errors.New("page not modified")
+
+// StatusError is returned when the server responds with a non-2xx status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is how long the server asked us to wait before trying again, if it did
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d fetching %s", e.StatusCode, e.URL)
+}
+
+type Page struct {
+	URL        string
+	StatusCode int
+	Body       []byte
+	FetchedAt  time.Time
+}
+
+// HTTPFetcher downloads pages with a timeout, retries transient failures with
+// exponential backoff, and uses conditional requests to avoid downloading pages
+// that haven't changed.
+type HTTPFetcher struct {
+	Client     *http.Client
+	UserAgent  string
+	MaxRetries int
+	BaseDelay  time.Duration
+
+	mu         sync.Mutex
+	validators map[string]validator
+}
+
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+func New() *HTTPFetcher {
+	return &HTTPFetcher{
+		Client:     &http.Client{Timeout: defaultTimeout},
+		UserAgent:  defaultUserAgent,
+		MaxRetries: defaultMaxRetries,
+		BaseDelay:  defaultBaseDelay,
+		validators: make(map[string]validator),
+	}
+}
+
+// Fetch downloads the page, retrying transient failures. The deadline of ctx bounds all
+// attempts together: a retry that can't be made before it, such as one after a long
+// Retry-After, isn't waited for.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (*Page, error) {
+	var err error
+	for attempt := 0; attempt <= f.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.retryDelay(attempt, err)
+			if delay > maxRetryAfter {
+				return nil, err
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+				return nil, fmt.Errorf("giving up on %s, since retrying in %v would take too long: %w", url, delay, err)
+			}
+			log.Printf("Fetching %s failed (%v), retrying in %v", url, err, delay)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, err
+			}
+		}
+
+		var page *Page
+		page, err = f.fetchOnce(ctx, url)
+		if err == nil || ctx.Err() != nil || !isRetryable(err) {
+			return page, err
+		}
+	}
+
+	return nil, fmt.Errorf("giving up on %s after %d attempts: %w", url, f.MaxRetries+1, err)
+}
+
+// Forget drops the cached validators for the given URL, so the next fetch downloads
+// the page again even if it hasn't changed.
+func (f *HTTPFetcher) Forget(url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.validators, url)
+}
+
+func (f *HTTPFetcher) fetchOnce(ctx context.Context, url string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html")
+
+	f.mu.Lock()
+	v, ok := f.validators[url]
+	f.mu.Unlock()
+	if ok {
+		if v.etag != "" {
+			req.Header.Set("If-None-Match", v.etag)
+		}
+		if v.lastModified != "" {
+			req.Header.Set("If-Modified-Since", v.lastModified)
+		}
+	}
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		statusErr := &StatusError{URL: url, StatusCode: res.StatusCode}
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+			statusErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, statusErr
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	etag := res.Header.Get("ETag")
+	lastModified := res.Header.Get("Last-Modified")
+	if etag != "" || lastModified != "" {
+		f.mu.Lock()
+		f.validators[url] = validator{etag: etag, lastModified: lastModified}
+		f.mu.Unlock()
+	}
+
+	return &Page{URL: url, StatusCode: res.StatusCode, Body: body, FetchedAt: time.Now()}, nil
+}
+
+// retryDelay backs off exponentially, but waits at least as long as the server asked.
+func (f *HTTPFetcher) retryDelay(attempt int, err error) time.Duration {
+	delay := f.BaseDelay << (attempt - 1)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		return statusErr.RetryAfter
+	}
+	return delay
+}
+
+// parseRetryAfter reads a Retry-After header, which holds either a number of seconds or
+// a date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrNotModified) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	// Timeouts and dropped connections may go away; an invalid URL or a bad certificate
+	// won't
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
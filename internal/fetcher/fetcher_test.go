@@ -0,0 +1,213 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFetcher() *HTTPFetcher {
+	f := New()
+	f.BaseDelay = time.Millisecond
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, defaultUserAgent, r.Header.Get("User-Agent"))
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	page, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "<html></html>", string(page.Body))
+}
+
+func TestFetchRetriesServerErrors(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	page, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(page.Body))
+	assert.Equal(t, 3, requests)
+}
+
+func TestFetchGivesUpAfterMaxRetries(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	_, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+	assert.Equal(t, defaultMaxRetries+1, requests)
+}
+
+func TestFetchDoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	assert.Equal(t, &StatusError{URL: ts.URL, StatusCode: http.StatusNotFound}, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestFetchUsesConditionalRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	f := newTestFetcher()
+
+	_, err := f.Fetch(context.Background(), ts.URL)
+	assert.NoError(t, err)
+
+	_, err = f.Fetch(context.Background(), ts.URL)
+	assert.Equal(t, ErrNotModified, err)
+
+	f.Forget(ts.URL)
+	_, err = f.Fetch(context.Background(), ts.URL)
+	assert.NoError(t, err)
+}
+
+func TestFetchHonorsRetryAfter(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	start := time.Now()
+	page, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(page.Body))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestFetchGivesUpWhenRetryAfterIsTooLong(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	_, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, time.Hour, statusErr.RetryAfter)
+	assert.Equal(t, 1, requests)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 2*time.Minute, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("Sun, 05 Mar 2023 12:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Sun, 05 Mar 2023 11:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
+
+func TestFetchDoesNotRetryInvalidURLs(t *testing.T) {
+	for _, url := range []string{"ftp://example.com/page", "http://[::1"} {
+		_, err := newTestFetcher().Fetch(context.Background(), url)
+
+		assert.Error(t, err)
+		assert.False(t, isRetryable(err), url)
+	}
+}
+
+func TestFetchRetriesTimeouts(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	f := newTestFetcher()
+	f.Client.Timeout = 50 * time.Millisecond
+	page, err := f.Fetch(context.Background(), ts.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(page.Body))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestFetchGivesUpWhenARetryDoesNotFitTheDeadline(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := newTestFetcher().Fetch(ctx, ts.URL)
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, 1, requests)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFetchRetriesRefusedConnections(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	_, err := newTestFetcher().fetchOnce(context.Background(), url)
+
+	assert.True(t, isRetryable(err), "%v", err)
+	assert.False(t, isRetryable(errors.New("parse error")))
+}
@@ -0,0 +1,56 @@
+package match
+
+import "time"
+
+type Status string
+
+const (
+	Played    Status = "played"
+	Postponed Status = "postponed"
+	Abandoned Status = "abandoned"
+	Awarded   Status = "awarded"
+)
+
+type Match struct {
+	// ExternalID identifies the match at the source, so it can be recognized when it's
+	// rescheduled.
+	ExternalID  string
+	Competition string
+	HomeTeam    string
+	AwayTeam    string
+	HomeGoals   int
+	AwayGoals   int
+	Date        time.Time
+	Status      Status
+
+	// Optional fields; the zero value or nil means unknown.
+	Kickoff           time.Time
+	HalfTimeHomeGoals *int
+	HalfTimeAwayGoals *int
+}
+
+// HasScore tells whether the match has a final score.
+func (m Match) HasScore() bool {
+	return m.Status == Played || m.Status == Awarded
+}
+
+// Filter selects matches; the zero value of each field selects everything.
+type Filter struct {
+	CompetitionID int
+	// TeamID selects the matches in which the team played, at home or away.
+	TeamID int
+	From   time.Time
+	To     time.Time
+}
+
+// Record is a stored match, with what an admin needs to correct it.
+type Record struct {
+	ID int
+	Match
+	// Corrected tells whether an admin changed the match. The scraper doesn't overwrite
+	// corrected matches.
+	Corrected bool
+	// DeletedAt is zero unless an admin deleted the match. Deleted matches are kept, but
+	// don't count anywhere.
+	DeletedAt time.Time
+}
@@ -12,13 +12,13 @@ func NewAverageGoalsPredictor(db DB) *AverageGoalsPredictor {
 	return &AverageGoalsPredictor{db: db}
 }
 
-func (a *AverageGoalsPredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, error) {
-	homeAvgGoals, err := a.db.AverageGoalsInLastMatches(homeTeamID, 8)
+func (a *AverageGoalsPredictor) Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error) {
+	homeAvgGoals, err := a.db.AverageGoalsInLastMatches(competitionID, homeTeamID, 8)
 	if err != nil {
 		return nil, err
 	}
 
-	awayAvgGoals, err := a.db.AverageGoalsInLastMatches(awayTeamID, 8)
+	awayAvgGoals, err := a.db.AverageGoalsInLastMatches(competitionID, awayTeamID, 8)
 	if err != nil {
 		return nil, err
 	}
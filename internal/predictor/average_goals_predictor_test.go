@@ -1,26 +1,26 @@
 package predictor
 
 import (
-	"errors"
 	"testing"
+	"time"
 
-	"github.com/jqno/balGPT/internal/database_test"
+	"github.com/jqno/balGPT/internal/database/memory"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewAverageGoalsPredictor(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewAverageGoalsPredictor(mockDB)
+	predictor := NewAverageGoalsPredictor(memory.New())
 	assert.NotNil(t, predictor)
 }
 
 func TestAverageGoalsPredictor_Predict(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("AverageGoalsInLastMatches", 1, 8).Return(2.4, nil)
-	mockDB.On("AverageGoalsInLastMatches", 2, 8).Return(1.6, nil)
+	db := newTestStore(t)
+	db.play("Ajax", "PSV", 3, 1, day(2023, time.March, 1))
+	db.play("PSV", "Ajax", 2, 2, day(2023, time.April, 1))
+	db.play("Ajax", "PSV", 2, 2, day(2023, time.May, 1))
 
-	predictor := NewAverageGoalsPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	predictor := NewAverageGoalsPredictor(db)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Ajax"), db.teamID("PSV"))
 
 	expectedPrediction := &Prediction{HomeGoals: 2, AwayGoals: 2}
 	assert.Nil(t, err)
@@ -28,11 +28,8 @@ func TestAverageGoalsPredictor_Predict(t *testing.T) {
 }
 
 func TestAverageGoalsPredictor_PredictDatabaseError(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("AverageGoalsInLastMatches", 1, 8).Return(0.0, errors.New("some database error"))
-
-	predictor := NewAverageGoalsPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	predictor := NewAverageGoalsPredictor(failingStore{DB: memory.New(), err: errDatabase})
+	prediction, err := predictor.Predict(testCompetitionID, 1, 2)
 
 	assert.Nil(t, prediction)
 	assert.NotNil(t, err)
@@ -15,7 +15,7 @@ func NewCompositePredictor(predictors ...Predictor) *CompositePredictor {
 	return &CompositePredictor{predictors: predictors}
 }
 
-func (c *CompositePredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, error) {
+func (c *CompositePredictor) Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error) {
 	if len(c.predictors) == 0 {
 		return nil, errors.New("No predictors provided")
 	}
@@ -25,7 +25,7 @@ func (c *CompositePredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, e
 
 	for _, predictor := range c.predictors {
 		predictionStartTime := time.Now()
-		prediction, err := predictor.Predict(homeTeamID, awayTeamID)
+		prediction, err := predictor.Predict(competitionID, homeTeamID, awayTeamID)
 		predictionEndTime := time.Now()
 
 		if err != nil {
@@ -1,7 +1,7 @@
 package predictor
 
 type DB interface {
-	LastYearMatchScores(homeTeamID, awayTeamID int) (int, int, error)
-	AverageGoalsInLastMatches(teamID, matches int) (float64, error)
-	GetCurrentSeasonLeaderboard() (map[int]int, error)
+	LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error)
+	AverageGoalsInLastMatches(competitionID, teamID, matches int) (float64, error)
+	GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error)
 }
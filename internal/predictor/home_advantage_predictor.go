@@ -7,6 +7,6 @@ func NewHomeAdvantagePredictor() *HomeAdvantagePredictor {
 	return &HomeAdvantagePredictor{}
 }
 
-func (h *HomeAdvantagePredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, error) {
+func (h *HomeAdvantagePredictor) Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error) {
 	return &Prediction{HomeGoals: 1, AwayGoals: 0}, nil
 }
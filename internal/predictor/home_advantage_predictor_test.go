@@ -14,7 +14,7 @@ func TestNewHomeAdvantagePredictor(t *testing.T) {
 func TestHomeAdvantagePredictor_Predict(t *testing.T) {
 	predictor := NewHomeAdvantagePredictor()
 
-	prediction, err := predictor.Predict(1, 2)
+	prediction, err := predictor.Predict(testCompetitionID, 1, 2)
 
 	expectedPrediction := &Prediction{HomeGoals: 1, AwayGoals: 0}
 	assert.Nil(t, err)
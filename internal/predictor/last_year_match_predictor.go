@@ -17,12 +17,12 @@ func NewFlippedLastYearMatchPredictor(db DB) *LastYearMatchPredictor {
 	return &LastYearMatchPredictor{db: db, flippedTeams: true}
 }
 
-func (l *LastYearMatchPredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, error) {
+func (l *LastYearMatchPredictor) Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error) {
 	if l.flippedTeams {
 		homeTeamID, awayTeamID = awayTeamID, homeTeamID
 	}
 
-	homeGoals, awayGoals, err := l.db.LastYearMatchScores(homeTeamID, awayTeamID)
+	homeGoals, awayGoals, err := l.db.LastYearMatchScores(competitionID, homeTeamID, awayTeamID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -2,50 +2,48 @@ package predictor
 
 import (
 	"database/sql"
-	"errors"
 	"testing"
+	"time"
 
-	"github.com/jqno/balGPT/internal/database_test"
+	"github.com/jqno/balGPT/internal/database/memory"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewLastYearMatchPredictor(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewLastYearMatchPredictor(mockDB)
+	predictor := NewLastYearMatchPredictor(memory.New())
 	assert.False(t, predictor.flippedTeams)
 }
 
 func TestNewFlippedLastYearMatchPredictor(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewFlippedLastYearMatchPredictor(mockDB)
+	predictor := NewFlippedLastYearMatchPredictor(memory.New())
 	assert.True(t, predictor.flippedTeams)
 }
 
 func TestPredictWithFlippedTeams(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("LastYearMatchScores", 2, 1).Return(1, 2, nil)
-	predictor := NewFlippedLastYearMatchPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	db := newTestStore(t)
+	db.play("PSV", "Ajax", 1, 2, day(2023, time.March, 1))
+	predictor := NewFlippedLastYearMatchPredictor(db)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Ajax"), db.teamID("PSV"))
 
 	assert.Nil(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 2, AwayGoals: 1}, prediction)
 }
 
 func TestPredictWithoutFlippedTeams(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("LastYearMatchScores", 1, 2).Return(1, 2, nil)
-	predictor := NewLastYearMatchPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	db := newTestStore(t)
+	db.play("Ajax", "PSV", 3, 3, day(2022, time.March, 1))
+	db.play("Ajax", "PSV", 1, 2, day(2023, time.March, 1))
+	db.play("PSV", "Ajax", 4, 0, day(2023, time.April, 1))
+	predictor := NewLastYearMatchPredictor(db)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Ajax"), db.teamID("PSV"))
 
 	assert.Nil(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 1, AwayGoals: 2}, prediction)
 }
 
 func TestPredictDatabaseError(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("LastYearMatchScores", 1, 2).Return(0, 0, errors.New("some database error"))
-	predictor := NewLastYearMatchPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	predictor := NewLastYearMatchPredictor(failingStore{DB: memory.New(), err: errDatabase})
+	prediction, err := predictor.Predict(testCompetitionID, 1, 2)
 
 	assert.Nil(t, prediction)
 	assert.NotNil(t, err)
@@ -53,10 +51,8 @@ func TestPredictDatabaseError(t *testing.T) {
 }
 
 func TestPredictNoRowsError(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("LastYearMatchScores", 1, 2).Return(0, 0, sql.ErrNoRows)
-	predictor := NewLastYearMatchPredictor(mockDB)
-	prediction, err := predictor.Predict(1, 2)
+	predictor := NewLastYearMatchPredictor(failingStore{DB: memory.New(), err: sql.ErrNoRows})
+	prediction, err := predictor.Predict(testCompetitionID, 1, 2)
 
 	assert.Nil(t, prediction)
 	assert.Nil(t, err)
@@ -18,7 +18,7 @@ func NewLeaderboardDifferencePredictor(db DB) *LeaderboardDifferencePredictor {
 	return &LeaderboardDifferencePredictor{db: db}
 }
 
-func (l *LeaderboardDifferencePredictor) Predict(homeTeamID, awayTeamID int) (*Prediction, error) {
+func (l *LeaderboardDifferencePredictor) Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error) {
 	if homeTeamID == -1 && awayTeamID == -1 {
 		return &Prediction{HomeGoals: 0, AwayGoals: 0}, nil
 	} else if homeTeamID == -1 {
@@ -27,7 +27,7 @@ func (l *LeaderboardDifferencePredictor) Predict(homeTeamID, awayTeamID int) (*P
 		return &Prediction{HomeGoals: 1, AwayGoals: 0}, nil
 	}
 
-	leaderboard, err := l.db.GetCurrentSeasonLeaderboard()
+	leaderboard, err := l.db.GetCurrentSeasonLeaderboard(competitionID)
 	if err != nil {
 		return nil, err
 	}
@@ -2,101 +2,93 @@ package predictor
 
 import (
 	"testing"
+	"time"
 
-	"github.com/jqno/balGPT/internal/database_test"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/season"
 	"github.com/stretchr/testify/assert"
 )
 
+// newLeaderboardStore returns a store in which the teams finish the current season in
+// the given order, each beating the ones below it. The last team has no points, so it's
+// not on the leaderboard.
+func newLeaderboardStore(t *testing.T, teams ...string) *testStore {
+	db := newTestStore(t)
+	date := season.DefaultBoundary.SeasonAt(time.Now()).Start
+	for i, home := range teams {
+		for _, away := range teams[i+1:] {
+			db.play(home, away, 1, 0, date)
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+	return db
+}
+
 func TestPredictWithEmptyLeaderboard(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("GetCurrentSeasonLeaderboard").Return(map[int]int{}, nil)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	db := newTestStore(t)
+	predictor := NewLeaderboardDifferencePredictor(db)
 
-	prediction, err := predictor.Predict(1, 2)
+	prediction, err := predictor.Predict(db.competitionID, 1, 2)
 
 	assert.NoError(t, err)
 	assert.Nil(t, prediction)
-	mockDB.AssertExpectations(t)
 }
 
 func TestPredictWithHomeTeamLeading(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("GetCurrentSeasonLeaderboard").Return(map[int]int{
-		1: 25, // home team
-		2: 20,
-		3: 15,
-		4: 10, // away team
-	}, nil)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	db := newLeaderboardStore(t, "Ajax", "PSV", "AZ", "Feyenoord", "Twente")
+	predictor := NewLeaderboardDifferencePredictor(db)
 
-	prediction, err := predictor.Predict(1, 4)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Ajax"), db.teamID("Feyenoord"))
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 1, AwayGoals: 0}, prediction)
-	mockDB.AssertExpectations(t)
 }
 
 func TestPredictWithAwayTeamLeading(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("GetCurrentSeasonLeaderboard").Return(map[int]int{
-		1: 10, // home team
-		2: 15,
-		3: 20,
-		4: 25, // away team
-	}, nil)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	db := newLeaderboardStore(t, "Ajax", "PSV", "AZ", "Feyenoord", "Twente")
+	predictor := NewLeaderboardDifferencePredictor(db)
 
-	prediction, err := predictor.Predict(1, 4)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Feyenoord"), db.teamID("Ajax"))
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 0, AwayGoals: 1}, prediction)
-	mockDB.AssertExpectations(t)
 }
 
 func TestPredictWithEqualTeams(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("GetCurrentSeasonLeaderboard").Return(map[int]int{
-		1: 10, // home team
-		2: 10, // away team
-	}, nil)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	db := newTestStore(t)
+	db.play("Ajax", "PSV", 1, 1, season.DefaultBoundary.SeasonAt(time.Now()).Start)
+	predictor := NewLeaderboardDifferencePredictor(db)
 
-	prediction, err := predictor.Predict(1, 2)
+	prediction, err := predictor.Predict(db.competitionID, db.teamID("Ajax"), db.teamID("PSV"))
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 0, AwayGoals: 0}, prediction)
-	mockDB.AssertExpectations(t)
 }
 
+// The leaderboard isn't needed for unknown teams, so a failing store doesn't matter.
 func TestPredictWithBothTeamsMinusOne(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	predictor := NewLeaderboardDifferencePredictor(failingStore{DB: memory.New(), err: errDatabase})
 
-	prediction, err := predictor.Predict(-1, -1)
+	prediction, err := predictor.Predict(testCompetitionID, -1, -1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 0, AwayGoals: 0}, prediction)
-	mockDB.AssertExpectations(t)
 }
 
 func TestPredictWithHomeTeamMinusOne(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	predictor := NewLeaderboardDifferencePredictor(failingStore{DB: memory.New(), err: errDatabase})
 
-	prediction, err := predictor.Predict(-1, 4)
+	prediction, err := predictor.Predict(testCompetitionID, -1, 4)
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 0, AwayGoals: 1}, prediction)
-	mockDB.AssertExpectations(t)
 }
 
 func TestPredictWithAwayTeamMinusOne(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	predictor := NewLeaderboardDifferencePredictor(mockDB)
+	predictor := NewLeaderboardDifferencePredictor(failingStore{DB: memory.New(), err: errDatabase})
 
-	prediction, err := predictor.Predict(1, -1)
+	prediction, err := predictor.Predict(testCompetitionID, 1, -1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, &Prediction{HomeGoals: 1, AwayGoals: 0}, prediction)
-	mockDB.AssertExpectations(t)
 }
@@ -6,5 +6,5 @@ type Prediction struct {
 }
 
 type Predictor interface {
-	Predict(homeTeamID, awayTeamID int) (*Prediction, error)
+	Predict(competitionID, homeTeamID, awayTeamID int) (*Prediction, error)
 }
@@ -0,0 +1,70 @@
+package predictor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/stretchr/testify/require"
+)
+
+var errDatabase = errors.New("some database error")
+
+// testStore is an in-memory store with matches of the Eredivisie.
+type testStore struct {
+	*memory.DB
+	t             *testing.T
+	competitionID int
+}
+
+func newTestStore(t *testing.T) *testStore {
+	db := memory.New()
+	competitionID, err := db.GetCompetitionID("Eredivisie")
+	require.NoError(t, err)
+	return &testStore{DB: db, t: t, competitionID: competitionID}
+}
+
+// play stores a match that was played on the given date, and refreshes the team
+// statistics like a scrape does.
+func (s *testStore) play(home, away string, homeGoals, awayGoals int, date time.Time) {
+	require.NoError(s.t, s.InsertOrUpdateMatch(match.Match{
+		Competition: "Eredivisie",
+		HomeTeam:    home,
+		AwayTeam:    away,
+		HomeGoals:   homeGoals,
+		AwayGoals:   awayGoals,
+		Date:        date,
+		Status:      match.Played,
+	}))
+	require.NoError(s.t, s.RefreshTeamStats())
+}
+
+func (s *testStore) teamID(name string) int {
+	teamID, err := s.GetTeamID(name)
+	require.NoError(s.t, err)
+	return teamID
+}
+
+// failingStore fails every query of the predictors with err.
+type failingStore struct {
+	*memory.DB
+	err error
+}
+
+func (s failingStore) LastYearMatchScores(competitionID, homeTeamID, awayTeamID int) (int, int, error) {
+	return 0, 0, s.err
+}
+
+func (s failingStore) AverageGoalsInLastMatches(competitionID, teamID, matches int) (float64, error) {
+	return 0, s.err
+}
+
+func (s failingStore) GetCurrentSeasonLeaderboard(competitionID int) (map[int]int, error) {
+	return nil, s.err
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
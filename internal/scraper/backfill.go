@@ -0,0 +1,49 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jqno/balGPT/internal/season"
+)
+
+// SeasonPlaceholder is replaced by the season name, such as "2022-2023", in backfill
+// URL templates.
+const SeasonPlaceholder = "{season}"
+
+// Backfill scrapes the results page of every season from fromYear up to and including
+// toYear, waiting the given delay between requests. Seasons are identified by the year
+// in which they start. Each season is stored while holding the scrape lock, so a backfill
+// never overlaps with a scrape or a reparse.
+func (scraped *ScrapeData) Backfill(competition, urlTemplate string, fromYear, toYear int, delay time.Duration) error {
+	if !strings.Contains(urlTemplate, SeasonPlaceholder) {
+		return fmt.Errorf("URL template %q does not contain %s", urlTemplate, SeasonPlaceholder)
+	}
+	if fromYear > toYear {
+		return errors.New("the first season must not come after the last season")
+	}
+
+	for year := fromYear; year <= toYear; year++ {
+		if year > fromYear {
+			time.Sleep(delay)
+		}
+
+		season := SeasonName(year)
+		url := strings.ReplaceAll(urlTemplate, SeasonPlaceholder, season)
+		log.Printf("Backfilling %s season %s from %s", competition, season, url)
+
+		if err := scraped.scrapeSource(Source{Competition: competition, URL: url}); err != nil {
+			return fmt.Errorf("Error backfilling %s season %s: %v", competition, season, err)
+		}
+	}
+
+	return nil
+}
+
+// SeasonName returns the name of the season that starts in the given year.
+func SeasonName(startYear int) string {
+	return season.Name(startYear)
+}
@@ -1,9 +1,34 @@
 package scraper
 
-import "time"
+import (
+	"time"
+
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/match"
+	"github.com/jqno/balGPT/internal/scraperun"
+)
 
 type DB interface {
+	Tx
 	GetLastScrape() (time.Time, error)
-	InsertOrUpdateMatch(homeTeam, awayTeam string, homeGoals, awayGoals int, date time.Time) error
-	UpdateLastScrape(t time.Time) error
+	GetTeamID(teamName string) (int, error)
+	FindMatch(m match.Match) (*match.Match, error)
+	ArchivePage(competition, url string, body []byte, fetchedAt time.Time) error
+	EachArchivedPage(since time.Time, fn func(page archive.Page) error) error
+	WithScrapeLock(fn func() error) error
+	GetSourceRowCount(url string) (int, error)
+	// InTransaction runs fn in a transaction, which is only committed if fn returns nil.
+	InTransaction(fn func(tx Tx) error) error
+}
+
+// Tx contains the writes that the scraper performs in a transaction.
+type Tx interface {
+	InsertOrUpdateMatch(m match.Match) error
+	BulkLoadMatches(matches []match.Match) (int64, error)
+	// RecordScrapeRuns stores what happened when sources were scraped.
+	RecordScrapeRuns(runs []scraperun.Run) error
+	DeleteMatches(competition string, from, to time.Time) (int64, error)
+	UpdateSourceRowCount(url string, rows int, scrapeTime time.Time) error
+	// RefreshTeamStats recomputes the team statistics from the stored matches.
+	RefreshTeamStats() error
 }
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jqno/balGPT/internal/match"
+)
+
+type DryRunReport struct {
+	Sources []SourceReport `json:"sources"`
+}
+
+// SourceReport describes what a scrape of a single source would change.
+type SourceReport struct {
+	Competition  string        `json:"competition"`
+	URL          string        `json:"url"`
+	NewMatches   []match.Match `json:"new_matches"`
+	NewTeams     []string      `json:"new_teams"`
+	ScoreChanges []ScoreChange `json:"score_changes"`
+	Unchanged    int           `json:"unchanged"`
+	// Skipped describes the rows that couldn't be parsed and would be skipped.
+	Skipped []string `json:"skipped"`
+}
+
+type ScoreChange struct {
+	Scraped match.Match `json:"scraped"`
+	Stored  match.Match `json:"stored"`
+}
+
+// DryRun parses all sources and compares the results with the database, without
+// writing anything.
+func (scraped *ScrapeData) DryRun(ctx context.Context) (*DryRunReport, error) {
+	report := &DryRunReport{Sources: []SourceReport{}}
+	for _, source := range scraped.Sources {
+		sourceReport, err := scraped.dryRunSource(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		report.Sources = append(report.Sources, *sourceReport)
+	}
+	return report, nil
+}
+
+func (scraped *ScrapeData) dryRunSource(ctx context.Context, source Source) (*SourceReport, error) {
+	// We need the contents of the page, even if it hasn't changed. Afterwards, the
+	// validators of this fetch are dropped as well: otherwise the next scrape would be
+	// told that the page hasn't changed, and store nothing.
+	scraped.forget(source.URL)
+	defer scraped.forget(source.URL)
+
+	page, err := scraped.Fetcher.Fetch(ctx, source.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parsePage(source.Competition, page.Body, scraped.DateParser)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SourceReport{
+		Competition:  source.Competition,
+		URL:          source.URL,
+		NewMatches:   []match.Match{},
+		NewTeams:     []string{},
+		ScoreChanges: []ScoreChange{},
+		Skipped:      []string{},
+	}
+	for _, problem := range parsed.Problems {
+		report.Skipped = append(report.Skipped, problem.Error())
+	}
+
+	seenTeams := map[string]bool{}
+	for _, m := range parsed.Matches {
+		for _, team := range []string{m.HomeTeam, m.AwayTeam} {
+			if seenTeams[team] {
+				continue
+			}
+			seenTeams[team] = true
+
+			_, err := scraped.DB.GetTeamID(team)
+			if err == sql.ErrNoRows {
+				report.NewTeams = append(report.NewTeams, team)
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		stored, err := scraped.DB.FindMatch(m)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case stored == nil:
+			report.NewMatches = append(report.NewMatches, m)
+		case !sameScore(m, *stored):
+			report.ScoreChanges = append(report.ScoreChanges, ScoreChange{Scraped: m, Stored: *stored})
+		default:
+			report.Unchanged++
+		}
+	}
+
+	return report, nil
+}
+
+func sameScore(a, b match.Match) bool {
+	if a.HasScore() != b.HasScore() {
+		return false
+	}
+	return !a.HasScore() || (a.HomeGoals == b.HomeGoals && a.AwayGoals == b.AwayGoals)
+}
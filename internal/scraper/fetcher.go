@@ -0,0 +1,11 @@
+package scraper
+
+import (
+	"context"
+
+	"github.com/jqno/balGPT/internal/fetcher"
+)
+
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*fetcher.Page, error)
+}
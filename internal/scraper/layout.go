@@ -0,0 +1,34 @@
+package scraper
+
+import "fmt"
+
+// minRowRatio is the fraction of the rows found during the previous scrape of a page,
+// below which we assume that the layout of the page has changed.
+const minRowRatio = 0.5
+
+// LayoutError is returned when a page doesn't look like the results pages the parser
+// knows, which usually means that the site has changed its layout.
+type LayoutError struct {
+	URL    string
+	Reason string
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("layout of %s seems to have changed: %s", e.URL, e.Reason)
+}
+
+// checkLayout compares a parsed page with what we expect from a results page, and with
+// the number of rows that were found on it during the previous scrape.
+func checkLayout(url string, parsed *parsedPage, previousRows int) error {
+	switch {
+	case parsed.Rows == 0:
+		return &LayoutError{URL: url, Reason: "no .matches-panel rows found"}
+	case parsed.DateHeaders == 0:
+		return &LayoutError{URL: url, Reason: "no date headers found"}
+	case parsed.InvalidDateHeaders == parsed.DateHeaders:
+		return &LayoutError{URL: url, Reason: fmt.Sprintf("none of the %d date headers could be parsed", parsed.DateHeaders)}
+	case float64(parsed.Rows) < minRowRatio*float64(previousRows):
+		return &LayoutError{URL: url, Reason: fmt.Sprintf("found %d rows, down from %d during the previous scrape", parsed.Rows, previousRows)}
+	}
+	return nil
+}
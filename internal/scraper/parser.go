@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// Row classes that the source uses for the status of a match. Rows without one of these
+// are matches that haven't been played yet. Only Played has been seen on a saved page
+// (testdata/kkd-2022-08-15.html); the others are the source's names for the statuses
+// that we expect, and rows with them are skipped as unplayed if they turn out different.
+var statusClasses = map[string]match.Status{
+	"Played":    match.Played,
+	"Postponed": match.Postponed,
+	"Abandoned": match.Abandoned,
+	"Awarded":   match.Awarded,
+}
+
+var (
+	kickoffTime   = regexp.MustCompile(`\b(\d{1,2}):(\d{2})\b`)
+	halfTimeScore = regexp.MustCompile(`(\d+)\s*-\s*(\d+)`)
+	// matchDate is the day and month at the end of a match link, like "mvv-nac-15-08"
+	matchDate = regexp.MustCompile(`-\d{2}-\d{2}$`)
+)
+
+type parsedPage struct {
+	Matches []match.Match
+	// Problems describes the rows that couldn't be parsed.
+	Problems []error
+	// Rows, DateHeaders and InvalidDateHeaders are used to detect changes in the layout
+	// of the page.
+	Rows               int
+	DateHeaders        int
+	InvalidDateHeaders int
+}
+
+// parsePage extracts the played, postponed, abandoned and awarded matches from a
+// results page.
+func parsePage(competition string, body []byte, dateParser *dates.Parser) (*parsedPage, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &parsedPage{Matches: []match.Match{}}
+	currentDate := time.Time{}
+	dateHeader := ""
+	rows := doc.Find(".matches-panel")
+	result.Rows = rows.Length()
+	rows.Each(func(i int, selection *goquery.Selection) {
+		if selection.HasClass("align-left") && selection.HasClass("justify-center") {
+			result.DateHeaders++
+			dateHeader = strings.TrimSpace(selection.Text())
+			currentDate, err = dateParser.Parse(dateHeader)
+			if err != nil {
+				result.InvalidDateHeaders++
+				result.Problems = append(result.Problems, fmt.Errorf("Date header: %v", err))
+			}
+			return
+		}
+
+		status, ok := parseStatus(selection)
+		if !ok {
+			return
+		}
+
+		homeTeam := strings.TrimSpace(selection.Find(".left-team > span").Text())
+		awayTeam := strings.TrimSpace(selection.Find(".right-team > span").Text())
+
+		if homeTeam == "" || awayTeam == "" {
+			result.Problems = append(result.Problems, fmt.Errorf("Row %d: missing team names", i))
+			return
+		}
+
+		if currentDate.IsZero() {
+			result.Problems = append(result.Problems, fmt.Errorf("Row %d: %s - %s has no valid date (header %q)", i, homeTeam, awayTeam, dateHeader))
+			return
+		}
+
+		m := match.Match{
+			ExternalID:  externalID(selection.Find("a.score").AttrOr("href", "")),
+			Competition: competition,
+			HomeTeam:    homeTeam,
+			AwayTeam:    awayTeam,
+			Date:        currentDate,
+			Status:      status,
+			Kickoff:     parseKickoff(selection, currentDate),
+		}
+
+		if m.HasScore() {
+			scoreSelection := selection.Find(".score > div > i")
+			homeGoalsStr := strings.TrimSpace(scoreSelection.First().Text())
+			awayGoalsStr := strings.TrimSpace(scoreSelection.Last().Text())
+
+			m.HomeGoals, err = strconv.Atoi(homeGoalsStr)
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Errorf("Row %d: %s - %s has invalid home goals %q", i, homeTeam, awayTeam, homeGoalsStr))
+				return
+			}
+
+			m.AwayGoals, err = strconv.Atoi(awayGoalsStr)
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Errorf("Row %d: %s - %s has invalid away goals %q", i, homeTeam, awayTeam, awayGoalsStr))
+				return
+			}
+
+			m.HalfTimeHomeGoals, m.HalfTimeAwayGoals = parseHalfTimeScore(selection)
+		}
+
+		result.Matches = append(result.Matches, m)
+	})
+
+	return result, nil
+}
+
+func parseStatus(selection *goquery.Selection) (match.Status, bool) {
+	for class, status := range statusClasses {
+		if selection.HasClass(class) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// externalID identifies a match by the link to its page, without the date at the end,
+// so that the ID survives when the match is rescheduled.
+func externalID(href string) string {
+	return matchDate.ReplaceAllString(strings.TrimSpace(href), "")
+}
+
+// parseKickoff and parseHalfTimeScore read the .match-time and .half-time-score elements,
+// which haven't been seen on a saved page yet. Without them, a match simply has no kickoff
+// time or half-time score.
+func parseKickoff(selection *goquery.Selection, date time.Time) time.Time {
+	if date.IsZero() {
+		return time.Time{}
+	}
+
+	groups := kickoffTime.FindStringSubmatch(selection.Find(".match-time").Text())
+	if groups == nil {
+		return time.Time{}
+	}
+
+	hour, _ := strconv.Atoi(groups[1])
+	minute, _ := strconv.Atoi(groups[2])
+	if hour > 23 || minute > 59 {
+		return time.Time{}
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+}
+
+func parseHalfTimeScore(selection *goquery.Selection) (*int, *int) {
+	groups := halfTimeScore.FindStringSubmatch(selection.Find(".half-time-score").Text())
+	if groups == nil {
+		return nil, nil
+	}
+
+	homeGoals, _ := strconv.Atoi(groups[1])
+	awayGoals, _ := strconv.Atoi(groups[2])
+	return &homeGoals, &awayGoals
+}
@@ -0,0 +1,84 @@
+package scraper
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/match"
+)
+
+// Reparse runs the parser over all pages archived since the given time and stores the
+// matches it finds. If rebuild is set, the stored matches of each competition within
+// the range of dates found on the archived pages are deleted first, so that matches
+// that were stored incorrectly by an earlier version of the parser disappear. All
+// changes are made in a single transaction, while holding the scrape lock.
+func (scraped *ScrapeData) Reparse(since time.Time, rebuild bool) error {
+	return scraped.DB.WithScrapeLock(func() error {
+		return scraped.reparse(since, rebuild)
+	})
+}
+
+func (scraped *ScrapeData) reparse(since time.Time, rebuild bool) error {
+	matchesByCompetition := map[string][]match.Match{}
+	competitions := []string{}
+	pages := 0
+
+	err := scraped.DB.EachArchivedPage(since, func(page archive.Page) error {
+		parsed, err := parsePage(page.Competition, page.Body, scraped.DateParser)
+		if err != nil {
+			return fmt.Errorf("Error parsing archived page %s from %v: %v", page.URL, page.FetchedAt, err)
+		}
+		logProblems(page.URL, parsed.Problems)
+
+		if _, ok := matchesByCompetition[page.Competition]; !ok {
+			competitions = append(competitions, page.Competition)
+		}
+		matchesByCompetition[page.Competition] = append(matchesByCompetition[page.Competition], parsed.Matches...)
+		pages++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Parsed %d archived pages", pages)
+
+	return scraped.DB.InTransaction(func(tx Tx) error {
+		for _, competition := range competitions {
+			matches := matchesByCompetition[competition]
+
+			if rebuild && len(matches) > 0 {
+				from, to := dateRange(matches)
+				deleted, err := tx.DeleteMatches(competition, from, to)
+				if err != nil {
+					return err
+				}
+				log.Printf("Deleted %d matches of %s between %s and %s", deleted, competition, from.Format("2006-01-02"), to.Format("2006-01-02"))
+			}
+
+			stored, err := tx.BulkLoadMatches(matches)
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Stored %d matches of %s", stored, competition)
+		}
+
+		return tx.RefreshTeamStats()
+	})
+}
+
+func dateRange(matches []match.Match) (time.Time, time.Time) {
+	from, to := matches[0].Date, matches[0].Date
+	for _, m := range matches[1:] {
+		if m.Date.Before(from) {
+			from = m.Date
+		}
+		if m.Date.After(to) {
+			to = m.Date
+		}
+	}
+	return from, to
+}
@@ -1,25 +1,95 @@
 package scraper
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
-	"regexp"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/fetcher"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"golang.org/x/sync/singleflight"
 )
 
 type ScrapeData struct {
-	DB  DB
-	URL string
+	DB         DB
+	Fetcher    Fetcher
+	DateParser *dates.Parser
+	Sources    []Source
+
+	group  singleflight.Group
+	mu     sync.Mutex
+	health Health
+}
+
+// Health describes the outcome of the most recent scrapes.
+type Health struct {
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   error
+}
+
+// Failing reports whether the most recent scrape failed.
+func (h Health) Failing() bool {
+	return h.LastFailure.After(h.LastSuccess)
 }
 
-func NewScrapeData(db DB, url string) *ScrapeData {
-	return &ScrapeData{DB: db, URL: url}
+// Source is a page listing the results of a single competition.
+type Source struct {
+	Competition string
+	URL         string
 }
 
-func (scraped *ScrapeData) Scrape() error {
+func NewScrapeData(db DB, f Fetcher, sources ...Source) *ScrapeData {
+	dateParser := dates.NewParser(dates.Amsterdam, dates.Dutch, dates.English)
+	return &ScrapeData{DB: db, Fetcher: f, DateParser: dateParser, Sources: sources}
+}
+
+// Scrape scrapes all sources, unless that already happened today. Concurrent callers
+// share a single scrape, which is recorded with the trigger of the first one and fetches
+// with its ctx, and the database makes sure that other instances of the app don't
+// scrape at the same time. A caller whose ctx is done stops waiting for the scrape.
+func (scraped *ScrapeData) Scrape(ctx context.Context, trigger scraperun.Trigger) error {
+	result := scraped.group.DoChan("scrape", func() (interface{}, error) {
+		err := scraped.DB.WithScrapeLock(func() error {
+			return scraped.scrapeIfStale(ctx, trigger)
+		})
+		scraped.recordResult(err)
+		return nil, err
+	})
+
+	select {
+	case r := <-result:
+		return r.Err
+	case <-ctx.Done():
+		return fmt.Errorf("Error waiting for the scrape: %w", ctx.Err())
+	}
+}
+
+func (scraped *ScrapeData) Health() Health {
+	scraped.mu.Lock()
+	defer scraped.mu.Unlock()
+
+	return scraped.health
+}
+
+func (scraped *ScrapeData) recordResult(err error) {
+	scraped.mu.Lock()
+	defer scraped.mu.Unlock()
+
+	if err != nil {
+		scraped.health.LastFailure = time.Now()
+		scraped.health.LastError = err
+	} else {
+		scraped.health.LastSuccess = time.Now()
+	}
+}
+
+func (scraped *ScrapeData) scrapeIfStale(ctx context.Context, trigger scraperun.Trigger) error {
 	lastScrape, err := scraped.DB.GetLastScrape()
 	if err != nil {
 		return err
@@ -29,83 +99,193 @@ func (scraped *ScrapeData) Scrape() error {
 		return nil
 	}
 
-	res, err := http.Get(scraped.URL)
-	if err != nil {
-		return err
+	runs := []*scraperun.Run{}
+	pages := []*scrapedPage{}
+	for _, source := range scraped.Sources {
+		run := &scraperun.Run{Trigger: trigger, Competition: source.Competition, SourceURL: source.URL, StartedAt: time.Now()}
+		runs = append(runs, run)
+
+		page, err := scraped.fetchSource(ctx, source, run)
+		run.FinishedAt = time.Now()
+		if err != nil {
+			run.Error = err.Error()
+			scraped.forgetPages(pages)
+			scraped.recordFailedRuns(runs, err)
+			return err
+		}
+		if page != nil {
+			page.Run = run
+			pages = append(pages, page)
+		}
 	}
-	defer res.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	err = scraped.DB.InTransaction(func(tx Tx) error {
+		if err := storePages(tx, pages); err != nil {
+			return err
+		}
+		if len(pages) > 0 {
+			if err := tx.RefreshTeamStats(); err != nil {
+				return err
+			}
+		}
+		return tx.RecordScrapeRuns(runValues(runs))
+	})
 	if err != nil {
+		scraped.forgetPages(pages)
+		scraped.recordFailedRuns(runs, err)
 		return err
 	}
 
-	currentDate := time.Time{}
-	doc.Find(".matches-panel").EachWithBreak(func(i int, selection *goquery.Selection) bool {
-		if selection.HasClass("align-left") && selection.HasClass("justify-center") {
-			dateStr := strings.TrimSpace(selection.Text())
-			re := regexp.MustCompile(`^\w+\s`)
-			dateStr = re.ReplaceAllString(dateStr, "")
-			currentDate, _ = parseDutchDate(dateStr)
-			return true
-		}
+	return nil
+}
 
-		if !selection.HasClass("Played") {
-			return true
+// recordFailedRuns records the runs of a scrape that failed. The runs of sources that
+// succeeded by themselves get the error too, since their matches weren't stored either.
+func (scraped *ScrapeData) recordFailedRuns(runs []*scraperun.Run, err error) {
+	for _, run := range runs {
+		run.RowsInserted = 0
+		if run.Succeeded() {
+			run.Error = fmt.Sprintf("not stored: %v", err)
 		}
+	}
+	if err := scraped.DB.RecordScrapeRuns(runValues(runs)); err != nil {
+		log.Printf("Error recording scrape runs: %v", err)
+	}
+}
 
-		homeTeam := strings.TrimSpace(selection.Find(".left-team > span").Text())
-		awayTeam := strings.TrimSpace(selection.Find(".right-team > span").Text())
+func runValues(runs []*scraperun.Run) []scraperun.Run {
+	values := make([]scraperun.Run, len(runs))
+	for i, run := range runs {
+		values[i] = *run
+	}
+	return values
+}
 
-		scoreSelection := selection.Find(".score > div > i")
-		homeGoalsStr := strings.TrimSpace(scoreSelection.First().Text())
-		awayGoalsStr := strings.TrimSpace(scoreSelection.Last().Text())
+// scrapedPage is a page that was fetched and parsed, but whose matches haven't been
+// stored yet.
+type scrapedPage struct {
+	Source    Source
+	Parsed    *parsedPage
+	FetchedAt time.Time
+	// Run is nil when the scrape isn't recorded
+	Run *scraperun.Run
+}
 
-		homeGoals, err := strconv.Atoi(homeGoalsStr)
-		if err != nil {
-			return true
-		}
+// scrapeSource fetches a single source and bulk loads its matches in a transaction of
+// its own, while holding the scrape lock. It's used by the CLI, so it waits as long as
+// the source asks.
+func (scraped *ScrapeData) scrapeSource(source Source) error {
+	return scraped.DB.WithScrapeLock(func() error {
+		return scraped.scrapeSourceLocked(source)
+	})
+}
 
-		awayGoals, err := strconv.Atoi(awayGoalsStr)
-		if err != nil {
-			return true
-		}
+func (scraped *ScrapeData) scrapeSourceLocked(source Source) error {
+	page, err := scraped.fetchSource(context.Background(), source, &scraperun.Run{})
+	if err != nil || page == nil {
+		return err
+	}
 
-		if homeTeam == "" || awayTeam == "" {
-			return true
+	err = scraped.DB.InTransaction(func(tx Tx) error {
+		if _, err := tx.BulkLoadMatches(page.Parsed.Matches); err != nil {
+			return err
 		}
-
-		err = scraped.DB.InsertOrUpdateMatch(homeTeam, awayTeam, homeGoals, awayGoals, currentDate)
-		if err != nil {
-			return true
+		if err := tx.UpdateSourceRowCount(source.URL, page.Parsed.Rows, page.FetchedAt); err != nil {
+			return err
 		}
-
-		return true
+		return tx.RefreshTeamStats()
 	})
-
-	err = scraped.DB.UpdateLastScrape(time.Now())
 	if err != nil {
+		scraped.forget(source.URL)
 		return err
 	}
 
 	return nil
 }
 
-func parseDutchDate(dateStr string) (time.Time, error) {
-	monthNameToNumber := map[string]string{
-		"januari": "01", "februari": "02", "maart": "03", "april": "04", "mei": "05", "juni": "06",
-		"juli": "07", "augustus": "08", "september": "09", "oktober": "10", "november": "11", "december": "12",
+// fetchSource fetches, archives and parses a source, and notes what it found in run. It
+// returns nil if the page hasn't changed since the last scrape.
+func (scraped *ScrapeData) fetchSource(ctx context.Context, source Source, run *scraperun.Run) (*scrapedPage, error) {
+	page, err := scraped.Fetcher.Fetch(ctx, source.URL)
+	if errors.Is(err, fetcher.ErrNotModified) {
+		log.Printf("Page for %s has not changed since the last scrape", source.Competition)
+		run.HTTPStatus = http.StatusNotModified
+		return nil, nil
 	}
+	var statusErr *fetcher.StatusError
+	if errors.As(err, &statusErr) {
+		run.HTTPStatus = statusErr.StatusCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.HTTPStatus = page.StatusCode
 
-	for monthName, monthNumber := range monthNameToNumber {
-		dateStr = strings.Replace(dateStr, monthName, monthNumber, 1)
+	if err := scraped.DB.ArchivePage(source.Competition, page.URL, page.Body, page.FetchedAt); err != nil {
+		scraped.forget(source.URL)
+		return nil, err
 	}
 
-	// Parse the date string
-	date, err := time.Parse("2 01 2006", dateStr)
+	parsed, err := parsePage(source.Competition, page.Body, scraped.DateParser)
 	if err != nil {
-		return time.Time{}, err
+		scraped.forget(source.URL)
+		return nil, err
 	}
+	logProblems(page.URL, parsed.Problems)
+	run.RowsParsed = len(parsed.Matches)
+	run.RowsSkipped = len(parsed.Problems)
 
-	return date, nil
+	previousRows, err := scraped.DB.GetSourceRowCount(source.URL)
+	if err != nil {
+		scraped.forget(source.URL)
+		return nil, err
+	}
+	if err := checkLayout(page.URL, parsed, previousRows); err != nil {
+		log.Printf("Not storing matches of %s: %v", source.Competition, err)
+		scraped.forget(source.URL)
+		return nil, err
+	}
+
+	return &scrapedPage{Source: source, Parsed: parsed, FetchedAt: page.FetchedAt}, nil
+}
+
+func storePages(tx Tx, pages []*scrapedPage) error {
+	for _, page := range pages {
+		for _, m := range page.Parsed.Matches {
+			if err := tx.InsertOrUpdateMatch(m); err != nil {
+				return fmt.Errorf("Error storing match %s - %s: %v", m.HomeTeam, m.AwayTeam, err)
+			}
+			if page.Run != nil {
+				page.Run.RowsInserted++
+			}
+		}
+		if page.Run != nil {
+			page.Run.FinishedAt = time.Now()
+		}
+
+		if err := tx.UpdateSourceRowCount(page.Source.URL, page.Parsed.Rows, page.FetchedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func logProblems(url string, problems []error) {
+	for _, problem := range problems {
+		log.Printf("Problem parsing %s: %v", url, problem)
+	}
+}
+
+// forget makes sure that a page that couldn't be processed is downloaded again on the
+// next scrape, even if it hasn't changed.
+func (scraped *ScrapeData) forget(url string) {
+	if f, ok := scraped.Fetcher.(interface{ Forget(url string) }); ok {
+		f.Forget(url)
+	}
+}
+
+func (scraped *ScrapeData) forgetPages(pages []*scrapedPage) {
+	for _, page := range pages {
+		scraped.forget(page.Source.URL)
+	}
 }
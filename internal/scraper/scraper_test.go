@@ -1,116 +1,593 @@
 package scraper_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/jqno/balGPT/internal/database_test"
+	"github.com/jqno/balGPT/internal/archive"
+	"github.com/jqno/balGPT/internal/database"
+	"github.com/jqno/balGPT/internal/database/memory"
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/jqno/balGPT/internal/fetcher"
+	"github.com/jqno/balGPT/internal/match"
 	"github.com/jqno/balGPT/internal/scraper"
-	"github.com/stretchr/testify/mock"
+	"github.com/jqno/balGPT/internal/scraperun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestScrape(t *testing.T) {
-	mockDB := new(database_test.MockDB)
-	mockDB.On("GetLastScrape").Return(time.Time{}, nil)
+// testDB is an in-memory store that hands the scraper transactions that satisfy its own
+// interface. It counts the scrape locks and the transactions that ran without one, and
+// can make storing matches fail.
+type testDB struct {
+	*memory.DB
+	scrapeLocks          int32
+	locked               int32
+	unlockedTransactions int32
+	// insertErr returns the error with which storing a match fails, if any
+	insertErr func(m match.Match) error
+}
+
+func newTestDB() *testDB {
+	return &testDB{DB: memory.New()}
+}
+
+func (db *testDB) WithScrapeLock(fn func() error) error {
+	atomic.AddInt32(&db.scrapeLocks, 1)
+	return db.DB.WithScrapeLock(func() error {
+		atomic.StoreInt32(&db.locked, 1)
+		defer atomic.StoreInt32(&db.locked, 0)
+		return fn()
+	})
+}
 
-	// set expected matches
-	expectedMatches := []struct {
-		homeTeam  string
-		awayTeam  string
-		homeGoals int
-		awayGoals int
-		date      time.Time
-	}{
-		{"Jong Utrecht", "Heracles", 0, 3, time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)},
-		{"Jong PSV", "Dordrecht", 1, 0, time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)},
-		{"MVV", "NAC", 3, 1, time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)},
+func (db *testDB) InTransaction(fn func(tx scraper.Tx) error) error {
+	if atomic.LoadInt32(&db.locked) == 0 {
+		atomic.AddInt32(&db.unlockedTransactions, 1)
 	}
+	return db.DB.InTransaction(func(tx database.Store) error {
+		return fn(testTx{Store: tx, db: db})
+	})
+}
+
+type testTx struct {
+	database.Store
+	db *testDB
+}
 
-	for _, match := range expectedMatches {
-		mockDB.On("InsertOrUpdateMatch", match.homeTeam, match.awayTeam, match.homeGoals, match.awayGoals, match.date).Return(nil)
+func (tx testTx) InsertOrUpdateMatch(m match.Match) error {
+	if tx.db.insertErr != nil {
+		if err := tx.db.insertErr(m); err != nil {
+			return err
+		}
 	}
+	return tx.Store.InsertOrUpdateMatch(m)
+}
 
-	mockDB.On("UpdateLastScrape", mock.AnythingOfType("time.Time")).Return(nil)
+// matches returns the stored matches, in order of date.
+func (db *testDB) matches(t *testing.T) []match.Match {
+	matches := []match.Match{}
+	require.NoError(t, db.EachMatch(match.Filter{}, func(m match.Match) error {
+		matches = append(matches, m)
+		return nil
+	}))
+	return matches
+}
 
+func (db *testDB) runs(t *testing.T) []scraperun.Run {
+	runs, err := db.GetScrapeRuns(100)
+	require.NoError(t, err)
+	return runs
+}
+
+func (db *testDB) archivedPages(t *testing.T) []archive.Page {
+	pages := []archive.Page{}
+	require.NoError(t, db.EachArchivedPage(time.Time{}, func(page archive.Page) error {
+		pages = append(pages, page)
+		return nil
+	}))
+	return pages
+}
+
+func (db *testDB) sourceRowCount(t *testing.T, url string) int {
+	rows, err := db.GetSourceRowCount(url)
+	require.NoError(t, err)
+	return rows
+}
+
+func serve(t *testing.T, body string) *httptest.Server {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(testData))
+		w.Write([]byte(body))
 	}))
-	defer ts.Close()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// testDate is the day of the matches in testData, as the store returns it: a calendar day
+// without a time zone, like a DATE column.
+var testDate = time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)
 
-	scraped := scraper.NewScrapeData(mockDB, ts.URL)
-	err := scraped.Scrape()
+func TestScrape(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testData)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
 
 	// check no error returned
 	if err != nil {
 		t.Fatalf("Scrape() error = %v", err)
 	}
 
-	for _, match := range expectedMatches {
-		mockDB.AssertCalled(t, "InsertOrUpdateMatch", match.homeTeam, match.awayTeam, match.homeGoals, match.awayGoals, match.date)
+	expectedMatches := []match.Match{
+		{ExternalID: testURLPrefix + "jong-fc-utrecht-heracles", Competition: "Keuken Kampioen Divisie", HomeTeam: "Jong Utrecht", AwayTeam: "Heracles", HomeGoals: 0, AwayGoals: 3, Date: testDate, Status: match.Played},
+		{ExternalID: testURLPrefix + "jong-psv-dordrecht", Competition: "Keuken Kampioen Divisie", HomeTeam: "Jong PSV", AwayTeam: "Dordrecht", HomeGoals: 1, AwayGoals: 0, Date: testDate, Status: match.Played},
+		{ExternalID: testURLPrefix + "mvv-nac", Competition: "Keuken Kampioen Divisie", HomeTeam: "MVV", AwayTeam: "NAC", HomeGoals: 3, AwayGoals: 1, Date: testDate, Status: match.Played},
+	}
+	assert.Equal(t, expectedMatches, db.matches(t))
+
+	runs := db.runs(t)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].Succeeded())
+	assert.Equal(t, scraperun.Scrape, runs[0].Trigger)
+	assert.Equal(t, ts.URL, runs[0].SourceURL)
+	assert.Equal(t, http.StatusOK, runs[0].HTTPStatus)
+	assert.Equal(t, 3, runs[0].RowsParsed)
+	assert.Equal(t, 3, runs[0].RowsInserted)
+
+	pages := db.archivedPages(t)
+	require.Len(t, pages, 1)
+	assert.Equal(t, "Keuken Kampioen Divisie", pages[0].Competition)
+	assert.Equal(t, []byte(testData), pages[0].Body)
+}
+
+func TestScrapeMatchMetadata(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testMetadataData)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Eredivisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
 	}
+
+	one, zero := 1, 0
+	matches := db.matches(t)
+	require.Len(t, matches, 2)
+	assert.Contains(t, matches, match.Match{
+		ExternalID:        "https://example.com/ajax-psv",
+		Competition:       "Eredivisie",
+		HomeTeam:          "Ajax",
+		AwayTeam:          "PSV",
+		HomeGoals:         2,
+		AwayGoals:         1,
+		Date:              time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC),
+		Status:            match.Played,
+		Kickoff:           time.Date(2023, 3, 5, 14, 30, 0, 0, dates.Amsterdam),
+		HalfTimeHomeGoals: &one,
+		HalfTimeAwayGoals: &zero,
+	})
+	assert.Contains(t, matches, match.Match{
+		ExternalID:  "https://example.com/feyenoord-az",
+		Competition: "Eredivisie",
+		HomeTeam:    "Feyenoord",
+		AwayTeam:    "AZ",
+		Date:        time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC),
+		Status:      match.Postponed,
+	})
 }
 
-const testData = `
+func TestMatchKeepsItsExternalIDWhenItIsRescheduled(t *testing.T) {
+	db := newTestDB()
+	body := testData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	scraped := scraper.NewScrapeData(db, fetcher.New())
+	require.NoError(t, scraped.Backfill("Keuken Kampioen Divisie", ts.URL+"/{season}", 2022, 2022, 0))
+
+	body = strings.NewReplacer("Maandag 15 augustus 2022", "Maandag 22 augustus 2022", "-15-08", "-22-08").Replace(testData)
+	require.NoError(t, scraped.Backfill("Keuken Kampioen Divisie", ts.URL+"/{season}", 2022, 2022, 0))
+
+	matches := db.matches(t)
+	assert.Len(t, matches, 3)
+	assert.Equal(t, testURLPrefix+"mvv-nac", matches[2].ExternalID)
+	assert.Equal(t, time.Date(2022, 8, 22, 0, 0, 0, 0, time.UTC), matches[2].Date)
+}
+
+func TestScrapeSkipsMatchesUnderInvalidDateHeader(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testData+`
 	<div class="matches-panel align-left justify-center notes">
-	Maandag 15 augustus 2022
-	</div>
-	<div class="matches-panel d-flex align-center justify-center Played  ">
-	<span class="fld-match">
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/jong-fc-utrecht" class="left-team d-flex align-center justify-end">
-	<span>Jong Utrecht</span>
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/ch82doz9w9t1sw9ae3a4ffh0j.png" alt="Jong Utrecht">
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalcompetities/nederland/keuken-kampioen-divisie/programma-uitslagen/2022-2023/jong-fc-utrecht-heracles-15-08" class="score d-flex justify-center">
-	<div class="match-result">
-	<i>0</i>
-	<i class="match-result__divder">-</i>
-	<i>3</i>
-	</div>
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/heracles" class="right-team d-flex align-center">
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/dac758ef858jbq7pcb3gfwite.png" alt="Heracles">
-	<span><strong>Heracles</strong></span>
-	</a>
-	</span>
-	</div>
-	<div class="matches-panel d-flex align-center justify-center Played  ">
-	<span class="fld-match">
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/jong-psv" class="left-team d-flex align-center justify-end">
-	<span><strong>Jong PSV</strong></span>
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/2xlnfgrl6r18ftv38unwp7h4s.png" alt="Jong PSV">
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalcompetities/nederland/keuken-kampioen-divisie/programma-uitslagen/2022-2023/jong-psv-dordrecht-15-08" class="score d-flex justify-center">
-	<div class="match-result">
-	<i>1</i>
-	<i class="match-result__divder">-</i>
-	<i>0</i>
+	Maandag 15 augusti 2022
 	</div>
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/dordrecht" class="right-team d-flex align-center">
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/z9phg19papi9f5fd6qxzr836.png" alt="Dordrecht">
-	<span>Dordrecht</span>
-	</a>
-	</span>
-	</div>
-	<div class="matches-panel d-flex align-center justify-center Played  ">
-	<span class="fld-match">
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/mvv" class="left-team d-flex align-center justify-end">
-	<span><strong>MVV</strong></span>
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/bqm1bnp1bh2apyudlz1o1whr3.png" alt="MVV">
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalcompetities/nederland/keuken-kampioen-divisie/programma-uitslagen/2022-2023/mvv-nac-15-08" class="score d-flex justify-center">
-	<div class="match-result">
-	<i>3</i>
-	<i class="match-result__divder">-</i>
-	<i>1</i>
-	</div>
-	</a>
-	<a href="https://www.fcupdate.nl/voetbalteams/nederland/nac" class="right-team d-flex align-center">
-	<img src="https://imagecdn.fcupdate.nl/?height=96&amp;width=96&amp;url=https://ext.fcupdate.nl/teams/150x150/59t7flioj4w4mpwnrwbm0m8ck.png" alt="NAC">
-	<span>NAC</span>
-	</a>
-	</span>
-	</div>
-	`
+	<div class="matches-panel d-flex align-center justify-center Played">
+	<a class="left-team"><span>Almere City</span></a>
+	<a href="https://example.com/almere-city-vvv" class="score"><div><i>1</i><i>-</i><i>1</i></div></a>
+	<a class="right-team"><span>VVV</span></a>
+	</div>`)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	assert.Len(t, db.matches(t), 3)
+	_, err = db.GetTeamID("Almere City")
+	assert.Error(t, err)
+}
+
+func TestScrapeRecordsFailedRunWhenStoringFails(t *testing.T) {
+	db := newTestDB()
+	db.insertErr = func(m match.Match) error {
+		if m.HomeTeam == "Jong PSV" {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	ts := serve(t, testData)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	assert.Error(t, err)
+	assert.Empty(t, db.matches(t))
+	assert.Equal(t, 0, db.sourceRowCount(t, ts.URL))
+	assert.True(t, failedRuns(db.runs(t)))
+}
+
+func TestScrapeStopsAtTheDeadline(t *testing.T) {
+	db := newTestDB()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(ctx, scraperun.Predict)
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, failedRuns(db.runs(t)))
+}
+
+func TestScrapeDetectsPageWithoutRows(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, `<div class="fixtures"><div class="fixture">Jong Utrecht - Heracles 0-3</div></div>`)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	var layoutErr *scraper.LayoutError
+	assert.ErrorAs(t, err, &layoutErr)
+	assert.True(t, scraped.Health().Failing())
+	assert.True(t, failedRuns(db.runs(t)))
+	assert.Equal(t, 0, db.sourceRowCount(t, ts.URL))
+}
+
+func TestScrapeDetectsUnparseableDateHeaders(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, strings.ReplaceAll(testData, "Maandag 15 augustus 2022", "2022-08-15"))
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	var layoutErr *scraper.LayoutError
+	assert.ErrorAs(t, err, &layoutErr)
+	assert.Empty(t, db.matches(t))
+	assert.True(t, failedRuns(db.runs(t)))
+}
+
+func TestScrapeDetectsDropInRows(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testData)
+	require.NoError(t, db.UpdateSourceRowCount(ts.URL, 300, time.Now()))
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	var layoutErr *scraper.LayoutError
+	assert.ErrorAs(t, err, &layoutErr)
+	assert.Empty(t, db.matches(t))
+	assert.True(t, failedRuns(db.runs(t)))
+}
+
+func TestScrapeRecordsRowCount(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testData)
+	require.NoError(t, db.UpdateSourceRowCount(ts.URL, 5, time.Now()))
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	assert.NoError(t, err)
+	assert.False(t, scraped.Health().Failing())
+	assert.Equal(t, 4, db.sourceRowCount(t, ts.URL))
+}
+
+func TestConcurrentScrapesShareOneScrape(t *testing.T) {
+	db := newTestDB()
+
+	var requests int32
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		requested <- struct{}{}
+		<-release
+		w.Write([]byte(testData))
+	}))
+	defer ts.Close()
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- scraped.Scrape(context.Background(), scraperun.Scrape)
+		}()
+	}
+
+	<-requested
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&db.scrapeLocks))
+	assert.Len(t, db.runs(t), 1)
+}
+
+func TestDryRun(t *testing.T) {
+	db := newTestDB()
+	_, err := db.CreateTeam("Heracles")
+	require.NoError(t, err)
+	require.NoError(t, db.InsertOrUpdateMatch(match.Match{Competition: "Keuken Kampioen Divisie", HomeTeam: "Jong PSV", AwayTeam: "Dordrecht",
+		HomeGoals: 1, AwayGoals: 0, Date: testDate, Status: match.Played}))
+	require.NoError(t, db.InsertOrUpdateMatch(match.Match{Competition: "Keuken Kampioen Divisie", HomeTeam: "MVV", AwayTeam: "NAC",
+		HomeGoals: 2, AwayGoals: 1, Date: testDate, Status: match.Played}))
+	ts := serve(t, testData)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	report, err := scraped.DryRun(context.Background())
+
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+
+	assert.Len(t, report.Sources, 1)
+	source := report.Sources[0]
+	assert.Equal(t, []string{"Jong Utrecht"}, source.NewTeams)
+	assert.Len(t, source.NewMatches, 1)
+	assert.Equal(t, "Heracles", source.NewMatches[0].AwayTeam)
+	assert.Len(t, source.ScoreChanges, 1)
+	assert.Equal(t, 3, source.ScoreChanges[0].Scraped.HomeGoals)
+	assert.Equal(t, 2, source.ScoreChanges[0].Stored.HomeGoals)
+	assert.Equal(t, 1, source.Unchanged)
+	assert.Empty(t, source.Skipped)
+
+	assert.Len(t, db.matches(t), 2)
+	assert.Equal(t, 2, db.matches(t)[1].HomeGoals)
+	assert.Empty(t, db.archivedPages(t))
+	assert.Empty(t, db.runs(t))
+}
+
+func TestScrapeAfterDryRunStoresMatches(t *testing.T) {
+	db := newTestDB()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testData))
+	}))
+	defer ts.Close()
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	_, err := scraped.DryRun(context.Background())
+	require.NoError(t, err)
+	err = scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	assert.NoError(t, err)
+	assert.Len(t, db.matches(t), 3)
+	runs := db.runs(t)
+	require.Len(t, runs, 1)
+	assert.Equal(t, 3, runs[0].RowsInserted)
+}
+
+func TestScrapeRetriesFlakyServer(t *testing.T) {
+	db := newTestDB()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testData))
+	}))
+	defer ts.Close()
+
+	f := fetcher.New()
+	f.BaseDelay = time.Millisecond
+
+	scraped := scraper.NewScrapeData(db, f, scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests)
+	}
+	assert.Len(t, db.matches(t), 3)
+}
+
+func TestScrapeDoesNotParseErrorPages(t *testing.T) {
+	db := newTestDB()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, testData, http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	err := scraped.Scrape(context.Background(), scraperun.Scrape)
+
+	if err == nil {
+		t.Fatalf("Scrape() expected an error")
+	}
+	assert.Empty(t, db.matches(t))
+	assert.True(t, failedRuns(db.runs(t)))
+}
+
+// newArchiveDB returns a store with an archived copy of testData, and a match on the
+// same day that isn't on the page.
+func newArchiveDB(t *testing.T) *testDB {
+	db := newTestDB()
+	require.NoError(t, db.ArchivePage("Keuken Kampioen Divisie", "https://example.com", []byte(testData), testDate))
+	require.NoError(t, db.InsertOrUpdateMatch(match.Match{Competition: "Keuken Kampioen Divisie", HomeTeam: "Den Bosch", AwayTeam: "VVV",
+		HomeGoals: 1, AwayGoals: 1, Date: testDate, Status: match.Played}))
+	return db
+}
+
+func TestReparse(t *testing.T) {
+	db := newArchiveDB(t)
+	since := time.Date(2022, 8, 1, 0, 0, 0, 0, dates.Amsterdam)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New())
+	err := scraped.Reparse(since, false)
+
+	if err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+	assert.Equal(t, int32(1), db.scrapeLocks)
+	assert.Equal(t, int32(0), db.unlockedTransactions)
+	matches := db.matches(t)
+	assert.Len(t, matches, 4)
+	assert.Contains(t, matches, match.Match{
+		ExternalID:  testURLPrefix + "mvv-nac",
+		Competition: "Keuken Kampioen Divisie",
+		HomeTeam:    "MVV",
+		AwayTeam:    "NAC",
+		HomeGoals:   3,
+		AwayGoals:   1,
+		Date:        testDate,
+		Status:      match.Played,
+	})
+}
+
+func TestReparseWithRebuild(t *testing.T) {
+	db := newArchiveDB(t)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New())
+	err := scraped.Reparse(time.Time{}, true)
+
+	if err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+	matches := db.matches(t)
+	assert.Len(t, matches, 3)
+	for _, m := range matches {
+		assert.NotEqual(t, "Den Bosch", m.HomeTeam)
+	}
+}
+
+func TestScrapeAndReparseWithInMemoryDB(t *testing.T) {
+	db := newTestDB()
+	ts := serve(t, testData)
+
+	scraped := scraper.NewScrapeData(db, fetcher.New(), scraper.Source{Competition: "Keuken Kampioen Divisie", URL: ts.URL})
+	if err := scraped.Scrape(context.Background(), scraperun.Scrape); err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	if err := scraped.Reparse(time.Time{}, true); err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+
+	stored, err := db.FindMatch(match.Match{ExternalID: testURLPrefix + "mvv-nac"})
+	assert.NoError(t, err)
+	assert.Equal(t, "MVV", stored.HomeTeam)
+	assert.Equal(t, 3, stored.HomeGoals)
+
+	teams, err := db.FetchTeamsFromDB()
+	assert.NoError(t, err)
+	assert.Len(t, teams, 6)
+
+	assert.Equal(t, 4, db.sourceRowCount(t, ts.URL))
+}
+
+func TestBackfill(t *testing.T) {
+	db := newTestDB()
+
+	paths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(testData))
+	}))
+	defer ts.Close()
+
+	scraped := scraper.NewScrapeData(db, fetcher.New())
+	err := scraped.Backfill("Keuken Kampioen Divisie", ts.URL+"/programma-uitslagen/{season}", 2020, 2022, 0)
+
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	assert.Equal(t, []string{"/programma-uitslagen/2020-2021", "/programma-uitslagen/2021-2022", "/programma-uitslagen/2022-2023"}, paths)
+	assert.Len(t, db.archivedPages(t), 3)
+	assert.Len(t, db.matches(t), 3)
+	assert.Empty(t, db.runs(t))
+	assert.Equal(t, int32(3), db.scrapeLocks)
+	assert.Equal(t, int32(0), db.unlockedTransactions)
+}
+
+func TestBackfillRequiresSeasonPlaceholder(t *testing.T) {
+	scraped := scraper.NewScrapeData(newTestDB(), fetcher.New())
+	err := scraped.Backfill("Keuken Kampioen Divisie", "https://example.com/programma-uitslagen", 2020, 2022, 0)
+
+	assert.Error(t, err)
+}
+
+func failedRuns(runs []scraperun.Run) bool {
+	return len(runs) == 1 && !runs[0].Succeeded() && runs[0].RowsInserted == 0
+}
+
+var (
+	testData         = readTestdata("kkd-2022-08-15.html")
+	testMetadataData = readTestdata("metadata-unverified.html")
+)
+
+func readTestdata(name string) string {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+	return string(body)
+}
+
+const testURLPrefix = "https://www.fcupdate.nl/voetbalcompetities/nederland/keuken-kampioen-divisie/programma-uitslagen/2022-2023/"
@@ -0,0 +1,45 @@
+// Package scraperun defines the record that is kept of every time a source is scraped.
+package scraperun
+
+import "time"
+
+// Trigger tells what started a scrape.
+type Trigger string
+
+const (
+	Scheduler Trigger = "scheduler"
+	// Scrape is the /scrape endpoint
+	Scrape Trigger = "scrape"
+	// Predict is the /predict endpoint, which scrapes first if that didn't happen today
+	Predict Trigger = "predict"
+	CLI     Trigger = "cli"
+)
+
+// Run is the scrape of a single source.
+type Run struct {
+	ID          int       `json:"id"`
+	Trigger     Trigger   `json:"trigger"`
+	Competition string    `json:"competition"`
+	SourceURL   string    `json:"source_url"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	// HTTPStatus is 0 if there was no response.
+	HTTPStatus int `json:"http_status"`
+	// RowsParsed is the number of matches found on the page, RowsInserted the number of
+	// matches that were stored, new or updated, and RowsSkipped the number of rows that
+	// couldn't be parsed.
+	RowsParsed   int `json:"rows_parsed"`
+	RowsInserted int `json:"rows_inserted"`
+	RowsSkipped  int `json:"rows_skipped"`
+	// Error is empty if the run succeeded.
+	Error string `json:"error"`
+}
+
+func (r Run) Succeeded() bool {
+	return r.Error == ""
+}
+
+// Duration returns how long the run took, rounded to milliseconds.
+func (r Run) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+}
@@ -0,0 +1,124 @@
+package season
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jqno/balGPT/internal/dates"
+)
+
+// Season is a period in which a competition plays its matches. Start and End are
+// the first and the last day of the season.
+type Season struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+func (s Season) Contains(t time.Time) bool {
+	return !t.Before(s.Start) && t.Before(s.End.AddDate(0, 0, 1))
+}
+
+// Boundary is the day on which seasons start, for competitions that haven't registered
+// the dates of their seasons.
+type Boundary struct {
+	Month time.Month
+	Day   int
+}
+
+var DefaultBoundary = Boundary{Month: time.August, Day: 1}
+
+// ParseBoundary parses a boundary in the form "MM-DD", such as "08-01".
+func ParseBoundary(s string) (Boundary, error) {
+	t, err := time.Parse("01-02", strings.TrimSpace(s))
+	if err != nil {
+		return Boundary{}, fmt.Errorf("invalid season boundary %q, expected MM-DD", s)
+	}
+	return Boundary{Month: t.Month(), Day: t.Day()}, nil
+}
+
+// SeasonAt returns the season that is running at the given time.
+func (b Boundary) SeasonAt(t time.Time) Season {
+	t = t.In(dates.Amsterdam)
+	year := t.Year()
+	if t.Before(b.start(year)) {
+		year--
+	}
+	return b.season(year)
+}
+
+// Season returns the season with the given name, such as "2022-2023".
+func (b Boundary) Season(name string) (Season, error) {
+	startYear, err := StartYear(name)
+	if err != nil {
+		return Season{}, err
+	}
+	return b.season(startYear), nil
+}
+
+func (b Boundary) season(startYear int) Season {
+	return Season{
+		Name:  Name(startYear),
+		Start: b.start(startYear),
+		End:   b.start(startYear+1).AddDate(0, 0, -1),
+	}
+}
+
+func (b Boundary) start(year int) time.Time {
+	return time.Date(year, b.Month, b.Day, 0, 0, 0, 0, dates.Amsterdam)
+}
+
+// Calendar knows the seasons of all competitions: the ones that are registered, and
+// otherwise the ones that start on the Boundary.
+type Calendar struct {
+	Boundary Boundary
+	// Registered contains the registered seasons per competition ID.
+	Registered map[int][]Season
+}
+
+// SeasonAt returns the season of the competition that is running on the date of t. If
+// several registered seasons contain that date, the one that started last wins.
+func (c Calendar) SeasonAt(competitionID int, t time.Time) Season {
+	date := t.Format("2006-01-02")
+
+	var found *Season
+	for i, s := range c.Registered[competitionID] {
+		if date < s.Start.Format("2006-01-02") || date > s.End.Format("2006-01-02") {
+			continue
+		}
+		if found == nil || s.Start.After(found.Start) {
+			found = &c.Registered[competitionID][i]
+		}
+	}
+	if found != nil {
+		return *found
+	}
+
+	return c.Boundary.SeasonAt(t)
+}
+
+// Name returns the name of the season that starts in the given year.
+func Name(startYear int) string {
+	return fmt.Sprintf("%d-%d", startYear, startYear+1)
+}
+
+// StartYear returns the year in which the season with the given name starts.
+func StartYear(name string) (int, error) {
+	invalid := fmt.Errorf("invalid season %q, expected a name like 2022-2023", name)
+
+	first, second, found := strings.Cut(name, "-")
+	if !found || len(first) != 4 || len(second) != 4 {
+		return 0, invalid
+	}
+	startYear, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, invalid
+	}
+	endYear, err := strconv.Atoi(second)
+	if err != nil || endYear != startYear+1 {
+		return 0, invalid
+	}
+	return startYear, nil
+}
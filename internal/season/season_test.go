@@ -0,0 +1,61 @@
+package season
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jqno/balGPT/internal/dates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeasonAt(t *testing.T) {
+	s := DefaultBoundary.SeasonAt(time.Date(2023, 3, 5, 14, 30, 0, 0, dates.Amsterdam))
+
+	assert.Equal(t, "2022-2023", s.Name)
+	assert.Equal(t, time.Date(2022, 8, 1, 0, 0, 0, 0, dates.Amsterdam), s.Start)
+	assert.Equal(t, time.Date(2023, 7, 31, 0, 0, 0, 0, dates.Amsterdam), s.End)
+}
+
+func TestSeasonAtBoundaryUsesAmsterdamTime(t *testing.T) {
+	// Already August 1st in Amsterdam
+	s := DefaultBoundary.SeasonAt(time.Date(2023, 7, 31, 23, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "2023-2024", s.Name)
+}
+
+func TestSeasonWithCustomBoundary(t *testing.T) {
+	boundary, err := ParseBoundary("07-15")
+	assert.NoError(t, err)
+
+	s, err := boundary.Season("2020-2021")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 7, 15, 0, 0, 0, 0, dates.Amsterdam), s.Start)
+	assert.Equal(t, time.Date(2021, 7, 14, 0, 0, 0, 0, dates.Amsterdam), s.End)
+	assert.True(t, s.Contains(time.Date(2021, 7, 14, 20, 0, 0, 0, dates.Amsterdam)))
+	assert.False(t, s.Contains(time.Date(2021, 7, 15, 0, 0, 0, 0, dates.Amsterdam)))
+}
+
+func TestInvalidSeasonNames(t *testing.T) {
+	for _, name := range []string{"2022", "2022-2024", "22-23", "current"} {
+		_, err := DefaultBoundary.Season(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseInvalidBoundary(t *testing.T) {
+	_, err := ParseBoundary("August 1st")
+	assert.Error(t, err)
+}
+
+func TestCalendarPrefersRegisteredSeasons(t *testing.T) {
+	covid := Season{
+		Name:  "2019-2020",
+		Start: time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2020, 3, 8, 0, 0, 0, 0, time.UTC),
+	}
+	calendar := Calendar{Boundary: DefaultBoundary, Registered: map[int][]Season{1: {covid}}}
+
+	assert.Equal(t, covid, calendar.SeasonAt(1, time.Date(2020, 3, 8, 0, 0, 0, 0, dates.Amsterdam)))
+	assert.Equal(t, "2019-2020", calendar.SeasonAt(1, time.Date(2020, 5, 1, 0, 0, 0, 0, dates.Amsterdam)).Name)
+	assert.Equal(t, time.Date(2019, 8, 1, 0, 0, 0, 0, dates.Amsterdam), calendar.SeasonAt(2, time.Date(2020, 3, 8, 0, 0, 0, 0, dates.Amsterdam)).Start)
+}
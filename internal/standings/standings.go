@@ -0,0 +1,41 @@
+// Package standings ranks the teams of a competition by their points.
+package standings
+
+import (
+	"sort"
+
+	"github.com/jqno/balGPT/internal/team"
+)
+
+// Standing is the place of a team in the league table.
+type Standing struct {
+	Position int
+	TeamID   int
+	Team     string
+	Points   int
+}
+
+// Table ranks the teams in the leaderboard by their points, and by name when they're
+// tied.
+func Table(leaderboard map[int]int, teams []team.Team) []Standing {
+	names := make(map[int]string, len(teams))
+	for _, t := range teams {
+		names[t.ID] = t.Name
+	}
+
+	table := make([]Standing, 0, len(leaderboard))
+	for teamID, points := range leaderboard {
+		table = append(table, Standing{TeamID: teamID, Team: names[teamID], Points: points})
+	}
+
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Points != table[j].Points {
+			return table[i].Points > table[j].Points
+		}
+		return table[i].Team < table[j].Team
+	})
+	for i := range table {
+		table[i].Position = i + 1
+	}
+	return table
+}
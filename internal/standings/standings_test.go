@@ -0,0 +1,20 @@
+package standings
+
+import (
+	"testing"
+
+	"github.com/jqno/balGPT/internal/team"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	teams := []team.Team{{ID: 1, Name: "Ajax"}, {ID: 2, Name: "PSV"}, {ID: 3, Name: "AZ"}}
+
+	table := Table(map[int]int{1: 3, 2: 6, 3: 3}, teams)
+
+	assert.Equal(t, []Standing{
+		{Position: 1, TeamID: 2, Team: "PSV", Points: 6},
+		{Position: 2, TeamID: 3, Team: "AZ", Points: 3},
+		{Position: 3, TeamID: 1, Team: "Ajax", Points: 3},
+	}, table)
+}
@@ -0,0 +1,110 @@
+package team
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultSimilarityThreshold is the similarity above which two team names are
+// reported as suspected duplicates.
+const DefaultSimilarityThreshold = 0.8
+
+type DuplicateCandidate struct {
+	First      Team
+	Second     Team
+	Similarity float64
+}
+
+// Club affixes that the source site sometimes includes and sometimes leaves out,
+// as in "Jong Utrecht" vs "Jong FC Utrecht".
+var ignoredTokens = map[string]bool{
+	"afc": true, "bv": true, "fc": true, "sc": true, "sv": true, "vv": true,
+}
+
+// SuspectedDuplicates returns all pairs of teams whose names are at least as similar
+// as the given threshold, most similar first.
+func SuspectedDuplicates(teams []Team, threshold float64) []DuplicateCandidate {
+	candidates := []DuplicateCandidate{}
+	for i := 0; i < len(teams); i++ {
+		for j := i + 1; j < len(teams); j++ {
+			similarity := Similarity(teams[i].Name, teams[j].Name)
+			if similarity >= threshold {
+				candidates = append(candidates, DuplicateCandidate{First: teams[i], Second: teams[j], Similarity: similarity})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Similarity > candidates[j].Similarity
+	})
+
+	return candidates
+}
+
+// Similarity returns a number between 0 and 1 that indicates how alike two team names
+// are, where 1 means they are the same after normalization.
+func Similarity(a, b string) float64 {
+	a = normalize(a)
+	b = normalize(b)
+
+	longest := len([]rune(a))
+	if l := len([]rune(b)); l > longest {
+		longest = l
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+func normalize(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !ignoredTokens[field] {
+			tokens = append(tokens, field)
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+func levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minOf(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func minOf(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package team
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarityIgnoresClubAffixes(t *testing.T) {
+	assert.Equal(t, 1.0, Similarity("Jong Utrecht", "Jong FC Utrecht"))
+	assert.Equal(t, 1.0, Similarity("FC Twente", "twente"))
+}
+
+func TestSimilarityOfDifferentTeams(t *testing.T) {
+	assert.Less(t, Similarity("Jong PSV", "PSV"), DefaultSimilarityThreshold)
+	assert.Less(t, Similarity("NAC", "NEC"), DefaultSimilarityThreshold)
+}
+
+func TestSuspectedDuplicates(t *testing.T) {
+	teams := []Team{
+		{ID: 1, Name: "Jong Utrecht"},
+		{ID: 2, Name: "Heracles"},
+		{ID: 3, Name: "Jong FC Utrecht"},
+		{ID: 4, Name: "Heracles Almelo"},
+	}
+
+	candidates := SuspectedDuplicates(teams, DefaultSimilarityThreshold)
+
+	assert.Equal(t, []DuplicateCandidate{
+		{First: teams[0], Second: teams[2], Similarity: 1.0},
+	}, candidates)
+}
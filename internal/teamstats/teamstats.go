@@ -0,0 +1,158 @@
+// Package teamstats defines the statistics that are kept per team and season, such as
+// the number of matches won and the recent form.
+package teamstats
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormLength is the number of matches in a team's form.
+const FormLength = 5
+
+// Stats are the statistics of a team in one season of a competition.
+type Stats struct {
+	CompetitionID int
+	TeamID        int
+	Season        string
+	Total         Record
+	Home          Record
+	Away          Record
+	// Form contains the results of the last FormLength matches, oldest first, as W, D
+	// or L; for instance "WWDLW".
+	Form string
+}
+
+// Record counts the results of a number of matches.
+type Record struct {
+	Played       int
+	Won          int
+	Drawn        int
+	Lost         int
+	GoalsFor     int
+	GoalsAgainst int
+}
+
+// Points are the points that the record is worth in the league table.
+func (r Record) Points() int {
+	return 3*r.Won + r.Drawn
+}
+
+// Result is a played match, as far as the statistics are concerned.
+type Result struct {
+	CompetitionID int
+	HomeTeamID    int
+	AwayTeamID    int
+	HomeGoals     int
+	AwayGoals     int
+	Date          time.Time
+}
+
+// Compute returns the statistics of every team in every season that the results were
+// played in. seasonAt names the season of the competition at the given date.
+func Compute(results []Result, seasonAt func(competitionID int, date time.Time) string) []Stats {
+	sorted := append([]Result(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	type key struct {
+		competitionID int
+		teamID        int
+		season        string
+	}
+	stats := map[key]*Stats{}
+	order := []key{}
+	forms := map[key][]string{}
+
+	add := func(competitionID, teamID int, season string, home bool, goalsFor, goalsAgainst int) {
+		k := key{competitionID: competitionID, teamID: teamID, season: season}
+		s, ok := stats[k]
+		if !ok {
+			s = &Stats{CompetitionID: competitionID, TeamID: teamID, Season: season}
+			stats[k] = s
+			order = append(order, k)
+		}
+
+		outcome := s.Total.add(goalsFor, goalsAgainst)
+		if home {
+			s.Home.add(goalsFor, goalsAgainst)
+		} else {
+			s.Away.add(goalsFor, goalsAgainst)
+		}
+		forms[k] = append(forms[k], outcome)
+	}
+
+	for _, r := range sorted {
+		season := seasonAt(r.CompetitionID, r.Date)
+		add(r.CompetitionID, r.HomeTeamID, season, true, r.HomeGoals, r.AwayGoals)
+		add(r.CompetitionID, r.AwayTeamID, season, false, r.AwayGoals, r.HomeGoals)
+	}
+
+	all := make([]Stats, 0, len(order))
+	for _, k := range order {
+		form := forms[k]
+		if len(form) > FormLength {
+			form = form[len(form)-FormLength:]
+		}
+		stats[k].Form = strings.Join(form, "")
+		all = append(all, *stats[k])
+	}
+	return all
+}
+
+// Points returns the points of each team, over all the results. Teams without points
+// are left out.
+func Points(results []Result) map[int]int {
+	return Leaderboard(Compute(results, func(int, time.Time) string { return "" }))
+}
+
+// Leaderboard returns the points of each team in stats. Teams without points are left
+// out.
+func Leaderboard(stats []Stats) map[int]int {
+	points := make(map[int]int)
+	for _, s := range stats {
+		if s.Total.Points() > 0 {
+			points[s.TeamID] += s.Total.Points()
+		}
+	}
+	return points
+}
+
+// Sort puts stats in the order of the league table: by points, then by goal difference,
+// then by goals scored.
+func Sort(stats []Stats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i].Total, stats[j].Total
+		if a.Points() != b.Points() {
+			return a.Points() > b.Points()
+		}
+		if a.GoalsFor-a.GoalsAgainst != b.GoalsFor-b.GoalsAgainst {
+			return a.GoalsFor-a.GoalsAgainst > b.GoalsFor-b.GoalsAgainst
+		}
+		if a.GoalsFor != b.GoalsFor {
+			return a.GoalsFor > b.GoalsFor
+		}
+		return stats[i].TeamID < stats[j].TeamID
+	})
+}
+
+// add counts one match, and returns its outcome as W, D or L.
+func (r *Record) add(goalsFor, goalsAgainst int) string {
+	r.Played++
+	r.GoalsFor += goalsFor
+	r.GoalsAgainst += goalsAgainst
+
+	switch {
+	case goalsFor > goalsAgainst:
+		r.Won++
+		return "W"
+	case goalsFor < goalsAgainst:
+		r.Lost++
+		return "L"
+	default:
+		r.Drawn++
+		return "D"
+	}
+}
@@ -0,0 +1,67 @@
+package teamstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func result(home, away, homeGoals, awayGoals int, date time.Time) Result {
+	return Result{CompetitionID: 1, HomeTeamID: home, AwayTeamID: away, HomeGoals: homeGoals, AwayGoals: awayGoals, Date: date}
+}
+
+func bySeasonYear(competitionID int, date time.Time) string {
+	return date.Format("2006")
+}
+
+func TestCompute(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	results := []Result{
+		result(2, 1, 1, 1, day(8)),
+		result(1, 2, 3, 1, day(1)),
+		result(1, 3, 0, 2, day(15)),
+	}
+
+	stats := Compute(results, bySeasonYear)
+
+	assert.Equal(t, []Stats{
+		{
+			CompetitionID: 1, TeamID: 1, Season: "2023",
+			Total: Record{Played: 3, Won: 1, Drawn: 1, Lost: 1, GoalsFor: 4, GoalsAgainst: 4},
+			Home:  Record{Played: 2, Won: 1, Lost: 1, GoalsFor: 3, GoalsAgainst: 3},
+			Away:  Record{Played: 1, Drawn: 1, GoalsFor: 1, GoalsAgainst: 1},
+			Form:  "WDL",
+		},
+		{
+			CompetitionID: 1, TeamID: 2, Season: "2023",
+			Total: Record{Played: 2, Drawn: 1, Lost: 1, GoalsFor: 2, GoalsAgainst: 4},
+			Home:  Record{Played: 1, Drawn: 1, GoalsFor: 1, GoalsAgainst: 1},
+			Away:  Record{Played: 1, Lost: 1, GoalsFor: 1, GoalsAgainst: 3},
+			Form:  "LD",
+		},
+		{
+			CompetitionID: 1, TeamID: 3, Season: "2023",
+			Total: Record{Played: 1, Won: 1, GoalsFor: 2},
+			Away:  Record{Played: 1, Won: 1, GoalsFor: 2},
+			Form:  "W",
+		},
+	}, stats)
+	assert.Equal(t, 4, stats[0].Total.Points())
+}
+
+func TestComputeSplitsSeasonsAndKeepsShortForm(t *testing.T) {
+	results := []Result{}
+	for d := 1; d <= 7; d++ {
+		results = append(results, result(1, 2, d%2, 0, time.Date(2022, 12, d*5, 0, 0, 0, 0, time.UTC)))
+	}
+
+	stats := Compute(results, bySeasonYear)
+
+	assert.Len(t, stats, 4)
+	assert.Equal(t, "2022", stats[0].Season)
+	assert.Equal(t, "DWDWD", stats[0].Form)
+	assert.Equal(t, 6, stats[0].Total.Played)
+	assert.Equal(t, "2023", stats[2].Season)
+	assert.Equal(t, "W", stats[2].Form)
+}
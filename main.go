@@ -1,12 +1,16 @@
 package main
 
 import (
-	"github.com/jqno/balGPT/internal/app"
+	"log"
+	"os"
+
+	"github.com/jqno/balGPT/internal/cli"
 	"github.com/jqno/balGPT/internal/config"
 )
 
 func main() {
 	cfg := config.LoadConfig()
-	app := app.NewApp(cfg)
-	app.Run()
+	if err := cli.Run(cfg, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
 }
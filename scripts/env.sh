@@ -10,5 +10,5 @@ export AUTH_USERNAME=admin
 export AUTH_PASSWORD=admin
 
 # Change this? Change it in fly.toml too!
-export SCRAPER_URL=https://www.fcupdate.nl/voetbalcompetities/nederland/eredivisie/programma-uitslagen
+export SCRAPER_SOURCES="Eredivisie=https://www.fcupdate.nl/voetbalcompetities/nederland/eredivisie/programma-uitslagen;Keuken Kampioen Divisie=https://www.fcupdate.nl/voetbalcompetities/nederland/keuken-kampioen-divisie/programma-uitslagen"
 export API_BASE_URL=http://localhost:8080
@@ -1,3 +1,3 @@
 #!/usr/bin/env sh
 
-migrate -path db/migrations -database "postgres://$DB_USER:$DB_PASS@$DB_HOST:$DB_PORT/$DB_NAME?sslmode=disable" down
+go run main.go migrate down "${1:-1}"